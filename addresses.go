@@ -0,0 +1,175 @@
+// addresses.go probes every resolved address of a hostname, instead of a random one
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+)
+
+// addressSummary is the gist of the statistics of one of the addresses of a hostname,
+// printed side by side with the other ones to tell which backend is misbehaving.
+type addressSummary struct {
+	addr             netip.Addr
+	totalProbes      uint
+	successfulProbes uint
+	packetLoss       float32 // packetLoss is in percent
+	rttResults       rttResult
+	outages          int
+	down             bool       // down is set when the last probe of the address failed
+	family           string     // family is "IPv4" or "IPv6" in dual-stack mode, and empty otherwise
+	srv              *srvRecord // srv is the record of the target when it was discovered through SRV records
+	stopped          bool       // stopped is set when the record of the target was removed
+}
+
+// expandAddresses returns a target per resolved address of the hostname, which keep
+// their own statistics. They share addressGroup, so that the last one of them
+// can summarize all of them. IP addresses are returned as they are.
+func expandAddresses(t *tcping) []*tcping {
+	if t.destIsIP || len(t.records) < 2 {
+		return []*tcping{t}
+	}
+
+	group := make([]*tcping, 0, len(t.records))
+	for _, addr := range t.records {
+		group = append(group, newAddressTarget(t, addr, t.records))
+	}
+
+	for _, a := range group {
+		a.addressGroup = group
+	}
+
+	t.printInfo("Probing all %d addresses of %s: %s", len(t.records), t.userInput.hostname, formatRecords(t.records))
+
+	return group
+}
+
+// newAddressTarget returns a copy of the target, yet to be probed, for one of the addresses of its hostname
+func newAddressTarget(t *tcping, addr netip.Addr, records []netip.Addr) *tcping {
+	a := *t
+	a.setIP(addr)
+	a.records = records
+	a.hostnameChanges = []hostnameChange{{Addr: addr, When: t.hostnameChanges[0].When, Records: records}}
+	if t.timeline != nil {
+		a.timeline = newProbeTimeline()
+	}
+
+	return &a
+}
+
+// setIP sets the IP address the target probes
+func (t *tcping) setIP(ip netip.Addr) {
+	t.userInput.ip = ip
+	if t.userInput.networkInterface.use {
+		// the dialer of the interface connects to a fixed address
+		t.userInput.networkInterface.remoteAddr = &net.TCPAddr{IP: ip.AsSlice(), Port: int(t.userInput.port)}
+	}
+}
+
+// isFirstAddress reports whether the target is the first address of its hostname,
+// or the only one when the addresses are not expanded.
+func (t *tcping) isFirstAddress() bool {
+	return len(t.addressGroup) == 0 || t.addressGroup[0] == t
+}
+
+// isLastAddress reports whether the target is the last address of its hostname,
+// whose statistics are followed by the summary of all of them.
+func (t *tcping) isLastAddress() bool {
+	return len(t.addressGroup) > 0 && t.addressGroup[len(t.addressGroup)-1] == t
+}
+
+// summarizeAddresses returns the summary of every address of the group
+func summarizeAddresses(group []*tcping) []addressSummary {
+	summaries := make([]addressSummary, 0, len(group))
+
+	for _, a := range group {
+		s := addressSummary{
+			addr:             a.userInput.ip,
+			totalProbes:      a.totalSuccessfulProbes + a.totalUnsuccessfulProbes,
+			successfulProbes: a.totalSuccessfulProbes,
+			rttResults:       a.rtt.result(),
+			outages:          len(a.allOutages()),
+			down:             a.destWasDown,
+		}
+
+		if a.userInput.dualStack {
+			s.family = addressFamily(s.addr)
+		}
+
+		if a.srv != nil {
+			record := a.srvRecord
+			s.srv = &record
+			s.stopped = a.isStopped()
+		}
+
+		s.packetLoss = (float32(a.totalUnsuccessfulProbes) / float32(s.totalProbes)) * 100
+		if math.IsNaN(float64(s.packetLoss)) {
+			s.packetLoss = 0
+		}
+
+		summaries = append(summaries, s)
+	}
+
+	return summaries
+}
+
+// label returns the address, preceded by its family in dual-stack mode,
+// or by its SRV record for the targets of SRV records.
+func (s addressSummary) label() string {
+	switch {
+	case s.srv != nil:
+		return fmt.Sprintf("%s %s priority %d weight %d", s.srv, s.addr, s.srv.priority, s.srv.weight)
+	case s.family != "":
+		return s.family + " " + s.addr.String()
+	default:
+		return s.addr.String()
+	}
+}
+
+// status returns whether the address is up or down, as of its last probe,
+// or gone when its SRV record was removed.
+func (s addressSummary) status() string {
+	switch {
+	case s.stopped:
+		return "gone"
+	case s.down:
+		return "down"
+	default:
+		return "up"
+	}
+}
+
+// formatAddressSummary returns a line of the per-address summary, without the status
+func formatAddressSummary(s addressSummary) string {
+	line := fmt.Sprintf("%d/%d received, %.2f%% packet loss, %d outages", s.successfulProbes, s.totalProbes, s.packetLoss, s.outages)
+
+	if s.rttResults.hasResults {
+		line += fmt.Sprintf(", rtt avg/p99 %.3f/%.3f ms", s.rttResults.average, s.rttResults.p99)
+	}
+
+	return line
+}
+
+// addressColumnWidth returns the width of the longest label of the summaries
+func addressColumnWidth(summaries []addressSummary) int {
+	width := 0
+	for _, s := range summaries {
+		width = max(width, len(s.label()))
+	}
+	return width
+}
+
+// addressSummaryTitle returns the title of the per-address summary of the hostname,
+// the per-family one in dual-stack mode, or the per-target one of SRV records.
+func addressSummaryTitle(t tcping) string {
+	if t.srv != nil {
+		return fmt.Sprintf("--- %s SRV per-target summary ---", t.srv.name)
+	}
+
+	kind := "per-address"
+	if len(t.addressSummaries) > 0 && t.addressSummaries[0].family != "" {
+		kind = "per-family"
+	}
+	return fmt.Sprintf("--- %s %s summary on port %d ---", t.userInput.hostname, kind, t.userInput.port)
+}
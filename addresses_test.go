@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandAddresses(t *testing.T) {
+	t.Run("ip", func(t *testing.T) {
+		stats := createTestStats(t)
+		stats.userInput.hostname = "127.0.0.1"
+		stats.destIsIP = true
+
+		expanded := expandAddresses(stats)
+		assert.Equal(t, []*tcping{stats}, expanded)
+		assert.True(t, stats.isFirstAddress())
+		assert.False(t, stats.isLastAddress(), "a lone target has no summary")
+	})
+
+	t.Run("single record", func(t *testing.T) {
+		stats := createTestStats(t)
+		stats.userInput.hostname = "tcping.test"
+		stats.records = []netip.Addr{netip.MustParseAddr("127.0.0.1")}
+
+		assert.Equal(t, []*tcping{stats}, expandAddresses(stats))
+	})
+
+	t.Run("records", func(t *testing.T) {
+		printer := &infoRecorder{}
+		stats := createTestStats(t)
+		stats.printer = printer
+		stats.userInput.hostname = "tcping.test"
+		stats.timeline = newProbeTimeline()
+		stats.userInput.networkInterface = networkInterface{use: true, remoteAddr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 12345}}
+		stats.records = []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2"), netip.MustParseAddr("2001:db8::1")}
+		stats.hostnameChanges = []hostnameChange{{Addr: netip.MustParseAddr("192.0.2.2"), When: time.Now(), Records: stats.records}}
+
+		expanded := expandAddresses(stats)
+		assert.Len(t, expanded, 3)
+
+		for i, a := range expanded {
+			assert.Equal(t, stats.records[i], a.userInput.ip)
+			assert.Equal(t, "tcping.test", a.userInput.hostname)
+			assert.Equal(t, []hostnameChange{{Addr: stats.records[i], When: stats.hostnameChanges[0].When, Records: stats.records}}, a.hostnameChanges)
+			assert.Equal(t, expanded, a.addressGroup)
+			assert.NotSame(t, stats.timeline, a.timeline, "every address has its own timeline")
+			assert.Equal(t, netip.AddrPortFrom(stats.records[i], stats.userInput.port).String(), a.userInput.networkInterface.remoteAddr.String())
+			assert.Equal(t, i == 0, a.isFirstAddress())
+			assert.Equal(t, i == 2, a.isLastAddress())
+		}
+
+		assert.Equal(t, []string{"Probing all 3 addresses of tcping.test: 192.0.2.1, 192.0.2.2, 2001:db8::1"}, printer.messages)
+	})
+}
+
+func TestSummarizeAddresses(t *testing.T) {
+	up := createTestStats(t)
+	up.userInput.ip = netip.MustParseAddr("192.0.2.1")
+	up.totalSuccessfulProbes = 3
+	up.totalUnsuccessfulProbes = 1
+	for range 3 {
+		up.rtt.add(20)
+	}
+	up.outages = []outage{{ip: up.userInput.ip}}
+
+	down := createTestStats(t)
+	down.userInput.ip = netip.MustParseAddr("192.0.2.2")
+	down.totalUnsuccessfulProbes = 4
+	down.destWasDown = true
+	down.currentOutage = outage{start: time.Now(), ip: down.userInput.ip}
+
+	fresh := createTestStats(t)
+	fresh.userInput.ip = netip.MustParseAddr("2001:db8::1")
+
+	summaries := summarizeAddresses([]*tcping{up, down, fresh})
+	assert.Len(t, summaries, 3)
+
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), summaries[0].addr)
+	assert.Equal(t, uint(4), summaries[0].totalProbes)
+	assert.Equal(t, float32(25), summaries[0].packetLoss)
+	assert.Equal(t, 1, summaries[0].outages)
+	assert.Equal(t, "up", summaries[0].status())
+	assert.InDelta(t, 20, summaries[0].rttResults.average, 0.001)
+	assert.Equal(t, fmt.Sprintf("3/4 received, 25.00%% packet loss, 1 outages, rtt avg/p99 %.3f/%.3f ms", summaries[0].rttResults.average, summaries[0].rttResults.p99),
+		formatAddressSummary(summaries[0]))
+
+	assert.Equal(t, float32(100), summaries[1].packetLoss)
+	assert.Equal(t, 1, summaries[1].outages, "the ongoing outage is counted")
+	assert.Equal(t, "down", summaries[1].status())
+	assert.Equal(t, "0/4 received, 100.00% packet loss, 1 outages", formatAddressSummary(summaries[1]))
+
+	assert.Equal(t, float32(0), summaries[2].packetLoss, "no probes, no packet loss")
+	assert.Equal(t, len("2001:db8::1"), addressColumnWidth(summaries))
+}
@@ -0,0 +1,450 @@
+// compare.go compares the statistics of two runs of tcping
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// significanceLevel is the p-value under which a latency shift is considered significant
+const significanceLevel = 0.05
+
+// metricKind decides how the values of a compared metric are formatted
+type metricKind int
+
+const (
+	metricCount metricKind = iota
+	metricPercent
+	metricMilliseconds
+	metricDuration // metricDuration values are in seconds
+)
+
+// comparedMetric is a single statistic of both runs
+type comparedMetric struct {
+	name          string
+	kind          metricKind
+	before        float64
+	after         float64
+	higherIsWorse bool // higherIsWorse is set for the metrics that should go down, e.g. packet loss
+}
+
+// latencyShift is the change of the average RTT between two runs,
+// along with the result of Welch's t-test on it.
+type latencyShift struct {
+	difference float64 // difference is the average RTT after minus the one before, in ms
+	t          float64
+	df         float64 // df is the degrees of freedom of the t-test
+	p          float64 // p is the two-tailed p-value of the t-test
+	tested     bool    // tested is false when a run has fewer than two RTTs
+}
+
+// comparison holds the statistics of a target in both runs
+type comparison struct {
+	before  string // before is the target of the first run in the <hostname:port> format
+	after   string // after is the target of the second run in the <hostname:port> format
+	metrics []comparedMetric
+	shift   latencyShift
+}
+
+// comparisonPrinter is implemented by the printers able to print comparisons.
+// These are the ones writing to the terminal.
+type comparisonPrinter interface {
+	printer
+
+	// printComparison should print the statistics of
+	// both runs of a target along with their changes.
+	printComparison(c comparison)
+}
+
+// title returns the heading of the comparison
+func (c comparison) title() string {
+	if c.before == c.after {
+		return fmt.Sprintf("--- %s comparison ---", c.before)
+	}
+
+	return fmt.Sprintf("--- %s vs %s comparison ---", c.before, c.after)
+}
+
+// change returns the difference of the values of both runs
+func (m comparedMetric) change() float64 {
+	return m.after - m.before
+}
+
+// worse reports whether the metric changed for the worse
+func (m comparedMetric) worse() bool {
+	return m.higherIsWorse && m.after > m.before
+}
+
+// better reports whether the metric changed for the better
+func (m comparedMetric) better() bool {
+	return m.higherIsWorse && m.after < m.before
+}
+
+// format formats a value of the metric
+func (m comparedMetric) format(value float64) string {
+	switch m.kind {
+	case metricPercent:
+		return fmt.Sprintf("%.2f%%", value)
+	case metricMilliseconds:
+		return fmt.Sprintf("%.3f ms", value)
+	case metricDuration:
+		return durationToString(time.Duration(value * float64(time.Second)))
+	default:
+		return fmt.Sprintf("%.0f", value)
+	}
+}
+
+// formatChange formats the change of the metric with its sign,
+// along with the relative change of the RTTs.
+func (m comparedMetric) formatChange() string {
+	change := m.change()
+
+	sign := "+"
+	if change < 0 {
+		sign = "-"
+	}
+
+	switch m.kind {
+	case metricPercent:
+		return fmt.Sprintf("%+.2f%%", change)
+	case metricMilliseconds:
+		if m.before == 0 {
+			return fmt.Sprintf("%+.3f ms", change)
+		}
+		return fmt.Sprintf("%+.3f ms (%+.1f%%)", change, change/m.before*100)
+	case metricDuration:
+		return sign + durationToString(time.Duration(math.Abs(change)*float64(time.Second)))
+	default:
+		return fmt.Sprintf("%+.0f", change)
+	}
+}
+
+// significant reports whether the shift of the average RTT is statistically significant
+func (s latencyShift) significant() bool {
+	return s.tested && s.p < significanceLevel
+}
+
+// String describes the shift of the average RTT and whether it's significant
+func (s latencyShift) String() string {
+	if !s.tested {
+		return fmt.Sprintf("avg rtt shift of %+.3f ms cannot be tested, as a run has fewer than two successful probes", s.difference)
+	}
+
+	verdict := "is not statistically significant"
+	if s.significant() {
+		verdict = "is statistically significant"
+	}
+
+	return fmt.Sprintf("avg rtt shift of %+.3f ms %s (Welch's t-test: t = %.3f, df = %.1f, p = %.4f)",
+		s.difference, verdict, s.t, s.df, s.p)
+}
+
+// newComparison compares the statistics of a target in both runs
+func newComparison(before, after *tcping) comparison {
+	c := comparison{
+		before: before.target(),
+		after:  after.target(),
+		shift:  welchTTest(&before.rtt, &after.rtt),
+	}
+
+	c.metrics = append(c.metrics,
+		comparedMetric{name: "probes", kind: metricCount,
+			before: float64(before.totalSuccessfulProbes + before.totalUnsuccessfulProbes),
+			after:  float64(after.totalSuccessfulProbes + after.totalUnsuccessfulProbes)},
+		comparedMetric{name: "packet loss", kind: metricPercent, higherIsWorse: true,
+			before: lossPercentage(before), after: lossPercentage(after)},
+	)
+
+	// the RTTs are only compared when both runs have some
+	if b, a := before.rttResults, after.rttResults; b.hasResults && a.hasResults {
+		rtts := []struct {
+			name          string
+			before, after float32
+		}{
+			{"rtt min", b.min, a.min},
+			{"rtt avg", b.average, a.average},
+			{"rtt max", b.max, a.max},
+			{"rtt p50", b.p50, a.p50},
+			{"rtt p90", b.p90, a.p90},
+			{"rtt p95", b.p95, a.p95},
+			{"rtt p99", b.p99, a.p99},
+			{"rtt stddev", b.stdDev, a.stdDev},
+			{"rtt jitter", b.jitter, a.jitter},
+		}
+
+		for _, rtt := range rtts {
+			c.metrics = append(c.metrics, comparedMetric{
+				name:          rtt.name,
+				kind:          metricMilliseconds,
+				before:        float64(rtt.before),
+				after:         float64(rtt.after),
+				higherIsWorse: true,
+			})
+		}
+	}
+
+	beforeOutages := summarizeOutages(before.allOutages(), before.totalUptime)
+	afterOutages := summarizeOutages(after.allOutages(), after.totalUptime)
+
+	c.metrics = append(c.metrics,
+		comparedMetric{name: "outages", kind: metricCount, higherIsWorse: true,
+			before: float64(beforeOutages.count), after: float64(afterOutages.count)},
+		comparedMetric{name: "MTTR", kind: metricDuration, higherIsWorse: true,
+			before: beforeOutages.mttr.Seconds(), after: afterOutages.mttr.Seconds()},
+		comparedMetric{name: "longest downtime", kind: metricDuration, higherIsWorse: true,
+			before: before.longestDowntime.duration.Seconds(), after: after.longestDowntime.duration.Seconds()},
+		comparedMetric{name: "total downtime", kind: metricDuration, higherIsWorse: true,
+			before: before.totalDowntime.Seconds(), after: after.totalDowntime.Seconds()},
+	)
+
+	return c
+}
+
+// lossPercentage returns the percentage of the failed probes
+func lossPercentage(t *tcping) float64 {
+	total := t.totalSuccessfulProbes + t.totalUnsuccessfulProbes
+	if total == 0 {
+		return 0
+	}
+
+	return float64(t.totalUnsuccessfulProbes) / float64(total) * 100
+}
+
+// welchTTest tests whether the average RTTs of both runs differ,
+// without assuming that both runs have the same variance.
+func welchTTest(before, after *rttStats) latencyShift {
+	var shift latencyShift
+	if before.count > 0 && after.count > 0 {
+		shift.difference = after.mean - before.mean
+	}
+
+	if before.count < 2 || after.count < 2 {
+		return shift
+	}
+	shift.tested = true
+
+	beforeN := float64(before.count)
+	afterN := float64(after.count)
+	beforeVariance := before.sampleVariance() / beforeN
+	afterVariance := after.sampleVariance() / afterN
+	variance := beforeVariance + afterVariance
+
+	// both runs had the same RTT for every probe
+	if variance == 0 {
+		shift.df = beforeN + afterN - 2
+		shift.p = 1
+		if shift.difference != 0 {
+			shift.t = math.Copysign(math.Inf(1), shift.difference)
+			shift.p = 0
+		}
+		return shift
+	}
+
+	shift.t = shift.difference / math.Sqrt(variance)
+	// Welch–Satterthwaite equation
+	shift.df = variance * variance /
+		(beforeVariance*beforeVariance/(beforeN-1) + afterVariance*afterVariance/(afterN-1))
+	shift.p = studentTTwoTailed(shift.t, shift.df)
+
+	return shift
+}
+
+// studentTTwoTailed returns the probability of a t value at least
+// as extreme as the given one in Student's t-distribution.
+func studentTTwoTailed(t, df float64) float64 {
+	return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// regularizedIncompleteBeta returns I_x(a, b),
+// evaluated by its continued fraction as described in Numerical Recipes.
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	lgammaAB, _ := math.Lgamma(a + b)
+	lgammaA, _ := math.Lgamma(a)
+	lgammaB, _ := math.Lgamma(b)
+	front := math.Exp(lgammaAB - lgammaA - lgammaB + a*math.Log(x) + b*math.Log(1-x))
+
+	// the continued fraction converges quickly only below this point
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(a, b, x) / a
+	}
+
+	return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction
+// of the incomplete beta function with the modified Lentz's method.
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 300
+		epsilon       = 1e-12
+		tiny          = 1e-300
+	)
+
+	// avoid dividing by zero
+	clamp := func(v float64) float64 {
+		if math.Abs(v) < tiny {
+			return tiny
+		}
+		return v
+	}
+
+	c := 1.0
+	d := 1 / clamp(1-(a+b)*x/(a+1))
+	h := d
+
+	for i := 1; i <= maxIterations; i++ {
+		m := float64(i)
+
+		// the even step
+		numerator := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 / clamp(1+numerator*d)
+		c = clamp(1 + numerator/c)
+		h *= d * c
+
+		// the odd step
+		numerator = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 / clamp(1+numerator*d)
+		c = clamp(1 + numerator/c)
+		delta := d * c
+		h *= delta
+
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+
+	return h
+}
+
+// pairTargets matches the targets of both runs by their <hostname:port>.
+// When each run has a single target, they are paired regardless,
+// e.g. to compare a service before and after moving it to a new host.
+func pairTargets(before, after []*tcping) ([][2]*tcping, []string) {
+	if len(before) == 1 && len(after) == 1 {
+		return [][2]*tcping{{before[0], after[0]}}, nil
+	}
+
+	afterByTarget := make(map[string]*tcping, len(after))
+	for _, t := range after {
+		afterByTarget[t.target()] = t
+	}
+
+	var pairs [][2]*tcping
+	var unmatched []string
+	paired := make(map[string]bool)
+
+	for _, t := range before {
+		target := t.target()
+		if match, ok := afterByTarget[target]; ok {
+			pairs = append(pairs, [2]*tcping{t, match})
+			paired[target] = true
+		} else {
+			unmatched = append(unmatched, target)
+		}
+	}
+
+	for _, t := range after {
+		if target := t.target(); !paired[target] {
+			unmatched = append(unmatched, target)
+		}
+	}
+
+	return pairs, unmatched
+}
+
+// compareUsage prints how the compare subcommand should be run
+func compareUsage(flagSet *flag.FlagSet) {
+	executableName := os.Args[0]
+
+	colorLightCyan("\nTCPING version %s\n\n", version)
+	colorRed("Try running %s compare like:\n", executableName)
+	colorRed("%s compare <before filename> <after filename>. For example:\n", executableName)
+	colorRed("%s compare before.json after.json\n", executableName)
+	colorRed("%s compare --before-session 1 --after-session 2 tcping.db tcping.db\n", executableName)
+	colorRed("The files can be the output of the --csv (with -D), -j or --db flags.\n")
+	colorYellow("\n[optional flags]\n")
+
+	flagSet.VisitAll(func(f *flag.Flag) {
+		flagName := f.Name
+		if len(f.Name) > 1 {
+			flagName = "-" + flagName
+		}
+
+		colorYellow("  -%s : %s\n", flagName, f.Usage)
+	})
+
+	os.Exit(1)
+}
+
+// runCompare reads the probes saved by two runs, recomputes
+// their statistics and prints how they changed.
+func runCompare(args []string) {
+	flagSet := flag.NewFlagSet("compare", flag.ExitOnError)
+
+	outputJSON := flagSet.Bool("j", false, "output in JSON format.")
+	prettyJSON := flagSet.Bool("pretty", false, "use indentation when using json output format. No effect without the '-j' flag.")
+	noColor := flagSet.Bool("no-color", false, "do not colorize output.")
+	target := flagSet.String("target", "", "only compare the probes of the given <hostname/ip:port>.")
+	beforeSession := flagSet.Int64("before-session", 0, "only include the probes of the given session of the first sqlite database.")
+	afterSession := flagSet.Int64("after-session", 0, "only include the probes of the given session of the second sqlite database.")
+	showHelp := flagSet.Bool("h", false, "show help message.")
+
+	flagSet.Usage = func() { compareUsage(flagSet) }
+
+	permuteArgs(flagSet, args)
+	flagSet.Parse(args)
+
+	if *showHelp || flagSet.NArg() != 2 {
+		compareUsage(flagSet)
+	}
+
+	base := &tcping{}
+	setTerminalPrinter(base, outputJSON, prettyJSON, noColor)
+	p := base.printer.(comparisonPrinter)
+
+	var filter reportFilter
+	if *target != "" {
+		var err error
+		if filter.target, err = parseReportTarget(*target); err != nil {
+			p.printError("%s", err)
+			os.Exit(1)
+		}
+	}
+
+	var runs [2][]*tcping
+	for i, session := range []int64{*beforeSession, *afterSession} {
+		filter.session = session
+
+		probes, err := loadSavedProbes(flagSet.Arg(i), "", filter)
+		if err != nil {
+			p.printError("%s", err)
+			os.Exit(1)
+		}
+
+		runs[i] = replayProbes(probes)
+	}
+
+	pairs, unmatched := pairTargets(runs[0], runs[1])
+	if len(pairs) == 0 {
+		p.printError("The runs have no targets in common")
+		os.Exit(1)
+	}
+
+	for _, target := range unmatched {
+		p.printInfo("%s was only probed in one of the runs", target)
+	}
+
+	for _, pair := range pairs {
+		p.printComparison(newComparison(pair[0], pair[1]))
+	}
+}
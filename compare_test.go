@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRttStats returns the statistics of the given RTTs
+func newRttStats(rtts ...float32) *rttStats {
+	var stats rttStats
+	for _, rtt := range rtts {
+		stats.add(rtt)
+	}
+	return &stats
+}
+
+func TestStudentTTwoTailed(t *testing.T) {
+	tests := []struct {
+		t    float64
+		df   float64
+		want float64
+	}{
+		{t: 0, df: 10, want: 1},
+		{t: 2, df: 10, want: 0.07339},
+		{t: -2, df: 10, want: 0.07339},
+		{t: 2.228, df: 10, want: 0.05},
+		{t: 1.96, df: 1e6, want: 0.05},
+		{t: 10, df: 30, want: 0},
+	}
+
+	for _, tt := range tests {
+		assert.InDelta(t, tt.want, studentTTwoTailed(tt.t, tt.df), 0.0001, "t = %v, df = %v", tt.t, tt.df)
+	}
+}
+
+func TestWelchTTest(t *testing.T) {
+	t.Run("significant shift", func(t *testing.T) {
+		// the first example of Welch's t-test on Wikipedia
+		before := newRttStats(27.5, 21.0, 19.0, 23.6, 17.0, 17.9, 16.9, 20.1, 21.9, 22.6, 23.1, 19.6, 19.0, 21.7, 21.4)
+		after := newRttStats(27.1, 22.0, 20.8, 23.4, 23.4, 23.5, 25.8, 22.0, 24.8, 20.2, 21.9, 22.1, 22.9, 20.5, 24.4)
+
+		shift := welchTTest(before, after)
+		assert.True(t, shift.tested)
+		assert.InDelta(t, 2.167, shift.difference, 0.001)
+		assert.InDelta(t, 2.455, shift.t, 0.001)
+		assert.InDelta(t, 24.99, shift.df, 0.01)
+		assert.InDelta(t, 0.0214, shift.p, 0.0001)
+		assert.True(t, shift.significant())
+	})
+
+	t.Run("insignificant shift", func(t *testing.T) {
+		before := newRttStats(10, 12, 11, 13, 9)
+		after := newRttStats(11, 12, 10, 13, 10)
+
+		shift := welchTTest(before, after)
+		assert.True(t, shift.tested)
+		assert.False(t, shift.significant())
+	})
+
+	t.Run("constant RTTs", func(t *testing.T) {
+		shift := welchTTest(newRttStats(10, 10, 10), newRttStats(10, 10))
+		assert.Equal(t, float64(1), shift.p)
+		assert.False(t, shift.significant())
+
+		shift = welchTTest(newRttStats(10, 10, 10), newRttStats(20, 20))
+		assert.Equal(t, float64(0), shift.p)
+		assert.True(t, shift.significant())
+	})
+
+	t.Run("not enough RTTs", func(t *testing.T) {
+		shift := welchTTest(newRttStats(10, 12), newRttStats(20))
+		assert.False(t, shift.tested)
+		assert.False(t, shift.significant())
+		assert.InDelta(t, 9, shift.difference, 0.001)
+		assert.Equal(t, "avg rtt shift of +9.000 ms cannot be tested, as a run has fewer than two successful probes", shift.String())
+
+		shift = welchTTest(newRttStats(10, 12), newRttStats())
+		assert.Equal(t, float64(0), shift.difference, "there is no shift without RTTs")
+	})
+}
+
+func TestComparedMetricFormat(t *testing.T) {
+	tests := []struct {
+		metric comparedMetric
+		before string
+		after  string
+		change string
+	}{
+		{
+			metric: comparedMetric{kind: metricCount, before: 100, after: 90},
+			before: "100", after: "90", change: "-10",
+		},
+		{
+			metric: comparedMetric{kind: metricPercent, before: 1.5, after: 4},
+			before: "1.50%", after: "4.00%", change: "+2.50%",
+		},
+		{
+			metric: comparedMetric{kind: metricMilliseconds, before: 10, after: 12.5},
+			before: "10.000 ms", after: "12.500 ms", change: "+2.500 ms (+25.0%)",
+		},
+		{
+			metric: comparedMetric{kind: metricMilliseconds, before: 0, after: 1},
+			before: "0.000 ms", after: "1.000 ms", change: "+1.000 ms",
+		},
+		{
+			metric: comparedMetric{kind: metricDuration, before: 90, after: 30},
+			before: "1 minute 30 seconds", after: "30 seconds", change: "-1 minute",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.before, tt.metric.format(tt.metric.before))
+		assert.Equal(t, tt.after, tt.metric.format(tt.metric.after))
+		assert.Equal(t, tt.change, tt.metric.formatChange())
+	}
+}
+
+func TestNewComparison(t *testing.T) {
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+	addr := netip.MustParseAddr("127.0.0.1")
+
+	newRun := func(rtts ...float32) *tcping {
+		var probes []savedProbe
+		for i, rtt := range rtts {
+			probes = append(probes, savedProbe{
+				timestamp: start.Add(time.Duration(i) * time.Second),
+				hostname:  "localhost",
+				addr:      addr,
+				port:      443,
+				success:   rtt > 0,
+				rtt:       rtt,
+			})
+		}
+		return replayProbes(probes)[0]
+	}
+
+	// zero RTTs stand for failed probes
+	before := newRun(10, 0, 0, 10, 12, 11)
+	after := newRun(20, 21, 22, 20, 21, 22)
+
+	c := newComparison(before, after)
+	assert.Equal(t, "--- localhost:443 comparison ---", c.title())
+
+	metrics := make(map[string]comparedMetric)
+	var names []string
+	for _, m := range c.metrics {
+		metrics[m.name] = m
+		names = append(names, m.name)
+	}
+
+	assert.Equal(t, []string{
+		"probes", "packet loss",
+		"rtt min", "rtt avg", "rtt max", "rtt p50", "rtt p90", "rtt p95", "rtt p99", "rtt stddev", "rtt jitter",
+		"outages", "MTTR", "longest downtime", "total downtime",
+	}, names)
+
+	assert.Equal(t, comparedMetric{name: "probes", kind: metricCount, before: 6, after: 6}, metrics["probes"])
+	assert.InDelta(t, 33.333, metrics["packet loss"].before, 0.001)
+	assert.Equal(t, float64(0), metrics["packet loss"].after)
+	assert.True(t, metrics["packet loss"].better())
+	assert.Equal(t, float64(21), metrics["rtt avg"].after)
+	assert.True(t, metrics["rtt avg"].worse())
+	assert.Equal(t, float64(1), metrics["outages"].before)
+	assert.Equal(t, float64(2), metrics["longest downtime"].before)
+
+	assert.True(t, c.shift.significant())
+	assert.InDelta(t, 10.25, c.shift.difference, 0.001)
+}
+
+func TestPairTargets(t *testing.T) {
+	newTarget := func(hostname string, port uint16) *tcping {
+		return &tcping{userInput: userInput{hostname: hostname, port: port}}
+	}
+
+	t.Run("single targets", func(t *testing.T) {
+		before := newTarget("old.example.com", 443)
+		after := newTarget("new.example.com", 443)
+
+		pairs, unmatched := pairTargets([]*tcping{before}, []*tcping{after})
+		assert.Equal(t, [][2]*tcping{{before, after}}, pairs)
+		assert.Empty(t, unmatched)
+	})
+
+	t.Run("multiple targets", func(t *testing.T) {
+		before := []*tcping{newTarget("example.com", 443), newTarget("example.com", 22)}
+		after := []*tcping{newTarget("example.com", 80), newTarget("example.com", 443)}
+
+		pairs, unmatched := pairTargets(before, after)
+		assert.Equal(t, [][2]*tcping{{before[0], after[1]}}, pairs)
+		assert.Equal(t, []string{"example.com:22", "example.com:80"}, unmatched)
+	})
+}
@@ -0,0 +1,108 @@
+// config.go reads the options and targets from a configuration file
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configTargetsKey is the key holding the list of targets in the
+// configuration file. Set to a string, it's the '--targets' flag instead.
+const configTargetsKey = "targets"
+
+// config holds the contents of a configuration file.
+type config struct {
+	// options maps flag names to their values.
+	options map[string]string
+	// targets holds the targets, each as a host and a port.
+	targets [][]string
+}
+
+// readConfigFile reads a YAML configuration file, such as:
+//
+//	i: 0.5
+//	tls: true
+//	targets:
+//	  - www.example.com:443
+//	  - 192.168.1.1:22
+//
+// Every key but the list of targets must be a flag name.
+func readConfigFile(filename string, flagSet *flag.FlagSet) (config, error) {
+	cfg := config{options: make(map[string]string)}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return cfg, fmt.Errorf("failed to parse config file %s: %w", filename, err)
+	}
+
+	for key, node := range values {
+		if key == configTargetsKey && node.Kind == yaml.SequenceNode {
+			var targets []string
+			if err := node.Decode(&targets); err != nil {
+				return cfg, fmt.Errorf("invalid targets in config file %s on line %d", filename, node.Line)
+			}
+
+			for _, target := range targets {
+				hostPort := parseHostPortArgs([]string{target})
+				if len(hostPort) != 2 {
+					return cfg, fmt.Errorf("invalid target %q in config file %s, expected <hostname/ip:port>", target, filename)
+				}
+				cfg.targets = append(cfg.targets, hostPort)
+			}
+
+			continue
+		}
+
+		if flagSet.Lookup(key) == nil || key == "config" {
+			return cfg, fmt.Errorf("unknown option %q in config file %s on line %d", key, filename, node.Line)
+		}
+
+		if node.Kind != yaml.ScalarNode {
+			return cfg, fmt.Errorf("option %q in config file %s on line %d should be a single value", key, filename, node.Line)
+		}
+
+		cfg.options[key] = node.Value
+	}
+
+	return cfg, nil
+}
+
+// apply sets the flags from the configuration file,
+// unless they were already set on the command line.
+func (cfg config) apply(flagSet *flag.FlagSet) error {
+	setOnCommandLine := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	// sorted, so that errors are reported deterministically
+	for _, name := range slices.Sorted(maps.Keys(cfg.options)) {
+		if setOnCommandLine[name] {
+			continue
+		}
+
+		value := cfg.options[name]
+		if !flagTakesValue(flagSet, name) {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("invalid value %q for option %q in config file, expected true or false", value, name)
+			}
+		}
+
+		if err := flagSet.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for option %q in config file", value, name)
+		}
+	}
+
+	return nil
+}
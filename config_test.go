@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestConfig writes the contents to a config file and returns its path.
+func writeTestConfig(t *testing.T, contents string) string {
+	filename := filepath.Join(t.TempDir(), "tcping.yaml")
+	if err := os.WriteFile(filename, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+
+	return filename
+}
+
+// newTestFlagSet creates a flag set with a few of the tcping flags.
+func newTestFlagSet() (*flag.FlagSet, *float64, *bool, *string) {
+	flagSet := flag.NewFlagSet("tcping", flag.ContinueOnError)
+	interval := flagSet.Float64("i", 1, "")
+	useTLS := flagSet.Bool("tls", false, "")
+	targetsFile := flagSet.String("targets", "", "")
+	flagSet.String("config", "", "")
+
+	return flagSet, interval, useTLS, targetsFile
+}
+
+func TestReadConfigFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		contents    string
+		wantOptions map[string]string
+		wantTargets [][]string
+		wantErr     string
+	}{
+		{
+			name: "options and targets",
+			contents: `
+i: 0.5
+tls: true
+targets:
+  - www.example.com:443
+  - "[::1]:22"
+`,
+			wantOptions: map[string]string{"i": "0.5", "tls": "true"},
+			wantTargets: [][]string{{"www.example.com", "443"}, {"::1", "22"}},
+		},
+		{
+			name:        "targets file",
+			contents:    "targets: hosts.txt\n",
+			wantOptions: map[string]string{"targets": "hosts.txt"},
+		},
+		{
+			name:     "unknown option",
+			contents: "i: 1\nfoo: bar\n",
+			wantErr:  `unknown option "foo"`,
+		},
+		{
+			name:     "nested config",
+			contents: "config: other.yaml\n",
+			wantErr:  `unknown option "config"`,
+		},
+		{
+			name:     "list value",
+			contents: "i:\n  - 1\n",
+			wantErr:  `option "i"`,
+		},
+		{
+			name:     "target without port",
+			contents: "targets:\n  - www.example.com\n",
+			wantErr:  `invalid target "www.example.com"`,
+		},
+		{
+			name:     "invalid yaml",
+			contents: "i: [1\n",
+			wantErr:  "failed to parse config file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagSet, _, _, _ := newTestFlagSet()
+
+			cfg, err := readConfigFile(writeTestConfig(t, tt.contents), flagSet)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantOptions, cfg.options)
+			assert.Equal(t, tt.wantTargets, cfg.targets)
+		})
+	}
+}
+
+func TestReadConfigFileMissing(t *testing.T) {
+	flagSet, _, _, _ := newTestFlagSet()
+
+	_, err := readConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), flagSet)
+	assert.ErrorContains(t, err, "failed to read config file")
+}
+
+func TestConfigApplyPrecedence(t *testing.T) {
+	flagSet, interval, useTLS, targetsFile := newTestFlagSet()
+	assert.NoError(t, flagSet.Parse([]string{"-i", "2"}))
+
+	cfg := config{options: map[string]string{"i": "0.5", "tls": "true"}}
+	assert.NoError(t, cfg.apply(flagSet))
+
+	// the command line wins over the config file, which wins over the defaults
+	assert.Equal(t, float64(2), *interval)
+	assert.True(t, *useTLS)
+	assert.Equal(t, "", *targetsFile)
+}
+
+func TestConfigApplyInvalidValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]string
+	}{
+		{
+			name:    "not a number",
+			options: map[string]string{"i": "fast"},
+		},
+		{
+			name:    "not a boolean",
+			options: map[string]string{"tls": "yes please"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagSet, _, _, _ := newTestFlagSet()
+
+			cfg := config{options: tt.options}
+			assert.Error(t, cfg.apply(flagSet))
+		})
+	}
+}
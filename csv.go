@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,14 +15,21 @@ import (
 type csvPrinter struct {
 	probeWriter       *csv.Writer
 	statsWriter       *csv.Writer
+	outagesWriter     *csv.Writer
 	probeFile         *os.File
 	statsFile         *os.File
+	outagesFile       *os.File
 	statsFilename     string
 	probeFilename     string
+	outagesFilename   string
+	outagesWritten    map[string]int // outagesWritten counts the outages already written per target
 	headerDone        bool
 	statsHeaderDone   bool
 	showTimestamp     *bool
 	showSourceAddress *bool
+	showTLS           bool // showTLS adds the TLS handshake columns
+	showHTTP          bool // showHTTP adds the HTTP request columns
+	showTimings       bool // showTimings adds the DNS, connect and first byte time columns
 	cleanup           func()
 }
 
@@ -33,6 +42,20 @@ const (
 	colTCPConn       = "TCP_Conn"
 	colLatency       = "Latency(ms)"
 	colSourceAddress = "Source Address"
+	colDNS           = "DNS(ms)"
+	colTCPConnect    = "TCP Connect(ms)"
+	colFirstByte     = "First Byte(ms)"
+	colTLSHandshake  = "TLS Handshake(ms)"
+	colTLSVersion    = "TLS Version"
+	colTLSCipher     = "TLS Cipher"
+	colTLSALPN       = "TLS ALPN"
+	colCertSubject   = "Cert Subject"
+	colCertExpiry    = "Cert Expiry"
+	colTLSError      = "TLS Error"
+	colHTTPStatus    = "HTTP Status"
+	colHTTPTTFB      = "TTFB(ms)"
+	colHTTPTotal     = "HTTP Total(ms)"
+	colHTTPError     = "HTTP Error"
 )
 
 const (
@@ -49,6 +72,11 @@ func addCSVExtension(filename string, withStats bool) string {
 	return filename + ".csv"
 }
 
+// outagesCSVFilename returns the name of the file listing the outages
+func outagesCSVFilename(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + "_outages.csv"
+}
+
 func newCSVPrinter(filename string, showTimestamp *bool, showSourceAddress *bool) (*csvPrinter, error) {
 	filename = addCSVExtension(filename, false)
 
@@ -63,6 +91,8 @@ func newCSVPrinter(filename string, showTimestamp *bool, showSourceAddress *bool
 		probeFile:         file,
 		probeFilename:     filename,
 		statsFilename:     statsFilename,
+		outagesFilename:   outagesCSVFilename(filename),
+		outagesWritten:    make(map[string]int),
 		showTimestamp:     showTimestamp,
 		showSourceAddress: showSourceAddress,
 	}
@@ -80,6 +110,12 @@ func newCSVPrinter(filename string, showTimestamp *bool, showSourceAddress *bool
 		if cp.statsFile != nil {
 			cp.statsFile.Close()
 		}
+		if cp.outagesWriter != nil {
+			cp.outagesWriter.Flush()
+		}
+		if cp.outagesFile != nil {
+			cp.outagesFile.Close()
+		}
 	}
 
 	return cp, nil
@@ -99,6 +135,39 @@ func (cp *csvPrinter) writeHeader() error {
 		headers = append(headers, colSourceAddress)
 	}
 
+	if cp.showTimings {
+		headers = append(headers, colDNS)
+	}
+
+	if cp.showTLS || cp.showHTTP || cp.showTimings {
+		headers = append(headers, colTCPConnect)
+	}
+
+	if cp.showTimings {
+		headers = append(headers, colFirstByte)
+	}
+
+	if cp.showTLS {
+		headers = append(headers,
+			colTLSHandshake,
+			colTLSVersion,
+			colTLSCipher,
+			colTLSALPN,
+			colCertSubject,
+			colCertExpiry,
+			colTLSError,
+		)
+	}
+
+	if cp.showHTTP {
+		headers = append(headers,
+			colHTTPStatus,
+			colHTTPTTFB,
+			colHTTPTotal,
+			colHTTPError,
+		)
+	}
+
 	if *cp.showTimestamp {
 		headers = append(headers, colTimestamp)
 	}
@@ -147,7 +216,74 @@ func (cp *csvPrinter) printStart(hostname string, port uint16) {
 	fmt.Printf("TCPing results for %s on port %d being written to: %s\n", hostname, port, cp.probeFilename)
 }
 
-func (cp *csvPrinter) printProbeSuccess(sourceAddr string, userInput userInput, streak uint, rtt float32) {
+// formatOptionalTime formats a time in ms, leaving the cell empty when it wasn't measured.
+func formatOptionalTime(ms float32) string {
+	if ms <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%.3f", ms)
+}
+
+// detailsRecord returns the cells of the probe details columns.
+func (cp *csvPrinter) detailsRecord(details probeDetails) []string {
+	var record []string
+
+	if cp.showTimings {
+		record = append(record, formatOptionalTime(details.dnsTime))
+	}
+
+	if cp.showTLS || cp.showHTTP || cp.showTimings {
+		record = append(record, formatOptionalTime(details.connectTime))
+	}
+
+	if cp.showTimings {
+		record = append(record, formatOptionalTime(details.firstByteTime))
+	}
+
+	if cp.showTLS {
+		tlsRecord := make([]string, 7)
+		if tlsInfo := details.tls; tlsInfo != nil {
+			tlsRecord[0] = fmt.Sprintf("%.3f", tlsInfo.handshakeTime)
+			if tlsInfo.err != nil {
+				tlsRecord[6] = tlsInfo.err.Error()
+			} else {
+				tlsRecord[1] = tlsInfo.version
+				tlsRecord[2] = tlsInfo.cipherSuite
+				tlsRecord[3] = tlsInfo.alpn
+				tlsRecord[4] = tlsInfo.certSubject
+				if !tlsInfo.certExpiry.IsZero() {
+					tlsRecord[5] = tlsInfo.certExpiry.Format(timeFormat)
+				}
+			}
+		}
+		record = append(record, tlsRecord...)
+	}
+
+	if cp.showHTTP {
+		httpRecord := make([]string, 4)
+		if httpInfo := details.http; httpInfo != nil {
+			if httpInfo.statusCode != 0 {
+				httpRecord[0] = strconv.Itoa(httpInfo.statusCode)
+				httpRecord[1] = fmt.Sprintf("%.3f", httpInfo.ttfb)
+				httpRecord[2] = fmt.Sprintf("%.3f", httpInfo.total)
+			}
+			if httpInfo.err != nil {
+				httpRecord[3] = httpInfo.err.Error()
+			}
+		}
+		record = append(record, httpRecord...)
+	}
+
+	return record
+}
+
+func (cp *csvPrinter) printProbeSuccess(sourceAddr string, userInput userInput, streak uint, rtt float32, details probeDetails) {
+	// the probes of a previous IP address are not a part of the statistics
+	if userInput.drainingPreviousIP || userInput.showFailuresOnly {
+		return
+	}
+
 	record := []string{
 		"Reply",
 		userInput.hostname,
@@ -161,14 +297,25 @@ func (cp *csvPrinter) printProbeSuccess(sourceAddr string, userInput userInput,
 		record = append(record, sourceAddr)
 	}
 
+	record = append(record, cp.detailsRecord(details)...)
+
 	if err := cp.writeRecord(record); err != nil {
 		cp.printError("failed to write success record: %v", err)
 	}
 }
 
-func (cp *csvPrinter) printProbeFail(userInput userInput, streak uint) {
+func (cp *csvPrinter) printProbeFail(userInput userInput, streak uint, details probeDetails) {
+	if userInput.drainingPreviousIP {
+		return
+	}
+
+	status := "No reply"
+	if details.failureReason != "" {
+		status = fmt.Sprintf("No reply (%s)", details.failureReason)
+	}
+
 	record := []string{
-		"No reply",
+		status,
 		userInput.hostname,
 		userInput.ip.String(),
 		fmt.Sprint(userInput.port),
@@ -180,14 +327,21 @@ func (cp *csvPrinter) printProbeFail(userInput userInput, streak uint) {
 		record = append(record, "")
 	}
 
+	record = append(record, cp.detailsRecord(details)...)
+
 	if err := cp.writeRecord(record); err != nil {
 		cp.printError("failed to write failure record: %v", err)
 	}
 }
 
-func (cp *csvPrinter) printRetryingToResolve(hostname string) {
+func (cp *csvPrinter) printRetryingToResolve(hostname, resolver string) {
+	status := "Resolving"
+	if resolver != "" {
+		status = fmt.Sprintf("Resolving (using %s)", resolver)
+	}
+
 	record := []string{
-		"Resolving",
+		status,
 		hostname,
 		"",
 		"",
@@ -268,12 +422,23 @@ func (cp *csvPrinter) printStatistics(t tcping) {
 	timestamp := time.Now().Format(timeFormat)
 	statistics := [][]string{
 		{"Timestamp", timestamp},
+		{"Hostname", t.userInput.hostname},
+		{"IP", t.userInput.ip.String()},
+		{"Port", fmt.Sprint(t.userInput.port)},
 		{"Total Packets", fmt.Sprint(totalPackets)},
 		{"Successful Probes", fmt.Sprint(t.totalSuccessfulProbes)},
 		{"Unsuccessful Probes", fmt.Sprint(t.totalUnsuccessfulProbes)},
 		{"Packet Loss", fmt.Sprintf("%.2f%%", packetLoss)},
 	}
 
+	if len(t.failureReasons) > 0 {
+		statistics = append(statistics, []string{"Failure Reasons", formatFailureReasons(t.failureReasons)})
+	}
+
+	if len(t.raceWins) > 0 {
+		statistics = append(statistics, []string{"Happy Eyeballs Wins", formatRaceWins(t.raceWins)})
+	}
+
 	if t.lastSuccessfulProbe.IsZero() {
 		statistics = append(statistics, []string{"Last Successful Probe", "Never succeeded"})
 	} else {
@@ -305,11 +470,30 @@ func (cp *csvPrinter) printStatistics(t tcping) {
 		)
 	}
 
+	if outages := t.allOutages(); len(outages) > 0 {
+		summary := summarizeOutages(outages, t.totalUptime)
+		statistics = append(statistics,
+			[]string{"Outages", fmt.Sprint(summary.count)},
+			[]string{"MTTR", durationToString(summary.mttr)},
+			[]string{"MTBF", durationToString(summary.mtbf)},
+		)
+	}
+
 	if !t.destIsIP {
 		statistics = append(statistics, []string{"Retried Hostname Lookups", fmt.Sprint(t.retriedHostnameLookups)})
 
 		if len(t.hostnameChanges) >= 2 {
 			for i := 0; i < len(t.hostnameChanges)-1; i++ {
+				// only the records changed
+				if change := t.hostnameChanges[i+1]; change.Addr == t.hostnameChanges[i].Addr {
+					statistics = append(statistics,
+						[]string{"Records Change", formatRecords(change.PreviousRecords)},
+						[]string{"To", formatRecords(change.Records)},
+						[]string{"At", change.When.Format(timeFormat)},
+					)
+					continue
+				}
+
 				statistics = append(statistics,
 					[]string{"IP Change", t.hostnameChanges[i].Addr.String()},
 					[]string{"To", t.hostnameChanges[i+1].Addr.String()},
@@ -319,11 +503,25 @@ func (cp *csvPrinter) printStatistics(t tcping) {
 		}
 	}
 
+	for _, change := range t.srvChanges() {
+		statistics = append(statistics,
+			[]string{"SRV Targets Change", strings.Join(change.PreviousTargets, ", ")},
+			[]string{"To", strings.Join(change.Targets, ", ")},
+			[]string{"At", change.When.Format(timeFormat)},
+		)
+	}
+
 	if t.rttResults.hasResults {
 		statistics = append(statistics,
 			[]string{"RTT Min", fmt.Sprintf("%.3f ms", t.rttResults.min)},
 			[]string{"RTT Avg", fmt.Sprintf("%.3f ms", t.rttResults.average)},
 			[]string{"RTT Max", fmt.Sprintf("%.3f ms", t.rttResults.max)},
+			[]string{"RTT P50", fmt.Sprintf("%.3f ms", t.rttResults.p50)},
+			[]string{"RTT P90", fmt.Sprintf("%.3f ms", t.rttResults.p90)},
+			[]string{"RTT P95", fmt.Sprintf("%.3f ms", t.rttResults.p95)},
+			[]string{"RTT P99", fmt.Sprintf("%.3f ms", t.rttResults.p99)},
+			[]string{"RTT StdDev", fmt.Sprintf("%.3f ms", t.rttResults.stdDev)},
+			[]string{"RTT Jitter", fmt.Sprintf("%.3f ms", t.rttResults.jitter)},
 		)
 	}
 
@@ -344,9 +542,71 @@ func (cp *csvPrinter) printStatistics(t tcping) {
 	}
 
 	fmt.Printf("TCPing statistics written to: %s\n", cp.statsFilename)
+
+	written, err := cp.writeOutages(t)
+	if err != nil {
+		cp.printError("failed to write outage record: %v", err)
+		return
+	}
+
+	if written {
+		fmt.Printf("TCPing outages written to: %s\n", cp.outagesFilename)
+	}
+}
+
+// writeOutages appends the outages of the target that were not written yet
+// to the outages file, reporting whether anything was written.
+// The ongoing outage is written only during the final call, once the program ends.
+func (cp *csvPrinter) writeOutages(t tcping) (bool, error) {
+	outages := t.outages
+	if !t.endTime.IsZero() {
+		outages = t.allOutages()
+	}
+
+	target := fmt.Sprintf("%s:%d", t.userInput.hostname, t.userInput.port)
+	done := cp.outagesWritten[target]
+	if done >= len(outages) {
+		return false, nil
+	}
+
+	if cp.outagesFile == nil {
+		file, err := os.OpenFile(cp.outagesFilename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePermission)
+		if err != nil {
+			return false, fmt.Errorf("failed to create outages CSV file: %w", err)
+		}
+		cp.outagesFile = file
+		cp.outagesWriter = csv.NewWriter(file)
+
+		headers := []string{colHostname, colPort, "Start", "End", "Duration(s)", "Failed Probes", colIP, "Ongoing"}
+		if err := cp.outagesWriter.Write(headers); err != nil {
+			return false, fmt.Errorf("failed to write outages headers: %w", err)
+		}
+	}
+
+	for _, o := range outages[done:] {
+		record := []string{
+			t.userInput.hostname,
+			fmt.Sprint(t.userInput.port),
+			o.start.Format(timeFormat),
+			o.end.Format(timeFormat),
+			fmt.Sprintf("%.3f", o.duration.Seconds()),
+			fmt.Sprint(o.failedProbes),
+			o.ip.String(),
+			strconv.FormatBool(o.ongoing),
+		}
+
+		if err := cp.outagesWriter.Write(record); err != nil {
+			return false, err
+		}
+	}
+	cp.outagesWritten[target] = len(outages)
+
+	cp.outagesWriter.Flush()
+
+	return true, cp.outagesWriter.Error()
 }
 
 // Satisfying remaining printer interface methods
-func (cp *csvPrinter) printTotalDownTime(_ time.Duration) {}
-func (cp *csvPrinter) printVersion()                      {}
-func (cp *csvPrinter) printInfo(_ string, _ ...any)       {}
+func (cp *csvPrinter) printTotalDownTime(_ userInput, _ time.Duration) {}
+func (cp *csvPrinter) printVersion()                                   {}
+func (cp *csvPrinter) printInfo(_ string, _ ...any)                    {}
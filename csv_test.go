@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/csv"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -58,6 +60,46 @@ func TestWriteRecord(t *testing.T) {
 	os.Remove(cp.statsFilename)
 }
 
+func TestPrintRetryingToResolve(t *testing.T) {
+	dataFilename := filepath.Join(t.TempDir(), "test_data.csv")
+	showTimestamp := false
+	showSourceAddress := false
+
+	cp, err := newCSVPrinter(dataFilename, &showTimestamp, &showSourceAddress)
+	assert.NoError(t, err)
+	defer cp.cleanup()
+
+	cp.printRetryingToResolve("example.com", "")
+	cp.printRetryingToResolve("example.com", "tls://1.1.1.1:853")
+
+	file, err := os.Open(dataFilename)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Status", "Hostname", "IP", "Port", "TCP_Conn", "Latency(ms)"},
+		{"Resolving", "example.com", "", "", "", ""},
+		{"Resolving (using tls://1.1.1.1:853)", "example.com", "", "", "", ""},
+	}, records)
+}
+
+func TestOutagesCSVFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{filename: "data.csv", want: "data_outages.csv"},
+		{filename: "./out/data.csv", want: "./out/data_outages.csv"},
+		{filename: "a.b.csv", want: "a.b_outages.csv"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, outagesCSVFilename(tt.filename), tt.filename)
+	}
+}
+
 func TestWriteStatistics(t *testing.T) {
 	dataFilename := "test_data.csv"
 	showTimestamp := true
@@ -130,3 +172,90 @@ func TestCleanup(t *testing.T) {
 	os.Remove(dataFilename)
 	os.Remove(cp.statsFilename)
 }
+
+func TestWriteOutages(t *testing.T) {
+	dataFilename := "test_data.csv"
+	showTimestamp := false
+	showSourceAddress := false
+
+	cp, err := newCSVPrinter(dataFilename, &showTimestamp, &showSourceAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, "test_data_outages.csv", cp.outagesFilename)
+
+	now := time.Now()
+	stats := tcping{
+		startTime:     now,
+		destWasDown:   true,
+		currentOutage: outage{start: now.Add(time.Minute), failedProbes: 4, ip: netip.MustParseAddr("10.0.0.2")},
+		userInput:     userInput{hostname: "example.com", port: 443},
+		outages: []outage{
+			{start: now, end: now.Add(12 * time.Second), duration: 12 * time.Second, failedProbes: 3, ip: netip.MustParseAddr("10.0.0.1")},
+		},
+	}
+
+	// the ongoing outage is left out until the final call,
+	// and outages already written are not repeated
+	cp.printStatistics(stats)
+	cp.printStatistics(stats)
+	stats.endTime = now.Add(2 * time.Minute)
+	cp.printStatistics(stats)
+
+	file, err := os.Open(cp.outagesFilename)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Hostname", "Port", "Start", "End", "Duration(s)", "Failed Probes", "IP", "Ongoing"},
+		{"example.com", "443", now.Format(timeFormat), now.Add(12 * time.Second).Format(timeFormat), "12.000", "3", "10.0.0.1", "false"},
+		{"example.com", "443", now.Add(time.Minute).Format(timeFormat), stats.endTime.Format(timeFormat), "60.000", "4", "10.0.0.2", "true"},
+	}, records)
+
+	cp.cleanup()
+	os.Remove(dataFilename)
+	os.Remove(cp.statsFilename)
+	os.Remove(cp.outagesFilename)
+}
+
+func TestDetailsRecord(t *testing.T) {
+	details := probeDetails{
+		dnsTime:       1.5,
+		connectTime:   2.25,
+		firstByteTime: 3,
+		http:          &httpDetails{ttfb: 4, total: 5, statusCode: 200},
+	}
+
+	tests := []struct {
+		name string
+		cp   csvPrinter
+		want []string
+	}{
+		{
+			name: "no details",
+			cp:   csvPrinter{},
+			want: nil,
+		},
+		{
+			name: "timings",
+			cp:   csvPrinter{showTimings: true},
+			want: []string{"1.500", "2.250", "3.000"},
+		},
+		{
+			name: "timings and HTTP",
+			cp:   csvPrinter{showTimings: true, showHTTP: true},
+			want: []string{"1.500", "2.250", "3.000", "200", "4.000", "5.000", ""},
+		},
+		{
+			name: "HTTP without the DNS resolution",
+			cp:   csvPrinter{showHTTP: true},
+			want: []string{"2.250", "200", "4.000", "5.000", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.cp.detailsRecord(details))
+		})
+	}
+}
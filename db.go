@@ -7,68 +7,45 @@ import (
 	"os"
 	"strings"
 	"time"
-	"unicode"
 
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
 
 type database struct {
-	conn      *sqlite.Conn
-	dbPath    string
-	tableName string
+	conn          *sqlite.Conn
+	dbPath        string
+	sessionID     int64         // sessionID is the row of this run in the sessions table
+	pendingProbes []probeRecord // pendingProbes are written to the probes table in batches
+	lastFlush     time.Time
 }
 
+// probeRecord is a row of the probes table
+type probeRecord struct {
+	timestamp     time.Time
+	hostname      string
+	addr          string
+	sourceAddr    string
+	port          uint16
+	success       bool
+	rtt           float32
+	failureReason failureReason
+}
+
+const (
+	// probeBatchSize is the number of probes after which they are written to the database
+	probeBatchSize = 100
+	// probeFlushInterval is the longest time probes wait before being written to the database
+	probeFlushInterval = time.Second
+)
+
 const (
 	eventTypeStatistics     = "statistics"
 	eventTypeHostnameChange = "hostname change"
+	eventTypeOutage         = "outage"
 
-	tableSchema = `
-CREATE TABLE %s (
-    id INTEGER PRIMARY KEY,
-    event_type TEXT NOT NULL, -- for the data type eg. statistics, hostname change
-    timestamp DATETIME,
-    addr TEXT,
-    sourceAddr TEXT,
-    hostname TEXT,
-    port INTEGER,
-    hostname_resolve_retries INTEGER,
-
-    hostname_changed_to TEXT,
-    hostname_change_time DATETIME,
-
-    latency_min REAL,
-    latency_avg REAL,
-    latency_max REAL,
-
-	total_duration TEXT,
-    start_time DATETIME,
-    end_time DATETIME,
-
-	never_succeed_probe INTEGER, -- value will be 1 if a probe never succeeded
-	never_failed_probe INTEGER, -- value will be 1 if a probe never failed
-    last_successful_probe DATETIME,
-    last_unsuccessful_probe DATETIME,
-
-    longest_uptime TEXT,
-    longest_uptime_start DATETIME,
-    longest_uptime_end DATETIME,
-
-    longest_downtime TEXT,
-    longest_downtime_start DATETIME,
-    longest_downtime_end DATETIME,
-
-    total_packets INTEGER,
-    total_packet_loss REAL,
-    total_successful_probes INTEGER,
-    total_unsuccessful_probes INTEGER,
-
-    total_uptime TEXT,
-    total_downtime TEXT
-);`
-
-	// %s will be replaced by the table name
-	statSaveSchema = `INSERT INTO %s (
+	statSaveSchema = `INSERT INTO events (
+	session_id,
 	event_type,
 	timestamp,
 	addr,
@@ -78,6 +55,7 @@ CREATE TABLE %s (
 	hostname_resolve_retries,
 	total_successful_probes,
 	total_unsuccessful_probes,
+	failure_reasons,
 	never_succeed_probe,
 	never_failed_probe,
 	last_successful_probe,
@@ -92,46 +70,134 @@ CREATE TABLE %s (
 	longest_downtime,
 	longest_downtime_start,
 	longest_downtime_end,
+	outages,
+	mttr,
+	mtbf,
 	latency_min,
 	latency_avg,
 	latency_max,
+	latency_p50,
+	latency_p90,
+	latency_p95,
+	latency_p99,
+	latency_stddev,
+	latency_jitter,
 	start_time,
 	end_time,
-	total_duration) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	total_duration) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
 )
 
-// newDB creates a newDB with the given path and returns a pointer to the `database` struct
-func newDB(dbPath string, args []string) *database {
-	tableName := newTableName(args)
-	tableSchema := fmt.Sprintf(tableSchema, tableName)
-
+// newDB opens or creates the database with the given path,
+// starts a new session of the given targets in it and returns a pointer to the `database` struct
+func newDB(dbPath string, targets []string) *database {
 	conn, err := sqlite.OpenConn(dbPath, sqlite.OpenCreate, sqlite.OpenReadWrite)
 	if err != nil {
 		colorRed("\nError while creating the database %q: %s\n", dbPath, err)
 		os.Exit(1)
 	}
 
-	err = sqlitex.Execute(conn, tableSchema, &sqlitex.ExecOptions{})
+	err = migrateDB(conn)
+	if err != nil {
+		colorRed("\nError while migrating the database %q \nerr: %s\n", dbPath, err)
+		os.Exit(1)
+	}
+
+	err = sqlitex.Execute(conn, "INSERT INTO sessions (targets, start_time) VALUES (?, ?)", &sqlitex.ExecOptions{
+		Args: []interface{}{strings.Join(targets, " "), time.Now().Format(timeFormat)},
+	})
 	if err != nil {
-		colorRed("\nError writing to the database %q \nerr: %s\n", dbPath, err)
+		colorRed("\nError while starting a session in the database %q \nerr: %s\n", dbPath, err)
 		os.Exit(1)
 	}
-	return &database{conn, dbPath, tableName}
+
+	return &database{
+		conn:      conn,
+		dbPath:    dbPath,
+		sessionID: conn.LastInsertRowID(),
+		lastFlush: time.Now(),
+	}
+}
+
+// addProbe queues the probe and writes the queued ones
+// when there are enough of them or they waited long enough
+func (db *database) addProbe(probe probeRecord) {
+	db.pendingProbes = append(db.pendingProbes, probe)
+
+	if len(db.pendingProbes) < probeBatchSize && time.Since(db.lastFlush) < probeFlushInterval {
+		return
+	}
+
+	if err := db.flushProbes(); err != nil {
+		db.printError("\nError while writing probes to the database %q\nerr: %s", db.dbPath, err)
+	}
 }
 
-// newTableName will return correctly formatted table name
-// formatting the table name as "example_com_port_hour_minute_sec_day_month_year"
-// table name can't have '.','-' and can't start with numbers
-func newTableName(args []string) string {
-	sanitizedHost := strings.ReplaceAll(args[0], ".", "_")
-	sanitizedHost = strings.ReplaceAll(sanitizedHost, "-", "_")
-	tableName := fmt.Sprintf("%s_%s_%s", sanitizedHost, args[1], time.Now().Format("15_04_05_01_02_2006"))
+// flushProbes writes the queued probes in a single transaction,
+// so that frequent probes don't wait for a disk sync each.
+func (db *database) flushProbes() (err error) {
+	db.lastFlush = time.Now()
+	if len(db.pendingProbes) == 0 {
+		return nil
+	}
+
+	schema := `INSERT INTO probes
+	(session_id, timestamp, hostname, addr, sourceAddr, port, success, rtt, failure_reason)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	defer sqlitex.Transaction(db.conn)(&err)
+
+	for _, probe := range db.pendingProbes {
+		var rtt, reason interface{}
+		if probe.success {
+			// limited to 3 decimal places, like the latency stats
+			rtt = math.Round(float64(probe.rtt)*1000) / 1000
+		}
+		if probe.failureReason != "" {
+			reason = string(probe.failureReason)
+		}
 
-	if unicode.IsNumber(rune(tableName[0])) {
-		tableName = "_" + tableName
+		err = sqlitex.Execute(db.conn, schema, &sqlitex.ExecOptions{
+			Args: []interface{}{
+				db.sessionID,
+				probe.timestamp.Format(timeFormat),
+				probe.hostname,
+				probe.addr,
+				probe.sourceAddr,
+				probe.port,
+				probe.success,
+				rtt,
+				reason,
+			}})
+		if err != nil {
+			return err
+		}
 	}
 
-	return tableName
+	db.pendingProbes = db.pendingProbes[:0]
+
+	return nil
+}
+
+// close writes the queued probes, ends the session and closes the database
+func (db *database) close() error {
+	err := db.flushProbes()
+
+	if endErr := db.endSession(time.Now()); err == nil {
+		err = endErr
+	}
+
+	if closeErr := db.conn.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// endSession sets the end time of the session
+func (db *database) endSession(when time.Time) error {
+	return sqlitex.Execute(db.conn, "UPDATE sessions SET end_time = ? WHERE id = ?", &sqlitex.ExecOptions{
+		Args: []interface{}{when.Format(timeFormat), db.sessionID},
+	})
 }
 
 // saveStats saves stats to the database with proper formatting
@@ -175,6 +241,9 @@ func (db *database) saveStats(tcping tcping) error {
 		longestDowntimeEnd = tcping.longestDowntime.end.Format(timeFormat)
 	}
 
+	outages := tcping.allOutages()
+	outageSummary := summarizeOutages(outages, tcping.totalUptime)
+
 	var totalDuration string
 	if tcping.endTime.IsZero() {
 		totalDuration = time.Since(tcping.startTime).String()
@@ -186,6 +255,7 @@ func (db *database) saveStats(tcping tcping) error {
 	// other printers utilize printProbeSuccess which takes the net.Conn
 	// whereas DB is having its own way
 	args := []interface{}{
+		db.sessionID,
 		eventTypeStatistics,
 		time.Now().Format(timeFormat),
 		tcping.userInput.ip.String(),
@@ -195,6 +265,7 @@ func (db *database) saveStats(tcping tcping) error {
 		tcping.retriedHostnameLookups,
 		tcping.totalSuccessfulProbes,
 		tcping.totalUnsuccessfulProbes,
+		formatFailureReasons(tcping.failureReasons),
 		neverSucceedProbe,
 		neverFailedProbe,
 		lastSuccessfulProbe,
@@ -209,9 +280,18 @@ func (db *database) saveStats(tcping tcping) error {
 		longestDowntimeDuration,
 		longestDowntimeStart,
 		longestDowntimeEnd,
+		outageSummary.count,
+		outageSummary.mttr.String(),
+		outageSummary.mtbf.String(),
 		fmt.Sprintf("%.3f", tcping.rttResults.min),
 		fmt.Sprintf("%.3f", tcping.rttResults.average),
 		fmt.Sprintf("%.3f", tcping.rttResults.max),
+		fmt.Sprintf("%.3f", tcping.rttResults.p50),
+		fmt.Sprintf("%.3f", tcping.rttResults.p90),
+		fmt.Sprintf("%.3f", tcping.rttResults.p95),
+		fmt.Sprintf("%.3f", tcping.rttResults.p99),
+		fmt.Sprintf("%.3f", tcping.rttResults.stdDev),
+		fmt.Sprintf("%.3f", tcping.rttResults.jitter),
 		tcping.startTime.Format(timeFormat),
 		tcping.endTime.Format(timeFormat),
 		totalDuration,
@@ -219,25 +299,54 @@ func (db *database) saveStats(tcping tcping) error {
 
 	return sqlitex.Execute(
 		db.conn,
-		fmt.Sprintf(statSaveSchema, db.tableName),
+		statSaveSchema,
 		&sqlitex.ExecOptions{Args: args},
 	)
 }
 
 // saveHostNameChang saves the hostname changes
 // in multiple rows with event_type = eventTypeHostnameChange
-func (db *database) saveHostNameChange(h []hostnameChange) error {
-	// %s will be replaced by the table name
-	schema := `INSERT INTO %s
-	(event_type, hostname_changed_to, hostname_change_time)
-	VALUES (?, ?, ?)`
+func (db *database) saveHostNameChange(hostname string, port uint16, h []hostnameChange) error {
+	schema := `INSERT INTO events
+	(session_id, event_type, hostname, port, hostname_changed_to, hostname_change_time)
+	VALUES (?, ?, ?, ?, ?, ?)`
 
 	for _, host := range h {
-		if host.Addr.String() == "" {
+		changedTo := host.changedTo()
+		if changedTo == "" {
 			continue
 		}
-		err := sqlitex.Execute(db.conn, fmt.Sprintf(schema, db.tableName), &sqlitex.ExecOptions{
-			Args: []interface{}{eventTypeHostnameChange, host.Addr.String(), host.When.Format(timeFormat)}})
+		err := sqlitex.Execute(db.conn, schema, &sqlitex.ExecOptions{
+			Args: []interface{}{db.sessionID, eventTypeHostnameChange, hostname, port, changedTo, host.When.Format(timeFormat)}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveOutages saves every outage of the target
+// in multiple rows with event_type = eventTypeOutage
+func (db *database) saveOutages(tcping tcping) error {
+	schema := `INSERT INTO events
+	(session_id, event_type, hostname, port, addr, outage_start, outage_end, outage_duration, outage_failed_probes, outage_ongoing)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	for _, o := range tcping.allOutages() {
+		err := sqlitex.Execute(db.conn, schema, &sqlitex.ExecOptions{
+			Args: []interface{}{
+				db.sessionID,
+				eventTypeOutage,
+				tcping.userInput.hostname,
+				tcping.userInput.port,
+				o.ip.String(),
+				o.start.Format(timeFormat),
+				o.end.Format(timeFormat),
+				o.duration.String(),
+				o.failedProbes,
+				o.ongoing,
+			}})
 		if err != nil {
 			return err
 		}
@@ -255,21 +364,39 @@ func (db *database) printStart(hostname string, port uint16) {
 // printStatistics saves the statistics to the given database
 // calls stat.printer.printError() on err
 func (db *database) printStatistics(tcping tcping) {
-	err := db.saveStats(tcping)
+	err := db.flushProbes()
+	if err != nil {
+		db.printError("\nError while writing probes to the database %q\nerr: %s", db.dbPath, err)
+	}
+
+	err = db.saveStats(tcping)
 	if err != nil {
 		db.printError("\nError while writing stats to the database %q\nerr: %s", db.dbPath, err)
 	}
 
-	// Hostname changes should be written during the final call.
+	// Hostname changes and outages should be written during the final call.
 	// If the endTime is 0, it indicates that this is not the last call.
 	if !tcping.endTime.IsZero() {
-		err = db.saveHostNameChange(tcping.hostnameChanges)
+		err = db.saveHostNameChange(tcping.userInput.hostname, tcping.userInput.port, tcping.hostnameChanges)
 		if err != nil {
 			db.printError("\nError while writing hostname changes to the database %q\nerr: %s", db.dbPath, err)
 		}
+
+		err = db.saveOutages(tcping)
+		if err != nil {
+			db.printError("\nError while writing outages to the database %q\nerr: %s", db.dbPath, err)
+		}
+
+		// the changes of the target list of the SRV records are saved under their name
+		if changes := tcping.srvChanges(); len(changes) > 0 {
+			err = db.saveHostNameChange(tcping.srv.name, 0, changes)
+			if err != nil {
+				db.printError("\nError while writing SRV target changes to the database %q\nerr: %s", db.dbPath, err)
+			}
+		}
 	}
 
-	colorYellow("\nStatistics for %q have been saved to %q in the table %q\n", tcping.userInput.hostname, db.dbPath, db.tableName)
+	colorYellow("\nStatistics for %q have been saved to %q in the session %d\n", tcping.userInput.hostname, db.dbPath, db.sessionID)
 }
 
 // printError prints the err to the stderr and exits with status code 1
@@ -278,10 +405,42 @@ func (db *database) printError(format string, args ...any) {
 	os.Exit(1)
 }
 
+// printProbeSuccess queues the successful probe to be saved to the database,
+// even when it's hidden by --show-failures-only.
+// The probes of a previous IP address are left out, as they're not a part of the statistics.
+func (db *database) printProbeSuccess(sourceAddr string, userInput userInput, _ uint, rtt float32, details probeDetails) {
+	if userInput.drainingPreviousIP {
+		return
+	}
+
+	db.addProbe(probeRecord{
+		timestamp:  details.probeTime,
+		hostname:   userInput.hostname,
+		addr:       userInput.ip.String(),
+		sourceAddr: sourceAddr,
+		port:       userInput.port,
+		success:    true,
+		rtt:        rtt,
+	})
+}
+
+// printProbeFail queues the failed probe to be saved to the database
+func (db *database) printProbeFail(userInput userInput, _ uint, details probeDetails) {
+	if userInput.drainingPreviousIP {
+		return
+	}
+
+	db.addProbe(probeRecord{
+		timestamp:     details.probeTime,
+		hostname:      userInput.hostname,
+		addr:          userInput.ip.String(),
+		port:          userInput.port,
+		failureReason: details.failureReason,
+	})
+}
+
 // Satisfying the "printer" interface.
-func (db *database) printProbeSuccess(_ string, _ userInput, _ uint, _ float32) {}
-func (db *database) printProbeFail(_ userInput, _ uint)                         {}
-func (db *database) printRetryingToResolve(_ string)                            {}
-func (db *database) printTotalDownTime(_ time.Duration)                         {}
-func (db *database) printVersion()                                              {}
-func (db *database) printInfo(_ string, _ ...any)                               {}
+func (db *database) printRetryingToResolve(_, _ string)              {}
+func (db *database) printTotalDownTime(_ userInput, _ time.Duration) {}
+func (db *database) printVersion()                                   {}
+func (db *database) printInfo(_ string, _ ...any)                    {}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"math"
 	"net/netip"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,23 +19,152 @@ func TestNewDBTableCreation(t *testing.T) {
 	db := newDB(":memory:", arg)
 	defer db.conn.Close()
 
-	query := "SELECT name FROM sqlite_master WHERE type='table';"
+	var tables []string
+	query := "SELECT name FROM sqlite_master WHERE type='table' ORDER BY name;"
 	err := sqlitex.Execute(db.conn, query, &sqlitex.ExecOptions{
 		ResultFunc: func(stmt *sqlite.Stmt) error {
 			Equals(t, stmt.ColumnCount(), 1)
-			Equals(t, stmt.ColumnText(0), db.tableName)
+			tables = append(tables, stmt.ColumnText(0))
 			return nil
 		},
 	})
+	isNil(t, err)
+	Equals(t, strings.Join(tables, ","), "events,metadata,probes,sessions")
+
+	query = "SELECT id, targets, end_time FROM sessions;"
+	rows := 0
+	err = sqlitex.Execute(db.conn, query, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			rows++
+			Equals(t, stmt.ColumnInt64(0), db.sessionID)
+			Equals(t, stmt.ColumnText(1), "localhost 8001")
+			Equals(t, stmt.ColumnText(2), "")
+			return nil
+		},
+	})
+	isNil(t, err)
+	Equals(t, rows, 1)
+}
+
+func TestNewDBSessions(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tcping.db")
+
+	first := newDB(dbPath, []string{"localhost:8001"})
+	isNil(t, first.close())
+
+	// reopening the database should add a new session to the same tables
+	second := newDB(dbPath, []string{"example.com:443", "192.168.1.1:22"})
+	defer second.conn.Close()
+
+	Equals(t, second.sessionID, first.sessionID+1)
+
+	var sessions []string
+	query := "SELECT targets, end_time != '' FROM sessions ORDER BY id;"
+	err := sqlitex.Execute(second.conn, query, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			sessions = append(sessions, fmt.Sprintf("%s ended=%t", stmt.ColumnText(0), stmt.ColumnBool(1)))
+			return nil
+		},
+	})
+	isNil(t, err)
+	Equals(t, strings.Join(sessions, ","), "localhost:8001 ended=true,example.com:443 192.168.1.1:22 ended=false")
+}
 
+// countProbes returns the number of rows in the probes table
+func countProbes(t *testing.T, db *database) int {
+	t.Helper()
+
+	count := 0
+	err := sqlitex.Execute(db.conn, "SELECT COUNT(*) FROM probes;", &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			count = stmt.ColumnInt(0)
+			return nil
+		},
+	})
 	isNil(t, err)
+
+	return count
+}
+
+func TestDbSaveProbes(t *testing.T) {
+	arg := []string{"localhost", "8001"}
+	db := newDB(":memory:", arg)
+	defer db.conn.Close()
+
+	input := userInput{
+		hostname: "localhost",
+		ip:       netip.MustParseAddr("127.0.0.1"),
+		port:     8001,
+	}
+
+	db.printProbeSuccess("127.0.0.1:50000", input, 1, 1.2345, probeDetails{})
+	db.printProbeFail(input, 1, probeDetails{failureReason: failureRefused})
+
+	// the probes are batched, so they should not be written yet
+	Equals(t, countProbes(t, db), 0)
+	Equals(t, len(db.pendingProbes), 2)
+
+	isNil(t, db.flushProbes())
+	Equals(t, countProbes(t, db), 2)
+	Equals(t, len(db.pendingProbes), 0)
+
+	query := `SELECT session_id, hostname, addr, sourceAddr, port, success, rtt, failure_reason
+FROM probes ORDER BY id`
+
+	var rows [][]string
+	err := sqlitex.Execute(db.conn, query, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			Equals(t, stmt.ColumnInt64(0), db.sessionID)
+			var row []string
+			for i := 1; i < stmt.ColumnCount(); i++ {
+				row = append(row, stmt.ColumnText(i))
+			}
+			rows = append(rows, row)
+			return nil
+		},
+	})
+	isNil(t, err)
+
+	want := [][]string{
+		{"localhost", "127.0.0.1", "127.0.0.1:50000", "8001", "1", "1.235", ""},
+		{"localhost", "127.0.0.1", "", "8001", "0", "", "refused"},
+	}
+	Equals(t, len(rows), len(want))
+	for i := range want {
+		for j := range want[i] {
+			Equals(t, rows[i][j], want[i][j])
+		}
+	}
+}
+
+func TestDbProbeBatches(t *testing.T) {
+	arg := []string{"localhost", "8001"}
+	db := newDB(":memory:", arg)
+	defer db.conn.Close()
+
+	input := userInput{hostname: "localhost", ip: netip.MustParseAddr("127.0.0.1"), port: 8001}
+
+	// a full batch is written right away
+	for i := 1; i <= probeBatchSize; i++ {
+		db.printProbeSuccess("", input, uint(i), 1, probeDetails{})
+	}
+	Equals(t, len(db.pendingProbes), 0)
+	Equals(t, countProbes(t, db), probeBatchSize)
+
+	// probes waiting for too long are written along with the next one
+	db.printProbeSuccess("", input, 1, 1, probeDetails{})
+	Equals(t, len(db.pendingProbes), 1)
+
+	db.lastFlush = time.Now().Add(-probeFlushInterval)
+	db.printProbeFail(input, 1, probeDetails{})
+	Equals(t, len(db.pendingProbes), 0)
+	Equals(t, countProbes(t, db), probeBatchSize+2)
 }
 
 func TestDbSaveStats(t *testing.T) {
 	// There are many fields, so many things could go wrong; that's why this elaborate test.
 	arg := []string{"localhost", "8001"}
 	db := newDB(":memory:", arg)
-	t.Log(db.tableName)
 	defer db.conn.Close()
 
 	stat := mockStats()
@@ -68,7 +199,7 @@ latency_max,
 start_time,
 end_time,
 total_duration
-FROM ` + fmt.Sprintf("%s WHERE event_type = '%s'", db.tableName, eventTypeStatistics)
+FROM events WHERE event_type = '` + eventTypeStatistics + "'"
 
 	var (
 		addr, sourceAddr, hostname, port               string
@@ -205,28 +336,15 @@ func TestSaveHostname(t *testing.T) {
 	db := newDB(":memory:", arg)
 	defer db.conn.Close()
 
-	// Test if hostName is sanitized correctly
-	expectedTableName := fmt.Sprintf("%s_%s_%s", "local__host", "8001", time.Now().Format("15_04_05_01_02_2006"))
-	Equals(t, db.tableName, expectedTableName)
-
-	// Ensure the table is created correctly
-	dropQuery := fmt.Sprintf("DROP TABLE IF EXISTS %s;", db.tableName)
-	err := sqlitex.Execute(db.conn, dropQuery, nil)
-	isNil(t, err)
-
-	createQuery := fmt.Sprintf("CREATE TABLE %s (id INTEGER PRIMARY KEY, event_type TEXT NOT NULL, hostname_changed_to TEXT, hostname_change_time TEXT);", db.tableName)
-	err = sqlitex.Execute(db.conn, createQuery, nil)
-	isNil(t, err)
-
 	stat := mockStats()
 
-	err = db.saveHostNameChange(stat.hostnameChanges)
+	err := db.saveHostNameChange(stat.userInput.hostname, stat.userInput.port, stat.hostnameChanges)
 	isNil(t, err)
 
 	// testing the host names if they are properly written
 	query := `SELECT
-		hostname_changed_to, hostname_change_time
-		FROM ` + fmt.Sprintf("%s WHERE event_type IS '%s';", db.tableName, eventTypeHostnameChange)
+		hostname_changed_to, hostname_change_time, session_id, hostname, port
+		FROM ` + fmt.Sprintf("events WHERE event_type IS '%s';", eventTypeHostnameChange)
 
 	idx := 0
 	err = sqlitex.Execute(db.conn, query, &sqlitex.ExecOptions{
@@ -237,6 +355,9 @@ func TestSaveHostname(t *testing.T) {
 			idx++
 			Equals(t, hostName, actualHost.Addr.String())
 			Equals(t, cTime, actualHost.When.Format(timeFormat))
+			Equals(t, stmt.ColumnInt64(2), db.sessionID)
+			Equals(t, stmt.ColumnText(3), stat.userInput.hostname)
+			Equals(t, stmt.ColumnInt(4), int(stat.userInput.port))
 
 			return nil
 		}})
@@ -335,3 +456,155 @@ func toFixedFloat(input float32, precision int) float32 {
 	output := math.Pow(10, float64(precision))
 	return float32(float64(round(num*output)) / output)
 }
+
+func TestDbSaveLatencyPercentiles(t *testing.T) {
+	arg := []string{"localhost", "8001"}
+	db := newDB(":memory:", arg)
+	defer db.conn.Close()
+
+	stat := mockStats()
+	stat.rttResults.p50 = 3.1
+	stat.rttResults.p90 = 3.9
+	stat.rttResults.p95 = 4.01
+	stat.rttResults.p99 = 4.09
+	stat.rttResults.stdDev = 0.42
+	stat.rttResults.jitter = 0.123
+
+	err := db.saveStats(stat)
+	isNil(t, err)
+
+	query := fmt.Sprintf(`SELECT latency_p50, latency_p90, latency_p95, latency_p99, latency_stddev, latency_jitter
+FROM events WHERE event_type = '%s'`, eventTypeStatistics)
+
+	rows := 0
+	err = sqlitex.Execute(db.conn, query, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			rows++
+			Equals(t, float32(stmt.ColumnFloat(0)), stat.rttResults.p50)
+			Equals(t, float32(stmt.ColumnFloat(1)), stat.rttResults.p90)
+			Equals(t, float32(stmt.ColumnFloat(2)), stat.rttResults.p95)
+			Equals(t, float32(stmt.ColumnFloat(3)), stat.rttResults.p99)
+			Equals(t, float32(stmt.ColumnFloat(4)), stat.rttResults.stdDev)
+			Equals(t, float32(stmt.ColumnFloat(5)), stat.rttResults.jitter)
+			return nil
+		},
+	})
+	isNil(t, err)
+	Equals(t, rows, 1)
+}
+
+func TestDbSaveFailureReasons(t *testing.T) {
+	arg := []string{"localhost", "8001"}
+	db := newDB(":memory:", arg)
+	defer db.conn.Close()
+
+	stat := mockStats()
+	stat.failureReasons = map[failureReason]uint{failureRefused: 1, failureTimeout: 3}
+
+	err := db.saveStats(stat)
+	isNil(t, err)
+
+	query := fmt.Sprintf(`SELECT failure_reasons FROM events WHERE event_type = '%s'`, eventTypeStatistics)
+
+	rows := 0
+	err = sqlitex.Execute(db.conn, query, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			rows++
+			Equals(t, stmt.ColumnText(0), "timeout: 3, refused: 1")
+			return nil
+		},
+	})
+	isNil(t, err)
+	Equals(t, rows, 1)
+}
+
+func TestDbSaveOutages(t *testing.T) {
+	arg := []string{"localhost", "8001"}
+	db := newDB(":memory:", arg)
+	defer db.conn.Close()
+
+	now := time.Now()
+	stat := mockStats()
+	stat.endTime = now.Add(time.Minute)
+	stat.outages = []outage{
+		{start: now, end: now.Add(12 * time.Second), duration: 12 * time.Second, failedProbes: 3, ip: netip.MustParseAddr("10.0.0.1")},
+	}
+	stat.destWasDown = true
+	stat.currentOutage = outage{start: now.Add(30 * time.Second), failedProbes: 2, ip: netip.MustParseAddr("10.0.0.2")}
+
+	err := db.saveStats(stat)
+	isNil(t, err)
+	err = db.saveOutages(stat)
+	isNil(t, err)
+
+	query := fmt.Sprintf(`SELECT outages, mttr, mtbf FROM events WHERE event_type = '%s'`, eventTypeStatistics)
+	err = sqlitex.Execute(db.conn, query, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			Equals(t, stmt.ColumnInt(0), 2)
+			Equals(t, stmt.ColumnText(1), "21s")
+			Equals(t, stmt.ColumnText(2), "16s")
+			return nil
+		},
+	})
+	isNil(t, err)
+
+	query = fmt.Sprintf(`SELECT addr, outage_start, outage_end, outage_duration, outage_failed_probes, outage_ongoing
+FROM events WHERE event_type = '%s' ORDER BY id`, eventTypeOutage)
+
+	var rows [][]string
+	err = sqlitex.Execute(db.conn, query, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			var row []string
+			for i := 0; i < stmt.ColumnCount(); i++ {
+				row = append(row, stmt.ColumnText(i))
+			}
+			rows = append(rows, row)
+			return nil
+		},
+	})
+	isNil(t, err)
+
+	want := [][]string{
+		{"10.0.0.1", now.Format(timeFormat), now.Add(12 * time.Second).Format(timeFormat), "12s", "3", "0"},
+		{"10.0.0.2", now.Add(30 * time.Second).Format(timeFormat), stat.endTime.Format(timeFormat), "30s", "2", "1"},
+	}
+	Equals(t, len(rows), len(want))
+	for i := range want {
+		for j := range want[i] {
+			Equals(t, rows[i][j], want[i][j])
+		}
+	}
+}
+
+func TestDbSkipsDrainingProbes(t *testing.T) {
+	db := newDB(":memory:", []string{"localhost", "8001"})
+	defer db.conn.Close()
+
+	draining := userInput{hostname: "localhost", ip: netip.MustParseAddr("127.0.0.1"), port: 8001, drainingPreviousIP: true}
+	db.printProbeSuccess("127.0.0.1:4567", draining, 1, 1.5, probeDetails{})
+	db.printProbeFail(draining, 1, probeDetails{})
+	Equals(t, len(db.pendingProbes), 0)
+
+	draining.drainingPreviousIP = false
+	db.printProbeFail(draining, 1, probeDetails{})
+	Equals(t, len(db.pendingProbes), 1)
+}
+
+func TestDbSavesHiddenProbes(t *testing.T) {
+	db := newDB(":memory:", []string{"localhost", "8001"})
+	defer db.conn.Close()
+
+	stats := createTestStats(t)
+	stats.printer = db
+	stats.userInput.showFailuresOnly = true
+
+	// the probes are saved with the time they were sent, not the time they were queued
+	probeTime := time.Now().Add(-time.Second)
+	stats.handleConnSuccess("127.0.0.1:4567", 1.5, probeTime, time.Second, probeDetails{})
+	stats.handleConnError(probeTime.Add(time.Second), time.Second, probeDetails{failureReason: failureTimeout})
+
+	Equals(t, len(db.pendingProbes), 2)
+	Equals(t, db.pendingProbes[0].success, true)
+	Equals(t, db.pendingProbes[0].timestamp, probeTime)
+	Equals(t, db.pendingProbes[1].timestamp, probeTime.Add(time.Second))
+}
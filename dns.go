@@ -0,0 +1,228 @@
+// dns.go resolves the hostnames through a custom DNS server, DNS-over-TLS or DNS-over-HTTPS
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// dohContentType is the media type of the DNS messages of DNS-over-HTTPS, see RFC 8484
+	dohContentType = "application/dns-message"
+	// dnsMaxMessageSize is the largest DNS message that fits in its length prefix over TCP
+	dnsMaxMessageSize = 65535
+)
+
+// dnsResolver resolves the hostnames through the DNS server chosen by the user.
+//
+// The lookups are done by the pure Go resolver of the standard library,
+// which only needs to be handed a connection to the server.
+// DNS-over-TLS and DNS-over-HTTPS connections look like TCP ones to it,
+// so every message is prefixed by its length, as in RFC 1035 section 4.2.2.
+type dnsResolver struct {
+	name       string // name is the URL of the server, shown in the output
+	network    string // network is one of udp, tcp, tls or https
+	address    string // address is the host:port of the server, unless it's DNS-over-HTTPS
+	url        string // url is the endpoint of the DNS-over-HTTPS server
+	timeout    time.Duration
+	tlsConfig  *tls.Config  // tlsConfig verifies the DNS-over-TLS server
+	httpClient *http.Client // httpClient sends the DNS-over-HTTPS queries
+	resolver   *net.Resolver
+}
+
+// newDNSResolver parses the DNS server given by the user, such as
+// 1.1.1.1, tcp://1.1.1.1:53, tls://1.1.1.1 or https://1.1.1.1/dns-query.
+// The plain ones use UDP, falling back to TCP for truncated replies.
+func newDNSResolver(server string, timeout time.Duration) (*dnsResolver, error) {
+	if timeout <= 0 {
+		return nil, errors.New("the DNS timeout should be more than 0")
+	}
+
+	if addr, err := netip.ParseAddr(server); err == nil {
+		// an IPv6 address needs brackets to be parsed as a URL
+		server = net.JoinHostPort(addr.String(), "53")
+	}
+
+	if !strings.Contains(server, "://") {
+		server = "udp://" + server
+	}
+
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS server %q: %w", server, err)
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid DNS server %q: missing host", server)
+	}
+
+	r := &dnsResolver{network: u.Scheme, timeout: timeout}
+
+	switch u.Scheme {
+	case "udp", "tcp", "tls":
+		if u.Path != "" && u.Path != "/" {
+			return nil, fmt.Errorf("invalid DNS server %q: only DNS-over-HTTPS servers have a path", server)
+		}
+
+		port := u.Port()
+		if port == "" {
+			port = "53"
+			if u.Scheme == "tls" {
+				port = "853"
+			}
+		}
+
+		r.address = net.JoinHostPort(u.Hostname(), port)
+		r.name = u.Scheme + "://" + r.address
+		r.tlsConfig = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+
+	case "https":
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+
+		r.url = u.String()
+		r.name = r.url
+		r.httpClient = &http.Client{Timeout: timeout}
+
+	default:
+		return nil, fmt.Errorf("invalid DNS server %q: the scheme should be udp, tcp, tls or https", server)
+	}
+
+	r.resolver = &net.Resolver{PreferGo: true, Dial: r.dial}
+
+	return r, nil
+}
+
+// dnsLookupTimeout returns the time to wait for the DNS server given by the user,
+// or for the system resolver.
+func dnsLookupTimeout(r *dnsResolver) time.Duration {
+	if r == nil {
+		return systemDNSTimeout
+	}
+	return r.timeout
+}
+
+// dial connects to the DNS server, whatever the resolver asked for
+func (r *dnsResolver) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	var dialer net.Dialer
+
+	switch r.network {
+	case "udp":
+		// the resolver retries truncated replies over TCP
+		return dialer.DialContext(ctx, network, r.address)
+	case "tcp":
+		return dialer.DialContext(ctx, "tcp", r.address)
+	case "tls":
+		tlsDialer := tls.Dialer{NetDialer: &dialer, Config: r.tlsConfig}
+		return tlsDialer.DialContext(ctx, "tcp", r.address)
+	default:
+		return &dohConn{ctx: ctx, client: r.httpClient, url: r.url}, nil
+	}
+}
+
+// lookupNetIP resolves the hostname to its IPv4 and IPv6 addresses
+func (r *dnsResolver) lookupNetIP(ctx context.Context, hostname string) ([]netip.Addr, error) {
+	addrs, err := r.resolver.LookupNetIP(ctx, "ip", hostname)
+	r.nameServer(err)
+
+	return addrs, err
+}
+
+// nameServer makes the lookup error name the DNS server,
+// instead of the servers of resolv.conf, which were never asked.
+func (r *dnsResolver) nameServer(err error) {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		dnsErr.Server = r.name
+	}
+}
+
+// dohConn sends the DNS messages written by the resolver over HTTPS
+// and hands the replies back, as if it were a TCP connection.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	url      string
+	query    bytes.Buffer
+	response bytes.Reader
+}
+
+// Write buffers the length-prefixed DNS query
+func (c *dohConn) Write(b []byte) (int, error) {
+	return c.query.Write(b)
+}
+
+// Read sends the buffered query, if any, and reads its length-prefixed reply
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.response.Len() == 0 && c.query.Len() > 0 {
+		if err := c.roundTrip(); err != nil {
+			return 0, err
+		}
+	}
+
+	return c.response.Read(b)
+}
+
+// roundTrip posts the buffered query to the server, see RFC 8484 section 4.1
+func (c *dohConn) roundTrip() error {
+	framed := c.query.Bytes()
+	if len(framed) < 2 || int(binary.BigEndian.Uint16(framed)) != len(framed)-2 {
+		return errors.New("incomplete DNS query")
+	}
+	c.query.Reset()
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url, bytes.NewReader(framed[2:]))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DNS-over-HTTPS server replied with %s", resp.Status)
+	}
+
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, dnsMaxMessageSize+1))
+	if err != nil {
+		return err
+	}
+	if len(reply) > dnsMaxMessageSize {
+		return errors.New("DNS-over-HTTPS reply is too large")
+	}
+
+	framedReply := binary.BigEndian.AppendUint16(make([]byte, 0, len(reply)+2), uint16(len(reply)))
+	c.response.Reset(append(framedReply, reply...))
+
+	return nil
+}
+
+func (c *dohConn) Close() error                     { return nil }
+func (c *dohConn) LocalAddr() net.Addr              { return dohAddr(c.url) }
+func (c *dohConn) RemoteAddr() net.Addr             { return dohAddr(c.url) }
+func (c *dohConn) SetDeadline(time.Time) error      { return nil }
+func (c *dohConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(time.Time) error { return nil }
+
+// dohAddr is the address of a DNS-over-HTTPS server
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testDNSAddr is the address the test DNS servers answer with
+var testDNSAddr = netip.MustParseAddr("192.0.2.1")
+
+// dnsAnswer replies to a DNS query with testDNSAddr for A records
+// and no records for anything else.
+func dnsAnswer(query []byte) []byte {
+	return dnsReply(query, []netip.Addr{testDNSAddr}, 60)
+}
+
+// dnsReply replies to a DNS query with the IPv4 addresses as A records,
+// all with the given TTL, and no records for anything else.
+func dnsReply(query []byte, addrs []netip.Addr, ttl uint32) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// the question ends after the labels of the name, the type and the class
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5
+	if end > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[end-4:])
+
+	var answers uint16
+	if qtype == dnsTypeA {
+		answers = uint16(len(addrs))
+	}
+
+	reply := append([]byte{}, query[:2]...) // ID
+	reply = append(reply, 0x81, 0x80)       // a recursive reply with no error
+	reply = append(reply, 0, 1, byte(answers>>8), byte(answers), 0, 0, 0, 0)
+	reply = append(reply, query[12:end]...) // the question
+	for i := range answers {
+		reply = append(reply, 0xc0, 12)   // a pointer to the name of the question
+		reply = append(reply, 0, 1, 0, 1) // A and IN
+		reply = binary.BigEndian.AppendUint32(reply, ttl)
+		reply = append(reply, 0, 4)
+		reply = append(reply, addrs[i].AsSlice()...)
+	}
+
+	return reply
+}
+
+// dnsServeStream answers the length-prefixed DNS queries of a TCP or TLS connection
+func dnsServeStream(conn net.Conn, queries *atomic.Int32, answer func(query []byte) []byte) {
+	defer conn.Close()
+
+	for {
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+
+		query := make([]byte, length)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		queries.Add(1)
+
+		reply := answer(query)
+		if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(reply)))); err != nil {
+			return
+		}
+		if _, err := conn.Write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// dnsServeListener answers the DNS queries of every connection of the listener
+func dnsServeListener(t *testing.T, ln net.Listener, queries *atomic.Int32, answer func(query []byte) []byte) {
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go dnsServeStream(conn, queries, answer)
+		}
+	}()
+}
+
+// dnsServeUDP answers the DNS queries sent over UDP and returns the address of the server
+func dnsServeUDP(t *testing.T, queries *atomic.Int32, answer func(query []byte) []byte) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, dnsMaxMessageSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			queries.Add(1)
+			_, _ = conn.WriteTo(answer(buf[:n]), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestNewDNSResolver(t *testing.T) {
+	tests := []struct {
+		server  string
+		network string
+		name    string
+	}{
+		{server: "1.1.1.1", network: "udp", name: "udp://1.1.1.1:53"},
+		{server: "1.1.1.1:5353", network: "udp", name: "udp://1.1.1.1:5353"},
+		{server: "2606:4700::1111", network: "udp", name: "udp://[2606:4700::1111]:53"},
+		{server: "tcp://1.1.1.1", network: "tcp", name: "tcp://1.1.1.1:53"},
+		{server: "tls://one.one.one.one", network: "tls", name: "tls://one.one.one.one:853"},
+		{server: "https://cloudflare-dns.com", network: "https", name: "https://cloudflare-dns.com/dns-query"},
+		{server: "https://dns.google/resolve", network: "https", name: "https://dns.google/resolve"},
+	}
+
+	for _, tt := range tests {
+		r, err := newDNSResolver(tt.server, time.Second)
+		assert.NoError(t, err, tt.server)
+		assert.Equal(t, tt.network, r.network, tt.server)
+		assert.Equal(t, tt.name, r.name, tt.server)
+	}
+
+	for _, server := range []string{"ftp://1.1.1.1", "udp://", "tcp://1.1.1.1/dns-query", "%zz"} {
+		_, err := newDNSResolver(server, time.Second)
+		assert.Error(t, err, server)
+	}
+
+	_, err := newDNSResolver("1.1.1.1", 0)
+	assert.Error(t, err)
+}
+
+func TestDNSResolverLookup(t *testing.T) {
+	// resolve looks up the hostname of a target through the resolver
+	resolve := func(t *testing.T, r *dnsResolver) netip.Addr {
+		stats := createTestStats(t)
+		stats.userInput.hostname = "tcping.test"
+		stats.userInput.useIPv4 = true
+		stats.userInput.resolver = r
+		return resolveHostname(stats)
+	}
+
+	t.Run("udp", func(t *testing.T) {
+		var queries atomic.Int32
+		r, err := newDNSResolver(dnsServeUDP(t, &queries, dnsAnswer), time.Second)
+		assert.NoError(t, err)
+
+		assert.Equal(t, testDNSAddr, resolve(t, r))
+		assert.Positive(t, queries.Load())
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		var queries atomic.Int32
+		dnsServeListener(t, ln, &queries, dnsAnswer)
+
+		r, err := newDNSResolver("tcp://"+ln.Addr().String(), time.Second)
+		assert.NoError(t, err)
+
+		assert.Equal(t, testDNSAddr, resolve(t, r))
+		assert.Positive(t, queries.Load())
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		// the test server of httptest has a certificate for 127.0.0.1
+		srv := httptest.NewTLSServer(http.NotFoundHandler())
+		t.Cleanup(srv.Close)
+
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: srv.TLS.Certificates})
+		assert.NoError(t, err)
+
+		var queries atomic.Int32
+		dnsServeListener(t, ln, &queries, dnsAnswer)
+
+		r, err := newDNSResolver("tls://"+ln.Addr().String(), time.Second)
+		assert.NoError(t, err)
+
+		roots := x509.NewCertPool()
+		roots.AddCert(srv.Certificate())
+		r.tlsConfig.RootCAs = roots
+
+		assert.Equal(t, testDNSAddr, resolve(t, r))
+		assert.Positive(t, queries.Load())
+	})
+
+	t.Run("https", func(t *testing.T) {
+		var queries atomic.Int32
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dohContentType {
+				http.Error(w, "unexpected request", http.StatusBadRequest)
+				return
+			}
+
+			query, _ := io.ReadAll(r.Body)
+			queries.Add(1)
+
+			w.Header().Set("Content-Type", dohContentType)
+			_, _ = w.Write(dnsAnswer(query))
+		}))
+		t.Cleanup(srv.Close)
+
+		r, err := newDNSResolver(srv.URL, time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, srv.URL+"/dns-query", r.name)
+		r.httpClient = srv.Client()
+
+		assert.Equal(t, testDNSAddr, resolve(t, r))
+		assert.Positive(t, queries.Load())
+	})
+
+	t.Run("https error", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(srv.Close)
+
+		r, err := newDNSResolver(srv.URL, time.Second)
+		assert.NoError(t, err)
+		r.httpClient = srv.Client()
+
+		_, err = r.lookupNetIP(t.Context(), "tcping.test")
+		assert.ErrorContains(t, err, "lookup tcping.test on "+r.name, "the error names the DNS server")
+	})
+}
+
+func TestDNSLookupTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { systemDNSTimeout = timeout }(systemDNSTimeout)
+	systemDNSTimeout = 5 * time.Second
+
+	r, err := newDNSResolver("1.1.1.1", time.Second)
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.Second, dnsLookupTimeout(r), "the timeout of the DNS server given by the user")
+	assert.Equal(t, 5*time.Second, dnsLookupTimeout(nil), "the timeout of the system resolver")
+}
+
+func TestRetryResolveHostnameFailure(t *testing.T) {
+	// every lookup fails with NXDOMAIN
+	var queries atomic.Int32
+	r, err := newDNSResolver(dnsServeUDP(t, &queries, func(query []byte) []byte {
+		reply := dnsReply(query, nil, 0)
+		reply[3] = 0x83
+		return reply
+	}), time.Second)
+	assert.NoError(t, err)
+
+	stats := createTestStats(t)
+	stats.userInput.hostname = "tcping.test"
+	stats.userInput.resolver = r
+	stats.userInput.retryHostnameLookupAfter = 1
+	stats.hostnameChanges = []hostnameChange{{Addr: stats.userInput.ip, When: time.Now()}}
+	ip := stats.userInput.ip
+
+	stats.handleConnError(time.Now(), time.Second, probeDetails{failureReason: failureRefused})
+	retryResolveHostname(stats)
+
+	assert.Positive(t, queries.Load())
+	assert.Equal(t, ip, stats.userInput.ip, "the current IP is kept")
+	assert.Equal(t, map[failureReason]uint{failureRefused: 1, failureDNS: 1}, stats.failureReasons)
+	assert.Equal(t, uint(2), stats.totalUnsuccessfulProbes, "the failed lookup is counted as a failed probe")
+	assert.Equal(t, uint(1), stats.retriedHostnameLookups)
+}
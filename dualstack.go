@@ -0,0 +1,218 @@
+// dualstack.go compares the IPv4 and IPv6 addresses of a hostname,
+// either side by side or by racing them as in Happy Eyeballs
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// connectionAttemptDelay is the time to wait for a connection attempt
+// before starting the next one, as recommended by RFC 8305 section 8
+const connectionAttemptDelay = 250 * time.Millisecond
+
+// raceDetails holds the outcome of a Happy Eyeballs race
+type raceDetails struct {
+	// addr is the address of the connection that won the race,
+	// and is invalid when every attempt has failed.
+	addr netip.Addr
+	// attempts is the number of connection attempts started.
+	attempts int
+}
+
+// addressFamily returns "IPv4" or "IPv6"
+func addressFamily(addr netip.Addr) string {
+	if addr.Unmap().Is4() {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+// expandFamilies returns a target for an IPv4 and an IPv6 address of the hostname,
+// which keep their own statistics. Each of them resolves the hostname again within
+// its family, e.g. with -r. IP addresses are returned as they are.
+// It fails if the hostname lacks the addresses of either family.
+func expandFamilies(t *tcping) ([]*tcping, error) {
+	if t.destIsIP {
+		return []*tcping{t}, nil
+	}
+
+	group := make([]*tcping, 0, 2)
+	for _, useIPv4 := range []bool{true, false} {
+		family := *t
+		family.userInput.useIPv4 = useIPv4
+		family.userInput.useIPv6 = !useIPv4
+		family.userInput.dualStack = true
+
+		records := filterResolvedIPs(&family, t.records)
+		if len(records) == 0 {
+			missing := "AAAA"
+			if useIPv4 {
+				missing = "A"
+			}
+			return nil, fmt.Errorf("'--dual-stack' needs both A and AAAA records, but %s has no %s record", t.userInput.hostname, missing)
+		}
+
+		group = append(group, newAddressTarget(&family, records[rand.Intn(len(records))], records))
+	}
+
+	for _, a := range group {
+		a.addressGroup = group
+	}
+
+	t.printInfo("Probing %s over IPv4 %s and IPv6 %s", t.userInput.hostname, group[0].userInput.ip, group[1].userInput.ip)
+
+	return group, nil
+}
+
+// formatFamilyComparison returns how IPv6 fares against IPv4 in dual-stack mode,
+// or an empty string if the summaries are not the ones of both families.
+func formatFamilyComparison(summaries []addressSummary) string {
+	if len(summaries) != 2 || summaries[0].family != "IPv4" || summaries[1].family != "IPv6" {
+		return ""
+	}
+	ipv4, ipv6 := summaries[0], summaries[1]
+
+	line := fmt.Sprintf("IPv6 vs IPv4: %+.2f%% packet loss", ipv6.packetLoss-ipv4.packetLoss)
+	if ipv4.rttResults.hasResults && ipv6.rttResults.hasResults {
+		line += fmt.Sprintf(", %+.3f ms avg rtt", ipv6.rttResults.average-ipv4.rttResults.average)
+	}
+
+	return line
+}
+
+// formatRaceWins returns the number of races won by each family, e.g. "IPv6: 8, IPv4: 2"
+func formatRaceWins(wins map[string]uint) string {
+	var parts []string
+
+	for _, family := range []string{"IPv6", "IPv4"} {
+		if count := wins[family]; count > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", family, count))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// interleaveFamilies orders the addresses for a Happy Eyeballs race,
+// alternating between the families, starting with IPv6, see RFC 8305 section 4.
+func interleaveFamilies(addrs []netip.Addr) []netip.Addr {
+	var ipv4, ipv6 []netip.Addr
+	for _, addr := range addrs {
+		if addr.Unmap().Is4() {
+			ipv4 = append(ipv4, addr)
+		} else {
+			ipv6 = append(ipv6, addr)
+		}
+	}
+
+	ordered := make([]netip.Addr, 0, len(addrs))
+	for i := range max(len(ipv4), len(ipv6)) {
+		if i < len(ipv6) {
+			ordered = append(ordered, ipv6[i])
+		}
+		if i < len(ipv4) {
+			ordered = append(ordered, ipv4[i])
+		}
+	}
+
+	return ordered
+}
+
+// attemptResult is the outcome of one of the connection attempts of a race
+type attemptResult struct {
+	conn net.Conn
+	addr netip.Addr
+	err  error
+}
+
+// happyEyeballsDial races connections to the addresses as in RFC 8305 section 5.
+// A new attempt starts every connectionAttemptDelay, or as soon as the previous one fails,
+// and the first established connection wins. The losing connections are closed.
+func happyEyeballsDial(addrs []netip.Addr, port uint16, timeout time.Duration) (net.Conn, raceDetails, error) {
+	var details raceDetails
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
+
+	// the pending attempts are canceled once the race is over
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := interleaveFamilies(addrs)
+	if len(queue) == 0 {
+		return nil, details, errors.New("no address to connect to")
+	}
+
+	results := make(chan attemptResult, len(queue))
+	pending := 0
+
+	start := func() {
+		addr := queue[0]
+		queue = queue[1:]
+		pending++
+		details.attempts++
+
+		go func() {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "tcp", netip.AddrPortFrom(addr, port).String())
+			results <- attemptResult{conn: conn, addr: addr, err: err}
+		}()
+	}
+
+	start()
+	delay := time.NewTimer(connectionAttemptDelay)
+	defer delay.Stop()
+
+	var err error
+	for pending > 0 {
+		// no more attempts to start, only waiting for the pending ones
+		var next <-chan time.Time
+		if len(queue) > 0 {
+			next = delay.C
+		}
+
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				details.addr = result.addr
+				go closeLosers(results, pending)
+
+				return result.conn, details, nil
+			}
+
+			// a failed attempt doesn't wait for the delay to start the next one
+			err = result.err
+			if len(queue) > 0 {
+				start()
+				delay.Reset(connectionAttemptDelay)
+			}
+
+		case <-next:
+			start()
+			delay.Reset(connectionAttemptDelay)
+		}
+	}
+
+	return nil, details, err
+}
+
+// closeLosers closes the connections of the attempts which lost the race
+func closeLosers(results <-chan attemptResult, pending int) {
+	for range pending {
+		if result := <-results; result.conn != nil {
+			result.conn.Close()
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressFamily(t *testing.T) {
+	assert.Equal(t, "IPv4", addressFamily(netip.MustParseAddr("192.0.2.1")))
+	assert.Equal(t, "IPv4", addressFamily(netip.MustParseAddr("::ffff:192.0.2.1")))
+	assert.Equal(t, "IPv6", addressFamily(netip.MustParseAddr("2001:db8::1")))
+}
+
+func TestInterleaveFamilies(t *testing.T) {
+	addrs := []netip.Addr{
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("192.0.2.2"),
+		netip.MustParseAddr("192.0.2.3"),
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("2001:db8::2"),
+	}
+
+	assert.Equal(t, []netip.Addr{
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("2001:db8::2"),
+		netip.MustParseAddr("192.0.2.2"),
+		netip.MustParseAddr("192.0.2.3"),
+	}, interleaveFamilies(addrs))
+
+	assert.Equal(t, addrs[:2], interleaveFamilies(addrs[:2]), "a single family keeps its order")
+	assert.Empty(t, interleaveFamilies(nil))
+}
+
+func TestExpandFamilies(t *testing.T) {
+	printer := &infoRecorder{}
+	stats := createTestStats(t)
+	stats.printer = printer
+	stats.userInput.hostname = "tcping.test"
+	stats.records = []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2")}
+	stats.hostnameChanges = []hostnameChange{{Addr: netip.MustParseAddr("2001:db8::2"), When: time.Now(), Records: stats.records}}
+
+	expanded, err := expandFamilies(stats)
+	assert.NoError(t, err)
+	assert.Len(t, expanded, 2)
+
+	ipv4, ipv6 := expanded[0], expanded[1]
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), ipv4.userInput.ip)
+	assert.Equal(t, stats.records[:1], ipv4.records)
+	assert.True(t, ipv4.userInput.useIPv4)
+	assert.False(t, ipv4.userInput.useIPv6)
+
+	assert.Contains(t, stats.records[1:], ipv6.userInput.ip)
+	assert.Equal(t, stats.records[1:], ipv6.records, "re-resolving stays within the family")
+	assert.True(t, ipv6.userInput.useIPv6)
+	assert.False(t, ipv6.userInput.useIPv4)
+
+	for _, a := range expanded {
+		assert.True(t, a.userInput.dualStack)
+		assert.Equal(t, expanded, a.addressGroup)
+		assert.Equal(t, a.userInput.ip, a.hostnameChanges[0].Addr)
+	}
+
+	assert.Equal(t, []string{"Probing tcping.test over IPv4 192.0.2.1 and IPv6 " + ipv6.userInput.ip.String()}, printer.messages)
+
+	ip := createTestStats(t)
+	ip.destIsIP = true
+	expanded, err = expandFamilies(ip)
+	assert.NoError(t, err)
+	assert.Equal(t, []*tcping{ip}, expanded)
+
+	ipv4Only := createTestStats(t)
+	ipv4Only.userInput.hostname = "tcping.test"
+	ipv4Only.records = []netip.Addr{netip.MustParseAddr("192.0.2.1")}
+	ipv4Only.hostnameChanges = []hostnameChange{{Addr: ipv4Only.records[0], When: time.Now(), Records: ipv4Only.records}}
+	_, err = expandFamilies(ipv4Only)
+	assert.EqualError(t, err, "'--dual-stack' needs both A and AAAA records, but tcping.test has no AAAA record")
+
+	ipv6Only := createTestStats(t)
+	ipv6Only.userInput.hostname = "tcping.test"
+	ipv6Only.records = []netip.Addr{netip.MustParseAddr("2001:db8::1")}
+	ipv6Only.hostnameChanges = []hostnameChange{{Addr: ipv6Only.records[0], When: time.Now(), Records: ipv6Only.records}}
+	_, err = expandFamilies(ipv6Only)
+	assert.EqualError(t, err, "'--dual-stack' needs both A and AAAA records, but tcping.test has no A record")
+}
+
+func TestFormatFamilyComparison(t *testing.T) {
+	ipv4 := addressSummary{addr: netip.MustParseAddr("192.0.2.1"), family: "IPv4", packetLoss: 1,
+		rttResults: rttResult{average: 10, hasResults: true}}
+	ipv6 := addressSummary{addr: netip.MustParseAddr("2001:db8::1"), family: "IPv6", packetLoss: 3.5,
+		rttResults: rttResult{average: 12.5, hasResults: true}}
+
+	assert.Equal(t, "IPv6 vs IPv4: +2.50% packet loss, +2.500 ms avg rtt", formatFamilyComparison([]addressSummary{ipv4, ipv6}))
+
+	ipv6.packetLoss = 0
+	ipv6.rttResults = rttResult{}
+	assert.Equal(t, "IPv6 vs IPv4: -1.00% packet loss", formatFamilyComparison([]addressSummary{ipv4, ipv6}))
+
+	ipv4.family, ipv6.family = "", ""
+	assert.Empty(t, formatFamilyComparison([]addressSummary{ipv4, ipv6}), "only in dual-stack mode")
+
+	assert.Equal(t, "IPv4 192.0.2.1", addressSummary{addr: ipv4.addr, family: "IPv4"}.label())
+	assert.Equal(t, "--- tcping.test per-family summary on port 443 ---",
+		addressSummaryTitle(tcping{
+			userInput:        userInput{hostname: "tcping.test", port: 443},
+			addressSummaries: []addressSummary{{family: "IPv4"}},
+		}))
+}
+
+func TestFormatRaceWins(t *testing.T) {
+	assert.Equal(t, "IPv6: 8, IPv4: 2", formatRaceWins(map[string]uint{"IPv4": 2, "IPv6": 8}))
+	assert.Equal(t, "IPv4: 1", formatRaceWins(map[string]uint{"IPv4": 1}))
+	assert.Empty(t, formatRaceWins(nil))
+}
+
+func TestHappyEyeballsDial(t *testing.T) {
+	ipv4 := netip.MustParseAddr("127.0.0.1")
+	ipv6 := netip.MustParseAddr("::1")
+
+	// a listener of both families, when the system has IPv6
+	ln, err := net.Listen("tcp", ":0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+
+	t.Run("ipv4 only", func(t *testing.T) {
+		conn, race, err := happyEyeballsDial([]netip.Addr{ipv4}, port, time.Second)
+		assert.NoError(t, err)
+		conn.Close()
+		assert.Equal(t, raceDetails{addr: ipv4, attempts: 1}, race)
+	})
+
+	t.Run("ipv6 first", func(t *testing.T) {
+		if conn, err := net.Dial("tcp", netip.AddrPortFrom(ipv6, port).String()); err != nil {
+			t.Skip("no IPv6 on this system")
+		} else {
+			conn.Close()
+		}
+
+		conn, race, err := happyEyeballsDial([]netip.Addr{ipv4, ipv6}, port, time.Second)
+		assert.NoError(t, err)
+		conn.Close()
+		assert.Equal(t, raceDetails{addr: ipv6, attempts: 1}, race, "IPv6 wins before IPv4 is tried")
+	})
+
+	// a closed port refuses every attempt
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closedPort := uint16(closed.Addr().(*net.TCPAddr).Port)
+	closed.Close()
+
+	t.Run("refused", func(t *testing.T) {
+		start := time.Now()
+		_, race, err := happyEyeballsDial([]netip.Addr{ipv4, ipv4.Next()}, closedPort, time.Second)
+		assert.Error(t, err)
+		assert.Equal(t, failureRefused, classifyProbeFailure(err, probeDetails{}))
+		assert.False(t, race.addr.IsValid())
+		assert.Equal(t, 2, race.attempts)
+		assert.Less(t, time.Since(start), connectionAttemptDelay, "failed attempts don't wait for the delay")
+	})
+
+	t.Run("no address", func(t *testing.T) {
+		_, _, err := happyEyeballsDial(nil, port, time.Second)
+		assert.Error(t, err)
+	})
+}
+
+func TestHappyEyeballsProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	stats := createTestStats(t)
+	stats.ticker = time.NewTicker(time.Millisecond)
+	stats.userInput.port = uint16(ln.Addr().(*net.TCPAddr).Port)
+	stats.userInput.happyEyeballs = true
+	stats.records = []netip.Addr{netip.MustParseAddr("127.0.0.1")}
+
+	tcpProbe(stats)
+	tcpProbe(stats)
+
+	assert.Equal(t, uint(2), stats.totalSuccessfulProbes)
+	assert.Equal(t, map[string]uint{"IPv4": 2}, stats.raceWins)
+}
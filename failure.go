@@ -0,0 +1,118 @@
+// failure.go classifies the reasons of the failed probes
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// failureReason is the category of the reason a probe failed.
+type failureReason string
+
+const (
+	failureTimeout            failureReason = "timeout"
+	failureRefused            failureReason = "refused"
+	failureHostUnreachable    failureReason = "host_unreachable"
+	failureNetworkUnreachable failureReason = "network_unreachable"
+	failureDNS                failureReason = "dns_failure"
+	failureLocalBind          failureReason = "local_bind_error"
+	failureTooManyOpenFiles   failureReason = "too_many_open_files"
+	failureTLS                failureReason = "tls_error"
+	failureHTTP               failureReason = "http_error"
+	failureOther              failureReason = "other"
+)
+
+// failureReasonOrder is the order the failure reasons are printed in.
+var failureReasonOrder = []failureReason{
+	failureTimeout,
+	failureRefused,
+	failureHostUnreachable,
+	failureNetworkUnreachable,
+	failureDNS,
+	failureLocalBind,
+	failureTooManyOpenFiles,
+	failureTLS,
+	failureHTTP,
+	failureOther,
+}
+
+// classifyFailure returns the category of the error of a failed probe.
+func classifyFailure(err error) failureReason {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &dnsErr):
+		return failureDNS
+	case isErrno(err, refusedErrnos):
+		return failureRefused
+	case isErrno(err, hostUnreachableErrnos):
+		return failureHostUnreachable
+	case isErrno(err, networkUnreachableErrnos):
+		return failureNetworkUnreachable
+	case isErrno(err, tooManyOpenFilesErrnos):
+		return failureTooManyOpenFiles
+	case isErrno(err, localBindErrnos):
+		return failureLocalBind
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return failureTimeout
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "bind" {
+		return failureLocalBind
+	}
+
+	return failureOther
+}
+
+// isErrno reports whether the error is one of the errnos
+func isErrno(err error, errnos []syscall.Errno) bool {
+	for _, errno := range errnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// classifyProbeFailure returns the category of the error of a failed probe,
+// falling back to the layer that failed after connecting.
+func classifyProbeFailure(err error, details probeDetails) failureReason {
+	reason := classifyFailure(err)
+	if reason != failureOther {
+		return reason
+	}
+
+	switch {
+	case details.tls != nil && details.tls.err != nil:
+		return failureTLS
+	case details.http != nil && details.http.err != nil:
+		return failureHTTP
+	}
+
+	return reason
+}
+
+// formatFailureReasons returns a human-readable breakdown
+// of the failure reasons, e.g. "timeout: 3, refused: 1".
+func formatFailureReasons(reasons map[failureReason]uint) string {
+	var parts []string
+
+	for _, reason := range failureReasonOrder {
+		if count := reasons[reason]; count > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", reason, count))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
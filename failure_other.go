@@ -0,0 +1,14 @@
+//go:build !windows
+
+// failure_other.go lists the errnos of the failure reasons on Unix systems
+package main
+
+import "syscall"
+
+var (
+	refusedErrnos            = []syscall.Errno{syscall.ECONNREFUSED, syscall.ECONNRESET}
+	hostUnreachableErrnos    = []syscall.Errno{syscall.EHOSTUNREACH, syscall.EHOSTDOWN}
+	networkUnreachableErrnos = []syscall.Errno{syscall.ENETUNREACH, syscall.ENETDOWN}
+	tooManyOpenFilesErrnos   = []syscall.Errno{syscall.EMFILE, syscall.ENFILE}
+	localBindErrnos          = []syscall.Errno{syscall.EADDRNOTAVAIL, syscall.EADDRINUSE}
+)
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dialError wraps the error the way a failed dial does.
+func dialError(err error) error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want failureReason
+	}{
+		{name: "no error", err: nil, want: ""},
+		{name: "connection refused", err: dialError(syscall.ECONNREFUSED), want: failureRefused},
+		{name: "connection reset", err: dialError(syscall.ECONNRESET), want: failureRefused},
+		{name: "host unreachable", err: dialError(syscall.EHOSTUNREACH), want: failureHostUnreachable},
+		{name: "network unreachable", err: dialError(syscall.ENETUNREACH), want: failureNetworkUnreachable},
+		{name: "too many open files", err: dialError(syscall.EMFILE), want: failureTooManyOpenFiles},
+		{name: "address not available", err: dialError(syscall.EADDRNOTAVAIL), want: failureLocalBind},
+		{name: "bind", err: &net.OpError{Op: "bind", Err: errors.New("cannot assign")}, want: failureLocalBind},
+		{name: "dial timeout", err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, want: failureTimeout},
+		{name: "context deadline", err: fmt.Errorf("handshake: %w", context.DeadlineExceeded), want: failureTimeout},
+		{name: "DNS", err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}, want: failureDNS},
+		{name: "other", err: errors.New("something else"), want: failureOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyFailure(tt.err))
+		})
+	}
+}
+
+func TestClassifyProbeFailure(t *testing.T) {
+	t.Parallel()
+
+	certErr := errors.New("x509: certificate has expired")
+	statusErr := errors.New("unexpected status code 503")
+
+	assert.Equal(t, failureTLS, classifyProbeFailure(certErr, probeDetails{tls: &tlsDetails{err: certErr}}))
+	assert.Equal(t, failureHTTP, classifyProbeFailure(statusErr, probeDetails{http: &httpDetails{err: statusErr}}))
+
+	// a timed out handshake is a timeout
+	timeoutErr := fmt.Errorf("tls: %w", os.ErrDeadlineExceeded)
+	assert.Equal(t, failureTimeout, classifyProbeFailure(timeoutErr, probeDetails{tls: &tlsDetails{err: timeoutErr}}))
+}
+
+func TestFormatFailureReasons(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", formatFailureReasons(nil))
+	assert.Equal(t, "timeout: 3, refused: 1, other: 2", formatFailureReasons(map[failureReason]uint{
+		failureOther:   2,
+		failureRefused: 1,
+		failureTimeout: 3,
+	}))
+}
@@ -0,0 +1,26 @@
+// failure_windows.go lists the errnos of the failure reasons on Windows,
+// where the sockets fail with the Winsock errors rather than the ones of syscall
+package main
+
+import "syscall"
+
+// the Winsock errors missing from the syscall package, see
+// https://learn.microsoft.com/en-us/windows/win32/winsock/windows-sockets-error-codes-2
+const (
+	wsaemfile        syscall.Errno = 10024
+	wsaeaddrinuse    syscall.Errno = 10048
+	wsaeaddrnotavail syscall.Errno = 10049
+	wsaenetdown      syscall.Errno = 10050
+	wsaenetunreach   syscall.Errno = 10051
+	wsaeconnrefused  syscall.Errno = 10061
+	wsaehostdown     syscall.Errno = 10064
+	wsaehostunreach  syscall.Errno = 10065
+)
+
+var (
+	refusedErrnos            = []syscall.Errno{wsaeconnrefused, syscall.WSAECONNRESET, syscall.ECONNREFUSED, syscall.ECONNRESET}
+	hostUnreachableErrnos    = []syscall.Errno{wsaehostunreach, wsaehostdown, syscall.EHOSTUNREACH, syscall.EHOSTDOWN}
+	networkUnreachableErrnos = []syscall.Errno{wsaenetunreach, wsaenetdown, syscall.ENETUNREACH, syscall.ENETDOWN}
+	tooManyOpenFilesErrnos   = []syscall.Errno{wsaemfile, syscall.EMFILE, syscall.ENFILE}
+	localBindErrnos          = []syscall.Errno{wsaeaddrnotavail, wsaeaddrinuse, syscall.EADDRNOTAVAIL, syscall.EADDRINUSE}
+)
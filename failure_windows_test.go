@@ -0,0 +1,31 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyFailureWindows(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want failureReason
+	}{
+		{name: "connection refused", err: dialError(wsaeconnrefused), want: failureRefused},
+		{name: "connection reset", err: dialError(syscall.WSAECONNRESET), want: failureRefused},
+		{name: "host unreachable", err: dialError(wsaehostunreach), want: failureHostUnreachable},
+		{name: "network unreachable", err: dialError(wsaenetunreach), want: failureNetworkUnreachable},
+		{name: "too many open files", err: dialError(wsaemfile), want: failureTooManyOpenFiles},
+		{name: "address not available", err: dialError(wsaeaddrnotavail), want: failureLocalBind},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyFailure(tt.err))
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// hook.go runs a user supplied command on the state changes of the targets
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hookNotifier runs a shell command for every state change.
+// The details of the change are passed through environment
+// variables, and as JSON on the standard input.
+type hookNotifier struct {
+	*notificationQueue
+	command string
+	timeout time.Duration
+}
+
+// newHookNotifier creates a hook notifier and
+// starts running its commands in the background.
+func newHookNotifier(command string, timeout time.Duration, onError func(format string, args ...any)) *hookNotifier {
+	h := &hookNotifier{
+		command: command,
+		timeout: timeout,
+	}
+	h.notificationQueue = newNotificationQueue("hook command", h.run, onError)
+
+	return h
+}
+
+// hookEnv returns the environment variables describing the state change.
+func hookEnv(change stateChange) []string {
+	return []string{
+		"TCPING_EVENT=" + change.Event,
+		"TCPING_HOSTNAME=" + change.Hostname,
+		"TCPING_IP=" + change.IP,
+		"TCPING_PREVIOUS_IP=" + change.PreviousIP,
+		"TCPING_PORT=" + strconv.Itoa(int(change.Port)),
+		"TCPING_TIMESTAMP=" + change.Timestamp.Format(time.RFC3339),
+		"TCPING_DOWNTIME=" + strconv.FormatFloat(change.Downtime, 'f', 3, 64),
+		"TCPING_STREAK=" + strconv.FormatUint(uint64(change.FailedProbes), 10),
+	}
+}
+
+// shellCommand creates a command running the given command line in the shell.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// run runs the command for the state change and waits for it to finish.
+func (h *hookNotifier) run(change stateChange) error {
+	input, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, h.command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), hookEnv(change)...)
+	// don't wait for the processes started by the command once it's killed
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", h.timeout)
+	}
+
+	if err != nil {
+		if output := strings.TrimSpace(string(output)); output != "" {
+			return fmt.Errorf("%w: %s", err, output)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHookEnv(t *testing.T) {
+	change := stateChange{
+		Event:        stateChangeUp,
+		Hostname:     "example.com",
+		IP:           "127.0.0.1",
+		Port:         443,
+		Timestamp:    time.Date(2025, time.February, 1, 10, 0, 5, 0, time.UTC),
+		FailedProbes: 5,
+		Downtime:     5.0021,
+	}
+
+	assert.Equal(t, []string{
+		"TCPING_EVENT=up",
+		"TCPING_HOSTNAME=example.com",
+		"TCPING_IP=127.0.0.1",
+		"TCPING_PREVIOUS_IP=",
+		"TCPING_PORT=443",
+		"TCPING_TIMESTAMP=2025-02-01T10:00:05Z",
+		"TCPING_DOWNTIME=5.002",
+		"TCPING_STREAK=5",
+	}, hookEnv(change))
+}
+
+func TestHookNotifierRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook command is a POSIX shell script")
+	}
+
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "env")
+	stdinFile := filepath.Join(dir, "stdin")
+
+	command := `echo "$TCPING_EVENT $TCPING_HOSTNAME $TCPING_PORT $TCPING_STREAK" > ` + envFile + ` && cat > ` + stdinFile
+
+	h := newHookNotifier(command, 5*time.Second, func(format string, args ...any) {
+		t.Errorf(format, args...)
+	})
+
+	h.notify(stateChange{Event: stateChangeDown, Hostname: "example.com", IP: "127.0.0.1", Port: 443, FailedProbes: 3})
+	h.flush(5 * time.Second)
+
+	env, err := os.ReadFile(envFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "down example.com 443 3", strings.TrimSpace(string(env)))
+
+	stdin, err := os.ReadFile(stdinFile)
+	assert.NoError(t, err)
+
+	var change stateChange
+	assert.NoError(t, json.Unmarshal(stdin, &change))
+	assert.Equal(t, stateChangeDown, change.Event)
+	assert.Equal(t, "127.0.0.1", change.IP)
+	assert.Equal(t, uint(3), change.FailedProbes)
+}
+
+func TestHookNotifierFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook commands are POSIX shell scripts")
+	}
+
+	tests := []struct {
+		name      string
+		command   string
+		timeout   time.Duration
+		wantError string
+	}{
+		{
+			name:      "exit status",
+			command:   "echo remediation failed >&2; exit 3",
+			timeout:   5 * time.Second,
+			wantError: "exit status 3: remediation failed",
+		},
+		{
+			name:      "timeout",
+			command:   "sleep 10",
+			timeout:   100 * time.Millisecond,
+			wantError: "timed out after 100ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported atomic.Value
+			h := newHookNotifier(tt.command, tt.timeout, func(format string, args ...any) {
+				reported.Store(fmt.Sprintf(format, args...))
+			})
+
+			start := time.Now()
+			h.notify(stateChange{Event: stateChangeDown, Hostname: "example.com"})
+			h.flush(5 * time.Second)
+
+			assert.Less(t, time.Since(start), 5*time.Second)
+			assert.Contains(t, reported.Load(), tt.wantError)
+		})
+	}
+}
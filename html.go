@@ -0,0 +1,384 @@
+// html.go writes a self-contained HTML report of the probes at shutdown
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// dimensions of the charts in the HTML report, in pixels
+const (
+	chartWidth       = 900
+	rttChartHeight   = 240
+	lossChartHeight  = 100
+	chartMarginLeft  = 70
+	chartMarginRight = 10
+	chartMarginTop   = 10
+	chartGridLines   = 4
+)
+
+// htmlReport is the data the HTML report is rendered from
+type htmlReport struct {
+	Version   string
+	Generated string
+	Targets   []htmlTarget
+}
+
+// htmlTarget holds the sections of a single target
+type htmlTarget struct {
+	Name            string
+	Statistics      string
+	RTTChart        *htmlRTTChart // RTTChart is nil when no probe was successful
+	LossChart       *htmlLossChart
+	Outages         []htmlOutage
+	HostnameChanges []htmlHostnameChange
+}
+
+// htmlRTTChart draws the average RTT as a line within the band of the min and max RTT
+type htmlRTTChart struct {
+	Width     int
+	Height    int
+	Left      int // Left and Right are the x coordinates of the plot area
+	Right     int
+	Bands     []string // Bands are the polygons of the min and max RTT
+	Lines     []string // Lines are the polylines of the average RTT
+	GridLines []htmlGridLine
+	Start     string
+	End       string
+}
+
+// htmlLossChart draws the packet loss over time as bars
+type htmlLossChart struct {
+	Width  int
+	Height int
+	Left   int // Left and Right are the x coordinates of the plot area
+	Right  int
+	Top    int // Top and Bottom are the y coordinates of 100% and 0% loss
+	Bottom int
+	Bars   []htmlLossBar
+	Start  string
+	End    string
+}
+
+// htmlGridLine is a labeled horizontal line of a chart
+type htmlGridLine struct {
+	Y     float64
+	Label string
+}
+
+// htmlLossBar is the packet loss of a single timeline point
+type htmlLossBar struct {
+	X, Y, Width, Height float64
+	Title               string
+}
+
+// htmlOutage is a row of the outage table
+type htmlOutage struct {
+	Number       int
+	Start        string
+	End          string
+	Duration     string
+	FailedProbes uint
+	IP           string
+	Ongoing      bool
+}
+
+// htmlHostnameChange is a row of the hostname change table
+type htmlHostnameChange struct {
+	Addr    string
+	Since   string
+	Records string
+}
+
+// chartX returns the x coordinate of the center of the i-th of n points.
+// Every point holds the same number of probes, so the probes are evenly spread.
+func chartX(i, n int) float64 {
+	plotWidth := float64(chartWidth - chartMarginLeft - chartMarginRight)
+	return chartMarginLeft + (float64(i)+0.5)*plotWidth/float64(n)
+}
+
+// niceCeiling rounds the value up to 1, 2 or 5 times a power of ten,
+// so that the axis of a chart has readable labels.
+func niceCeiling(value float64) float64 {
+	if value <= 0 {
+		return 1
+	}
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if value <= step*magnitude {
+			return step * magnitude
+		}
+	}
+
+	return 10 * magnitude
+}
+
+// newRTTChart draws the RTTs of the timeline, or returns nil when it has no RTTs
+func newRTTChart(points []timelinePoint) *htmlRTTChart {
+	if !hasSuccessfulPoint(points) {
+		return nil
+	}
+
+	var maxRTT float64
+	for _, p := range points {
+		if p.successful > 0 {
+			maxRTT = math.Max(maxRTT, float64(p.rttMax))
+		}
+	}
+
+	chart := &htmlRTTChart{
+		Width:  chartWidth,
+		Height: rttChartHeight,
+		Left:   chartMarginLeft,
+		Right:  chartWidth - chartMarginRight,
+		Start:  points[0].start.Format(timeFormat),
+		End:    points[len(points)-1].end.Format(timeFormat),
+	}
+
+	plotHeight := float64(rttChartHeight - 2*chartMarginTop)
+	ceiling := niceCeiling(maxRTT)
+	y := func(rtt float32) float64 {
+		return chartMarginTop + plotHeight - float64(rtt)/ceiling*plotHeight
+	}
+
+	for i := 0; i <= chartGridLines; i++ {
+		value := ceiling * float64(i) / chartGridLines
+		chart.GridLines = append(chart.GridLines, htmlGridLine{
+			Y:     chartMarginTop + plotHeight - value/ceiling*plotHeight,
+			Label: fmt.Sprintf("%g ms", value),
+		})
+	}
+
+	// failed points break the lines, so that no RTT is made up for them
+	var upper, lower, line []string
+	flush := func() {
+		if len(line) > 0 {
+			for i, j := 0, len(lower)-1; i < j; i, j = i+1, j-1 {
+				lower[i], lower[j] = lower[j], lower[i]
+			}
+			chart.Bands = append(chart.Bands, strings.Join(append(upper, lower...), " "))
+			chart.Lines = append(chart.Lines, strings.Join(line, " "))
+		}
+		upper, lower, line = nil, nil, nil
+	}
+
+	for i, p := range points {
+		if p.successful == 0 {
+			flush()
+			continue
+		}
+
+		x := chartX(i, len(points))
+		upper = append(upper, fmt.Sprintf("%.1f,%.1f", x, y(p.rttMax)))
+		lower = append(lower, fmt.Sprintf("%.1f,%.1f", x, y(p.rttMin)))
+		line = append(line, fmt.Sprintf("%.1f,%.1f", x, y(p.rttAverage())))
+	}
+	flush()
+
+	return chart
+}
+
+// hasSuccessfulPoint reports whether any point has a successful probe
+func hasSuccessfulPoint(points []timelinePoint) bool {
+	for _, p := range points {
+		if p.successful > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newLossChart draws the packet loss of the timeline
+func newLossChart(points []timelinePoint) *htmlLossChart {
+	chart := &htmlLossChart{
+		Width:  chartWidth,
+		Height: lossChartHeight,
+		Left:   chartMarginLeft,
+		Right:  chartWidth - chartMarginRight,
+		Top:    chartMarginTop,
+		Bottom: lossChartHeight - chartMarginTop,
+		Start:  points[0].start.Format(timeFormat),
+		End:    points[len(points)-1].end.Format(timeFormat),
+	}
+
+	plotWidth := float64(chartWidth - chartMarginLeft - chartMarginRight)
+	plotHeight := float64(lossChartHeight - 2*chartMarginTop)
+	barWidth := plotWidth / float64(len(points))
+
+	for i, p := range points {
+		loss := p.lossPercentage()
+		if loss == 0 {
+			continue
+		}
+
+		height := math.Max(loss/100*plotHeight, 1)
+		chart.Bars = append(chart.Bars, htmlLossBar{
+			X:      chartX(i, len(points)) - barWidth/2,
+			Y:      chartMarginTop + plotHeight - height,
+			Width:  barWidth,
+			Height: height,
+			Title: fmt.Sprintf("%s - %s: %.2f%% loss (%d of %d probes)",
+				p.start.Format(timeFormat), p.end.Format(timeFormat), loss, p.failed, p.probes()),
+		})
+	}
+
+	return chart
+}
+
+// newHTMLTarget collects the sections of the target.
+// It expects the statistics to be calculated already, as done by printStats.
+func newHTMLTarget(t *tcping) htmlTarget {
+	target := htmlTarget{Name: t.target()}
+
+	var statistics bytes.Buffer
+	showTimestamp := false
+	(&plainPrinter{showTimestamp: &showTimestamp, out: &statistics}).printStatistics(*t)
+	target.Statistics = strings.TrimSpace(statistics.String())
+
+	if t.timeline != nil && len(t.timeline.points) > 0 {
+		target.RTTChart = newRTTChart(t.timeline.points)
+		target.LossChart = newLossChart(t.timeline.points)
+	}
+
+	for _, o := range t.allOutages() {
+		target.Outages = append(target.Outages, htmlOutage{
+			Number:       len(target.Outages) + 1,
+			Start:        o.start.Format(timeFormat),
+			End:          o.end.Format(timeFormat),
+			Duration:     durationToString(o.duration),
+			FailedProbes: o.failedProbes,
+			IP:           o.ip.String(),
+			Ongoing:      o.ongoing,
+		})
+	}
+
+	for _, change := range t.hostnameChanges {
+		if !change.Addr.IsValid() {
+			continue
+		}
+		target.HostnameChanges = append(target.HostnameChanges, htmlHostnameChange{
+			Addr:    change.Addr.String(),
+			Since:   change.When.Format(timeFormat),
+			Records: formatRecords(change.Records),
+		})
+	}
+
+	// the target list of the SRV records is shown along with their last target
+	if t.isLastSRVTarget() {
+		for _, change := range t.srv.changes {
+			target.HostnameChanges = append(target.HostnameChanges, htmlHostnameChange{
+				Addr:    "SRV " + t.srv.name,
+				Since:   change.When.Format(timeFormat),
+				Records: strings.Join(change.Targets, ", "),
+			})
+		}
+	}
+
+	return target
+}
+
+// writeHTMLReport writes the report of the targets to a single HTML file,
+// which needs no network access to be viewed.
+func writeHTMLReport(filename string, targets []*tcping) error {
+	report := htmlReport{
+		Version:   version,
+		Generated: time.Now().Format(timeFormat),
+	}
+	for _, t := range targets {
+		report.Targets = append(report.Targets, newHTMLTarget(t))
+	}
+
+	var buf bytes.Buffer
+	if err := htmlReportTemplate.Execute(&buf, report); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, buf.Bytes(), filePermission)
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>TCPing report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; background: #fff; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; border-bottom: 1px solid #ccc; padding-bottom: .2em; margin-top: 2em; }
+h3 { font-size: 1.05em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: .3em .8em; text-align: left; }
+th { background: #f3f3f3; }
+pre { background: #f7f7f7; padding: 1em; overflow-x: auto; }
+svg text { font-size: 11px; fill: #555; }
+.grid { stroke: #e3e3e3; }
+.band { fill: #9ecae1; fill-opacity: .5; stroke: none; }
+.avg { fill: none; stroke: #08519c; stroke-width: 1.5; }
+.loss { fill: #d62728; }
+.axis { stroke: #999; }
+.meta, .empty { color: #777; }
+.ongoing { color: #d62728; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>TCPing report</h1>
+<p class="meta">Generated on {{.Generated}} by TCPing version {{.Version}}</p>
+{{range .Targets}}
+<h2>{{.Name}}</h2>
+
+<h3>Round-trip time</h3>
+{{with .RTTChart}}
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}" role="img">
+{{$chart := .}}{{range .GridLines}}<line class="grid" x1="{{$chart.Left}}" x2="{{$chart.Right}}" y1="{{printf "%.1f" .Y}}" y2="{{printf "%.1f" .Y}}"/><text x="{{$chart.Left}}" dx="-6" y="{{printf "%.1f" .Y}}" text-anchor="end" dominant-baseline="middle">{{.Label}}</text>
+{{end}}{{range .Bands}}<polygon class="band" points="{{.}}"/>
+{{end}}{{range .Lines}}<polyline class="avg" points="{{.}}"/>
+{{end}}</svg>
+<p class="meta">{{.Start}} &ndash; {{.End}}. The line is the average RTT, the band spans the min and max RTT.</p>
+{{else}}
+<p class="empty">No successful probes.</p>
+{{end}}
+
+<h3>Packet loss</h3>
+{{with .LossChart}}
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}" role="img">
+<line class="grid" x1="{{.Left}}" x2="{{.Right}}" y1="{{.Top}}" y2="{{.Top}}"/><text x="{{.Left}}" dx="-6" y="{{.Top}}" text-anchor="end" dominant-baseline="middle">100%</text>
+<line class="axis" x1="{{.Left}}" x2="{{.Right}}" y1="{{.Bottom}}" y2="{{.Bottom}}"/><text x="{{.Left}}" dx="-6" y="{{.Bottom}}" text-anchor="end" dominant-baseline="middle">0%</text>
+{{range .Bars}}<rect class="loss" x="{{printf "%.1f" .X}}" y="{{printf "%.1f" .Y}}" width="{{printf "%.2f" .Width}}" height="{{printf "%.1f" .Height}}"><title>{{.Title}}</title></rect>
+{{end}}</svg>
+<p class="meta">{{.Start}} &ndash; {{.End}}</p>
+{{else}}
+<p class="empty">No probes.</p>
+{{end}}
+
+<h3>Outages</h3>
+{{if .Outages}}
+<table>
+<tr><th>#</th><th>Start</th><th>End</th><th>Duration</th><th>Failed probes</th><th>IP</th></tr>
+{{range .Outages}}<tr><td>{{.Number}}</td><td>{{.Start}}</td><td>{{if .Ongoing}}<span class="ongoing">ongoing</span>{{else}}{{.End}}{{end}}</td><td>{{.Duration}}</td><td>{{.FailedProbes}}</td><td>{{.IP}}</td></tr>
+{{end}}</table>
+{{else}}
+<p class="empty">No outages.</p>
+{{end}}
+
+<h3>Hostname changes</h3>
+{{if .HostnameChanges}}
+<table>
+<tr><th>IP</th><th>Since</th><th>Records</th></tr>
+{{range .HostnameChanges}}<tr><td>{{.Addr}}</td><td>{{.Since}}</td><td>{{.Records}}</td></tr>
+{{end}}</table>
+{{else}}
+<p class="empty">No hostname changes.</p>
+{{end}}
+
+<h3>Statistics</h3>
+<pre>{{.Statistics}}</pre>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNiceCeiling(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  float64
+	}{
+		{value: 0, want: 1},
+		{value: 0.3, want: 0.5},
+		{value: 1, want: 1},
+		{value: 13, want: 20},
+		{value: 42, want: 50},
+		{value: 51, want: 100},
+		{value: 120, want: 200},
+	}
+
+	for _, tt := range tests {
+		assert.InDelta(t, tt.want, niceCeiling(tt.value), 1e-9, "value = %v", tt.value)
+	}
+}
+
+func TestNewRTTChart(t *testing.T) {
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+
+	points := make([]timelinePoint, 4)
+	points[0].add(start, true, 10)
+	points[1].add(start.Add(time.Second), true, 20)
+	points[2].add(start.Add(2*time.Second), false, 0)
+	points[3].add(start.Add(3*time.Second), true, 40)
+
+	chart := newRTTChart(points)
+	assert.Len(t, chart.Lines, 2, "the failed point breaks the line")
+	assert.Len(t, chart.Bands, 2)
+	assert.Len(t, chart.GridLines, chartGridLines+1)
+	assert.Equal(t, "50 ms", chart.GridLines[chartGridLines].Label)
+
+	failed := make([]timelinePoint, 1)
+	failed[0].add(start, false, 0)
+	assert.Nil(t, newRTTChart(failed))
+	assert.Len(t, newLossChart(failed).Bars, 1)
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	stats := createTestStats(t)
+	stats.userInput.hostname = "<script>alert(1)</script>"
+	stats.timeline = newProbeTimeline()
+
+	// probes lists the probe results in order, true being a successful probe
+	probes := []bool{true, false, false, true, true}
+
+	now := time.Now()
+	stats.startTime = now
+	stats.startOfUptime = now
+	for i, success := range probes {
+		probeTime := now.Add(time.Duration(i) * time.Second)
+		if success {
+			stats.handleConnSuccess("127.0.0.1:4567", 1, probeTime, 10*time.Millisecond, probeDetails{})
+		} else {
+			stats.handleConnError(probeTime, time.Second, probeDetails{})
+		}
+	}
+	stats.endTime = now.Add(time.Duration(len(probes)) * time.Second)
+	stats.rttResults = stats.rtt.result()
+
+	assert.Len(t, stats.timeline.points, len(probes))
+
+	filename := filepath.Join(t.TempDir(), "report.html")
+	assert.NoError(t, writeHTMLReport(filename, []*tcping{stats}))
+
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	report := string(content)
+
+	for _, section := range []string{"<h3>Round-trip time</h3>", "<h3>Packet loss</h3>", "<h3>Outages</h3>", "<h3>Hostname changes</h3>", "<h3>Statistics</h3>"} {
+		assert.Contains(t, report, section)
+	}
+	assert.Contains(t, report, `<polyline class="avg"`)
+	assert.Contains(t, report, `<rect class="loss"`)
+	assert.Contains(t, report, "TCPing statistics")
+	assert.NotContains(t, report, "<script>", "the hostname is escaped")
+	assert.Contains(t, report, "&lt;script&gt;")
+	assert.False(t, strings.Contains(report, "http://") || strings.Contains(report, "https://"), "the report is self-contained")
+}
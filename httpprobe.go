@@ -0,0 +1,169 @@
+// httpprobe.go sends an HTTP request on top of the TCP probes
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpMaxBodySize is the most of the response body that's read,
+// so that a large or endless body doesn't keep the probe busy.
+const httpMaxBodySize = 1 << 20
+
+// httpProbeConfig holds the request sent by the HTTP probes
+// and the status codes that make a probe successful.
+type httpProbeConfig struct {
+	method         string
+	path           string
+	host           string
+	expectedStatus []statusRange
+}
+
+// statusRange is an inclusive range of HTTP status codes.
+type statusRange struct {
+	from int
+	to   int
+}
+
+// httpDetails holds the outcome of an HTTP request.
+type httpDetails struct {
+	// ttfb is the time from sending the request to receiving
+	// the first byte of the response, in ms.
+	ttfb float32
+	// total is the time from sending the request to reading
+	// the whole response, or its first httpMaxBodySize bytes, in ms.
+	total      float32
+	statusCode int
+	// err is set when the request has failed
+	// or the status code was not expected.
+	err error
+}
+
+// parseStatusCodes parses a comma-separated list of
+// status codes and ranges of them, e.g. "200-299,301".
+func parseStatusCodes(codes string) ([]statusRange, error) {
+	var ranges []statusRange
+
+	for _, part := range strings.Split(codes, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			to = from
+		}
+
+		fromCode, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q", part)
+		}
+
+		toCode, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q", part)
+		}
+
+		if fromCode < 100 || toCode > 599 || fromCode > toCode {
+			return nil, fmt.Errorf("invalid status code range %q", part)
+		}
+
+		ranges = append(ranges, statusRange{from: fromCode, to: toCode})
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no status codes given")
+	}
+
+	return ranges, nil
+}
+
+// isExpected reports whether the status code is within the expected ranges.
+func (c *httpProbeConfig) isExpected(statusCode int) bool {
+	for _, r := range c.expectedStatus {
+		if statusCode >= r.from && statusCode <= r.to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// firstByteReader records when the first byte was read.
+type firstByteReader struct {
+	reader    io.Reader
+	firstByte time.Time
+}
+
+func (r *firstByteReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 && r.firstByte.IsZero() {
+		r.firstByte = time.Now()
+	}
+
+	return n, err
+}
+
+// httpRequest sends the configured request over an already
+// established connection and reads the whole response.
+func httpRequest(conn net.Conn, config *httpProbeConfig, timeout time.Duration) (httpDetails, error) {
+	var details httpDetails
+
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	req, err := http.NewRequest(config.method, "http://"+config.host+config.path, nil)
+	if err != nil {
+		details.err = err
+		return details, err
+	}
+	req.Host = config.host
+	req.Close = true
+	req.Header.Set("User-Agent", "tcping/"+version)
+
+	requestStart := time.Now()
+
+	if err := req.Write(conn); err != nil {
+		details.err = err
+		return details, err
+	}
+
+	reader := &firstByteReader{reader: conn}
+
+	resp, err := http.ReadResponse(bufio.NewReader(reader), req)
+	if err != nil {
+		details.err = err
+		return details, err
+	}
+	defer resp.Body.Close()
+
+	details.ttfb = nanoToMillisecond(reader.firstByte.Sub(requestStart).Nanoseconds())
+	details.statusCode = resp.StatusCode
+
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, httpMaxBodySize))
+	if err != nil {
+		details.err = err
+		return details, err
+	}
+	if n == httpMaxBodySize {
+		// closing the body would read the rest of it
+		conn.SetReadDeadline(time.Now())
+	}
+
+	details.total = nanoToMillisecond(time.Since(requestStart).Nanoseconds())
+
+	if !config.isExpected(resp.StatusCode) {
+		details.err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		return details, details.err
+	}
+
+	return details, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestHTTPConfig creates an HTTP probe config expecting the given status codes.
+func newTestHTTPConfig(t *testing.T, statusCodes string) *httpProbeConfig {
+	expectedStatus, err := parseStatusCodes(statusCodes)
+	if err != nil {
+		t.Fatalf("parse status codes: %v", err)
+	}
+
+	return &httpProbeConfig{
+		method:         "GET",
+		path:           "/health",
+		host:           "example.com",
+		expectedStatus: expectedStatus,
+	}
+}
+
+func TestParseStatusCodes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		codes   string
+		want    []statusRange
+		wantErr bool
+	}{
+		{
+			name:  "single code",
+			codes: "200",
+			want:  []statusRange{{200, 200}},
+		},
+		{
+			name:  "range and codes",
+			codes: "200-299, 301,302",
+			want:  []statusRange{{200, 299}, {301, 301}, {302, 302}},
+		},
+		{
+			name:    "empty",
+			codes:   "",
+			wantErr: true,
+		},
+		{
+			name:    "not a number",
+			codes:   "ok",
+			wantErr: true,
+		},
+		{
+			name:    "reversed range",
+			codes:   "399-200",
+			wantErr: true,
+		},
+		{
+			name:    "out of range",
+			codes:   "200-600",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStatusCodes(tt.codes)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHTTPProbeSuccess(t *testing.T) {
+	var gotHost, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+		gotPath = r.URL.Path
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	stats := createServerTestStats(t, srv)
+	stats.userInput.httpConfig = newTestHTTPConfig(t, "200-399")
+
+	for i := 0; i < 5; i++ {
+		tcpProbe(stats)
+	}
+
+	assert.Equal(t, uint(5), stats.totalSuccessfulProbes)
+	assert.Equal(t, uint(0), stats.totalUnsuccessfulProbes)
+	assert.Equal(t, "example.com", gotHost)
+	assert.Equal(t, "/health", gotPath)
+}
+
+func TestHTTPProbeUnexpectedStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	stats := createServerTestStats(t, srv)
+	stats.userInput.httpConfig = newTestHTTPConfig(t, "200-399")
+
+	for i := 0; i < 5; i++ {
+		tcpProbe(stats)
+	}
+
+	assert.Equal(t, uint(0), stats.totalSuccessfulProbes)
+	assert.Equal(t, uint(5), stats.totalUnsuccessfulProbes)
+	assert.True(t, stats.destWasDown)
+}
+
+func TestHTTPSProbeSuccess(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	stats := createServerTestStats(t, srv)
+
+	tlsConfig := newTLSConfig("example.com", false, "http/1.1")
+	tlsConfig.RootCAs = x509.NewCertPool()
+	tlsConfig.RootCAs.AddCert(srv.Certificate())
+	stats.userInput.tlsConfig = tlsConfig
+	stats.userInput.httpConfig = newTestHTTPConfig(t, "404")
+
+	for i := 0; i < 5; i++ {
+		tcpProbe(stats)
+	}
+
+	assert.Equal(t, uint(5), stats.totalSuccessfulProbes)
+	assert.Equal(t, uint(0), stats.totalUnsuccessfulProbes)
+}
+
+func TestHTTPRequestDetails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial test server: %v", err)
+	}
+	defer conn.Close()
+
+	details, err := httpRequest(conn, newTestHTTPConfig(t, "200"), time.Second)
+
+	assert.Error(t, err)
+	assert.Equal(t, err, details.err)
+	assert.Equal(t, http.StatusInternalServerError, details.statusCode)
+	assert.Greater(t, details.ttfb, float32(0))
+	assert.GreaterOrEqual(t, details.total, details.ttfb)
+}
+
+func TestHTTPRequestEndlessBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		chunk := make([]byte, 64<<10)
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial test server: %v", err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	details, err := httpRequest(conn, newTestHTTPConfig(t, "200"), 5*time.Second)
+
+	assert.NoError(t, err, "only the first bytes of the body are read")
+	assert.Less(t, time.Since(start), 5*time.Second, "the rest of the body is not read")
+	assert.Equal(t, http.StatusOK, details.statusCode)
+}
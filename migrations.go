@@ -0,0 +1,493 @@
+// migrations.go keeps the schema of existing sqlite databases up to date
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+const (
+	// metadataSchema holds details about the database, such as its schema version
+	metadataSchema = `
+CREATE TABLE IF NOT EXISTS metadata (
+    key TEXT PRIMARY KEY,
+    value TEXT
+);`
+
+	schemaVersionKey = "schema_version"
+
+	// every run of tcping is a session,
+	// to which the events and probes belong.
+	// New databases are created with this schema at once,
+	// which should match the result of every migration.
+	sessionsSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER PRIMARY KEY,
+    targets TEXT, -- the probed targets, e.g. "example.com:443 192.168.1.1:22"
+    start_time DATETIME,
+    end_time DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES sessions(id),
+    event_type TEXT NOT NULL, -- for the data type eg. statistics, hostname change
+    timestamp DATETIME,
+    addr TEXT,
+    sourceAddr TEXT,
+    hostname TEXT,
+    port INTEGER,
+    hostname_resolve_retries INTEGER,
+
+    hostname_changed_to TEXT,
+    hostname_change_time DATETIME,
+
+    latency_min REAL,
+    latency_avg REAL,
+    latency_max REAL,
+    latency_p50 REAL,
+    latency_p90 REAL,
+    latency_p95 REAL,
+    latency_p99 REAL,
+    latency_stddev REAL,
+    latency_jitter REAL,
+
+	total_duration TEXT,
+    start_time DATETIME,
+    end_time DATETIME,
+
+	never_succeed_probe INTEGER, -- value will be 1 if a probe never succeeded
+	never_failed_probe INTEGER, -- value will be 1 if a probe never failed
+    last_successful_probe DATETIME,
+    last_unsuccessful_probe DATETIME,
+
+    longest_uptime TEXT,
+    longest_uptime_start DATETIME,
+    longest_uptime_end DATETIME,
+
+    longest_downtime TEXT,
+    longest_downtime_start DATETIME,
+    longest_downtime_end DATETIME,
+
+    outages INTEGER,
+    mttr TEXT,
+    mtbf TEXT,
+
+    outage_start DATETIME,
+    outage_end DATETIME,
+    outage_duration TEXT,
+    outage_failed_probes INTEGER,
+    outage_ongoing INTEGER, -- value will be 1 if the target was still down when tcping ended
+
+    total_packets INTEGER,
+    total_packet_loss REAL,
+    total_successful_probes INTEGER,
+    total_unsuccessful_probes INTEGER,
+    failure_reasons TEXT, -- e.g. "timeout: 3, refused: 1"
+
+    total_uptime TEXT,
+    total_downtime TEXT
+);
+
+CREATE TABLE IF NOT EXISTS probes (
+    id INTEGER PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES sessions(id),
+    timestamp DATETIME,
+    hostname TEXT,
+    addr TEXT, -- the resolved IP address of the target
+    sourceAddr TEXT,
+    port INTEGER,
+    success INTEGER, -- value will be 1 if the probe succeeded
+    rtt REAL, -- in milliseconds, NULL for failed probes
+    failure_reason TEXT
+);
+
+CREATE INDEX IF NOT EXISTS probes_session_timestamp ON probes (session_id, timestamp);
+CREATE INDEX IF NOT EXISTS events_session ON events (session_id);`
+
+	// sessionsSchemaV1 is the schema created by the migration to version 1,
+	// which must stay as it was released
+	sessionsSchemaV1 = `
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER PRIMARY KEY,
+    targets TEXT, -- the probed targets, e.g. "example.com:443 192.168.1.1:22"
+    start_time DATETIME,
+    end_time DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES sessions(id),
+    event_type TEXT NOT NULL, -- for the data type eg. statistics, hostname change
+    timestamp DATETIME,
+    addr TEXT,
+    sourceAddr TEXT,
+    hostname TEXT,
+    port INTEGER,
+    hostname_resolve_retries INTEGER,
+
+    hostname_changed_to TEXT,
+    hostname_change_time DATETIME,
+
+    latency_min REAL,
+    latency_avg REAL,
+    latency_max REAL,
+    latency_p50 REAL,
+    latency_p90 REAL,
+    latency_p95 REAL,
+    latency_p99 REAL,
+    latency_stddev REAL,
+    latency_jitter REAL,
+
+	total_duration TEXT,
+    start_time DATETIME,
+    end_time DATETIME,
+
+	never_succeed_probe INTEGER, -- value will be 1 if a probe never succeeded
+	never_failed_probe INTEGER, -- value will be 1 if a probe never failed
+    last_successful_probe DATETIME,
+    last_unsuccessful_probe DATETIME,
+
+    longest_uptime TEXT,
+    longest_uptime_start DATETIME,
+    longest_uptime_end DATETIME,
+
+    longest_downtime TEXT,
+    longest_downtime_start DATETIME,
+    longest_downtime_end DATETIME,
+
+    outages INTEGER,
+    mttr TEXT,
+    mtbf TEXT,
+
+    outage_start DATETIME,
+    outage_end DATETIME,
+    outage_duration TEXT,
+    outage_failed_probes INTEGER,
+    outage_ongoing INTEGER, -- value will be 1 if the target was still down when tcping ended
+
+    total_packets INTEGER,
+    total_packet_loss REAL,
+    total_successful_probes INTEGER,
+    total_unsuccessful_probes INTEGER,
+    failure_reasons TEXT, -- e.g. "timeout: 3, refused: 1"
+
+    total_uptime TEXT,
+    total_downtime TEXT
+);
+
+CREATE TABLE IF NOT EXISTS probes (
+    id INTEGER PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES sessions(id),
+    timestamp DATETIME,
+    hostname TEXT,
+    addr TEXT, -- the resolved IP address of the target
+    sourceAddr TEXT,
+    port INTEGER,
+    success INTEGER, -- value will be 1 if the probe succeeded
+    rtt REAL, -- in milliseconds, NULL for failed probes
+    failure_reason TEXT
+);
+
+CREATE INDEX IF NOT EXISTS probes_session_timestamp ON probes (session_id, timestamp);
+CREATE INDEX IF NOT EXISTS events_session ON events (session_id);`
+)
+
+// migration moves a database from the previous schema version to its version.
+//
+// Migrations are never changed once released;
+// changes to the schema should be added as a new migration instead,
+// e.g. an ALTER TABLE, and made to sessionsSchema too.
+type migration struct {
+	version     int
+	description string
+	up          func(conn *sqlite.Conn) error
+}
+
+// migrations are applied in order when opening a database.
+// Databases created before the schema version was recorded are at version 0.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create the sessions, events and probes tables",
+		up: func(conn *sqlite.Conn) error {
+			return sqlitex.ExecuteScript(conn, sessionsSchemaV1, nil)
+		},
+	},
+	{
+		version:     2,
+		description: "move the tables of every run into sessions",
+		up:          moveLegacyTables,
+	},
+}
+
+// latestSchemaVersion is the schema version used by this version of tcping
+var latestSchemaVersion = migrations[len(migrations)-1].version
+
+// migrateDB applies the migrations the database is missing,
+// each one in its own transaction.
+func migrateDB(conn *sqlite.Conn) error {
+	err := sqlitex.ExecuteScript(conn, metadataSchema, nil)
+	if err != nil {
+		return err
+	}
+
+	version, err := schemaVersion(conn)
+	if err != nil {
+		return err
+	}
+
+	if version > latestSchemaVersion {
+		return fmt.Errorf("the schema version %d of the database is newer than the version %d supported by this version of tcping", version, latestSchemaVersion)
+	}
+
+	if version == 0 {
+		tables, err := tableNames(conn)
+		if err != nil {
+			return err
+		}
+
+		// a new database gets the current schema at once
+		if slices.Equal(tables, []string{"metadata"}) {
+			return applyMigration(conn, migration{
+				version:     latestSchemaVersion,
+				description: "create the current schema",
+				up: func(conn *sqlite.Conn) error {
+					return sqlitex.ExecuteScript(conn, sessionsSchema, nil)
+				},
+			})
+		}
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+
+		if err := applyMigration(conn, m); err != nil {
+			return fmt.Errorf("failed to migrate the database to schema version %d (%s): %w", m.version, m.description, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration applies the migration and records
+// the new schema version in a single transaction.
+func applyMigration(conn *sqlite.Conn, m migration) (err error) {
+	defer sqlitex.Transaction(conn)(&err)
+
+	if err := m.up(conn); err != nil {
+		return err
+	}
+
+	return sqlitex.Execute(conn, "INSERT OR REPLACE INTO metadata (key, value) VALUES (?, ?)", &sqlitex.ExecOptions{
+		Args: []interface{}{schemaVersionKey, strconv.Itoa(m.version)},
+	})
+}
+
+// schemaVersion returns the schema version of the database,
+// which is 0 if it was never recorded.
+func schemaVersion(conn *sqlite.Conn) (int, error) {
+	var value string
+	err := sqlitex.Execute(conn, "SELECT value FROM metadata WHERE key = ?", &sqlitex.ExecOptions{
+		Args: []interface{}{schemaVersionKey},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			value = stmt.ColumnText(0)
+			return nil
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if value == "" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", value, err)
+	}
+
+	return version, nil
+}
+
+// legacyTable is a table tcping v2.7 and earlier created for every run,
+// named after the target and the start time, e.g. "example_com_443_15_04_05_01_02_2006"
+type legacyTable struct {
+	name      string
+	target    string
+	startTime string
+	endTime   string
+}
+
+// moveLegacyTables turns every per-run table into a session,
+// moving its rows to the events table.
+func moveLegacyTables(conn *sqlite.Conn) error {
+	tables, err := findLegacyTables(conn)
+	if err != nil {
+		return err
+	}
+
+	// the sessions should be in the order the runs started
+	sort.SliceStable(tables, func(i, j int) bool {
+		return tables[i].startTime < tables[j].startTime
+	})
+
+	eventColumns, err := tableColumns(conn, "events")
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if err := moveLegacyTable(conn, table, eventColumns); err != nil {
+			return fmt.Errorf("table %q: %w", table.name, err)
+		}
+	}
+
+	return nil
+}
+
+// findLegacyTables returns the tables with an event_type column,
+// other than the events table, along with the target and the time of their run.
+func findLegacyTables(conn *sqlite.Conn) ([]legacyTable, error) {
+	var names []string
+	query := `SELECT name FROM sqlite_master
+	WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name NOT IN ('metadata', 'sessions', 'events', 'probes')
+	ORDER BY name`
+
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			names = append(names, stmt.ColumnText(0))
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []legacyTable
+	for _, name := range names {
+		columns, err := tableColumns(conn, name)
+		if err != nil {
+			return nil, err
+		}
+
+		// leave the tables not created by tcping alone
+		if !slices.Contains(columns, "event_type") {
+			continue
+		}
+
+		table := legacyTable{name: name, target: name}
+
+		// the statistics rows tell the target and the time of the run
+		query := fmt.Sprintf(`SELECT hostname, port, MIN(start_time), MAX(end_time)
+		FROM %s WHERE event_type = ?`, quoteIdentifier(name))
+
+		err = sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+			Args: []interface{}{eventTypeStatistics},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if hostname := stmt.ColumnText(0); hostname != "" {
+					table.target = net.JoinHostPort(hostname, stmt.ColumnText(1))
+				}
+				table.startTime = stmt.ColumnText(2)
+				table.endTime = stmt.ColumnText(3)
+				return nil
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, table)
+	}
+
+	return tables, nil
+}
+
+// moveLegacyTable creates a session for the table, copies its rows
+// to the events table and drops it.
+func moveLegacyTable(conn *sqlite.Conn, table legacyTable, eventColumns []string) error {
+	err := sqlitex.Execute(conn, "INSERT INTO sessions (targets, start_time, end_time) VALUES (?, ?, ?)", &sqlitex.ExecOptions{
+		Args: []interface{}{table.target, table.startTime, table.endTime},
+	})
+	if err != nil {
+		return err
+	}
+	sessionID := conn.LastInsertRowID()
+
+	columns, err := tableColumns(conn, table.name)
+	if err != nil {
+		return err
+	}
+
+	// older versions of tcping have fewer columns,
+	// so only the ones known to the events table are copied
+	var shared []string
+	for _, column := range columns {
+		if column != "id" && slices.Contains(eventColumns, column) {
+			shared = append(shared, quoteIdentifier(column))
+		}
+	}
+	sharedColumns := strings.Join(shared, ", ")
+
+	query := fmt.Sprintf("INSERT INTO events (session_id, %s) SELECT ?, %s FROM %s ORDER BY id",
+		sharedColumns, sharedColumns, quoteIdentifier(table.name))
+
+	err = sqlitex.Execute(conn, query, &sqlitex.ExecOptions{Args: []interface{}{sessionID}})
+	if err != nil {
+		return err
+	}
+
+	// the hostname changes were saved without the target
+	host, port, err := net.SplitHostPort(table.target)
+	if err == nil {
+		err = sqlitex.Execute(conn, "UPDATE events SET hostname = ?, port = ? WHERE session_id = ? AND hostname IS NULL", &sqlitex.ExecOptions{
+			Args: []interface{}{host, port, sessionID},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return sqlitex.Execute(conn, "DROP TABLE "+quoteIdentifier(table.name), nil)
+}
+
+// tableNames returns the names of the tables of the database, in order
+func tableNames(conn *sqlite.Conn) ([]string, error) {
+	var names []string
+	err := sqlitex.Execute(conn, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name", &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			names = append(names, stmt.ColumnText(0))
+			return nil
+		},
+	})
+
+	return names, err
+}
+
+// tableColumns returns the column names of the table
+func tableColumns(conn *sqlite.Conn, table string) ([]string, error) {
+	var columns []string
+	err := sqlitex.Execute(conn, fmt.Sprintf("PRAGMA table_info(%s)", quoteIdentifier(table)), &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			columns = append(columns, stmt.ColumnText(1))
+			return nil
+		},
+	})
+
+	return columns, err
+}
+
+// quoteIdentifier quotes the name of a table or column to be used in a query
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// openFixtureDB creates a database from the SQL dump in testdata/db
+// and returns its path, so that it can be opened like an archived database.
+func openFixtureDB(t *testing.T, fixture string) string {
+	t.Helper()
+
+	script, err := os.ReadFile(filepath.Join("testdata", "db", fixture))
+	assert.NoError(t, err)
+
+	dbPath := filepath.Join(t.TempDir(), "tcping.db")
+	conn, err := sqlite.OpenConn(dbPath, sqlite.OpenCreate, sqlite.OpenReadWrite)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, sqlitex.ExecuteScript(conn, string(script), nil))
+
+	return dbPath
+}
+
+// queryRows returns every row of the query, with the columns joined by "|"
+func queryRows(t *testing.T, conn *sqlite.Conn, query string) []string {
+	t.Helper()
+
+	var rows []string
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			var columns []string
+			for i := 0; i < stmt.ColumnCount(); i++ {
+				columns = append(columns, stmt.ColumnText(i))
+			}
+			rows = append(rows, strings.Join(columns, "|"))
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	return rows
+}
+
+func TestMigrationVersions(t *testing.T) {
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.version, "the migrations should have consecutive versions")
+		assert.NotEmpty(t, m.description)
+	}
+	assert.Equal(t, len(migrations), latestSchemaVersion)
+}
+
+func TestMigrateNewDB(t *testing.T) {
+	db := newDB(":memory:", []string{"localhost:8001"})
+	defer db.conn.Close()
+
+	version, err := schemaVersion(db.conn)
+	assert.NoError(t, err)
+	assert.Equal(t, latestSchemaVersion, version)
+}
+
+// schemaRows describes the tables and indexes of the database,
+// regardless of the order their columns were added in
+func schemaRows(t *testing.T, conn *sqlite.Conn) []string {
+	t.Helper()
+
+	columns := queryRows(t, conn, `SELECT m.name, p.name, p.type, p."notnull", p.dflt_value, p.pk
+	FROM sqlite_master m JOIN pragma_table_info(m.name) p
+	WHERE m.type = 'table' ORDER BY m.name, p.name`)
+	indexes := queryRows(t, conn, `SELECT m.name, m.tbl_name, i.seqno, i.name
+	FROM sqlite_master m JOIN pragma_index_info(m.name) i
+	WHERE m.type = 'index' AND m.name NOT LIKE 'sqlite_%' ORDER BY m.name, i.seqno`)
+
+	return append(columns, indexes...)
+}
+
+func TestMigrationsMatchSchema(t *testing.T) {
+	fresh, err := sqlite.OpenConn(":memory:", sqlite.OpenCreate, sqlite.OpenReadWrite)
+	assert.NoError(t, err)
+	defer fresh.Close()
+	assert.NoError(t, migrateDB(fresh))
+
+	migrated, err := sqlite.OpenConn(":memory:", sqlite.OpenCreate, sqlite.OpenReadWrite)
+	assert.NoError(t, err)
+	defer migrated.Close()
+	assert.NoError(t, sqlitex.ExecuteScript(migrated, metadataSchema, nil))
+	for _, m := range migrations {
+		assert.NoError(t, applyMigration(migrated, m), m.description)
+	}
+
+	assert.NotEmpty(t, schemaRows(t, fresh))
+	assert.Equal(t, schemaRows(t, fresh), schemaRows(t, migrated), "replaying every migration should give the schema of a new database")
+
+	version, err := schemaVersion(migrated)
+	assert.NoError(t, err)
+	assert.Equal(t, latestSchemaVersion, version)
+}
+
+func TestMigrateLegacyDB(t *testing.T) {
+	dbPath := openFixtureDB(t, "v2.7.sql")
+
+	db := newDB(dbPath, []string{"example.com:443"})
+	defer db.conn.Close()
+
+	version, err := schemaVersion(db.conn)
+	assert.NoError(t, err)
+	assert.Equal(t, latestSchemaVersion, version)
+
+	// the per-run tables are gone, while the unrelated table is left alone
+	tables := queryRows(t, db.conn, "SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name")
+	assert.Equal(t, []string{"events", "metadata", "notes", "probes", "sessions"}, tables)
+	assert.Equal(t, []string{"added by hand, should be left alone"}, queryRows(t, db.conn, "SELECT note FROM notes"))
+
+	// every run becomes a session, in the order they started
+	sessions := queryRows(t, db.conn, "SELECT id, targets, start_time, end_time FROM sessions WHERE id < "+strconv.FormatInt(db.sessionID, 10))
+	assert.Equal(t, []string{
+		"1|127.0.0.1:18080|2026-10-16 12:00:46|2026-10-16 12:00:46",
+		"2|localhost:18097|2026-10-16 12:00:47|2026-10-16 12:00:48",
+	}, sessions)
+	assert.Equal(t, int64(3), db.sessionID)
+
+	// the columns added after v2.7, like latency_p99, are left empty
+	events := queryRows(t, db.conn, `SELECT session_id, event_type, hostname, port,
+	latency_avg || '', total_packet_loss || '', longest_downtime, hostname_changed_to, latency_p99
+	FROM events WHERE session_id < 3 ORDER BY id`)
+	assert.Equal(t, []string{
+		"1|statistics|127.0.0.1|18080|0.664|0.0|0s||",
+		"1|hostname change|127.0.0.1|18080||||127.0.0.1|",
+		"2|statistics|localhost|18097|0.0|100.0|400.502428ms||",
+		"2|hostname change|localhost|18097||||127.0.0.1|",
+	}, events)
+
+	// the migrated database can be written to like a new one
+	stat := mockStats()
+	assert.NoError(t, db.saveStats(stat))
+	assert.NoError(t, db.close())
+}
+
+func TestMigrateUnversionedSessionsDB(t *testing.T) {
+	dbPath := openFixtureDB(t, "sessions_unversioned.sql")
+
+	db := newDB(dbPath, []string{"example.com:443"})
+	defer db.conn.Close()
+
+	version, err := schemaVersion(db.conn)
+	assert.NoError(t, err)
+	assert.Equal(t, latestSchemaVersion, version)
+
+	assert.Equal(t, []string{"1|127.0.0.1:18080 localhost:18097", "2|example.com:443"},
+		queryRows(t, db.conn, "SELECT id, targets FROM sessions ORDER BY id"))
+	assert.Equal(t, []string{"1|4"}, queryRows(t, db.conn, "SELECT session_id, COUNT(*) FROM probes GROUP BY session_id"))
+	assert.Equal(t, []string{"1|5"}, queryRows(t, db.conn, "SELECT session_id, COUNT(*) FROM events GROUP BY session_id"))
+}
+
+func TestMigrateTwice(t *testing.T) {
+	dbPath := openFixtureDB(t, "v2.7.sql")
+
+	first := newDB(dbPath, []string{"example.com:443"})
+	assert.NoError(t, first.close())
+
+	// migrating an up to date database should not change it
+	second := newDB(dbPath, []string{"example.com:443"})
+	defer second.conn.Close()
+
+	assert.Equal(t, []string{"4"}, queryRows(t, second.conn, "SELECT COUNT(*) FROM events"))
+	assert.Equal(t, []string{"4"}, queryRows(t, second.conn, "SELECT COUNT(*) FROM sessions"))
+}
+
+func TestMigrateNewerSchemaVersion(t *testing.T) {
+	conn, err := sqlite.OpenConn(":memory:", sqlite.OpenCreate, sqlite.OpenReadWrite)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, migrateDB(conn))
+
+	err = sqlitex.Execute(conn, "UPDATE metadata SET value = ? WHERE key = ?", &sqlitex.ExecOptions{
+		Args: []interface{}{strconv.Itoa(latestSchemaVersion + 1), schemaVersionKey},
+	})
+	assert.NoError(t, err)
+
+	err = migrateDB(conn)
+	assert.EqualError(t, err, fmt.Sprintf("the schema version %d of the database is newer than the version %d supported by this version of tcping",
+		latestSchemaVersion+1, latestSchemaVersion))
+}
+
+func TestMigrationRollback(t *testing.T) {
+	conn, err := sqlite.OpenConn(":memory:", sqlite.OpenCreate, sqlite.OpenReadWrite)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, sqlitex.ExecuteScript(conn, metadataSchema, nil))
+
+	failing := migration{
+		version:     1,
+		description: "fail halfway",
+		up: func(conn *sqlite.Conn) error {
+			if err := sqlitex.Execute(conn, "CREATE TABLE halfway (id INTEGER)", nil); err != nil {
+				return err
+			}
+			return fmt.Errorf("failed")
+		},
+	}
+	assert.Error(t, applyMigration(conn, failing))
+
+	// neither the changes nor the version should be kept
+	assert.Empty(t, queryRows(t, conn, "SELECT name FROM sqlite_master WHERE name = 'halfway'"))
+	version, err := schemaVersion(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+}
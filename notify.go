@@ -0,0 +1,188 @@
+// notify.go reports the targets going down and coming back up
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	stateChangeDown     = "down"
+	stateChangeUp       = "up"
+	stateChangeIPChange = "ip_change"
+)
+
+// stateChange describes a target going down, coming back up
+// or its hostname resolving to a different IP address.
+type stateChange struct {
+	Event    string `json:"event"`
+	Hostname string `json:"hostname"`
+	IP       string `json:"ip"`
+	// PreviousIP is only set for the "ip_change" event.
+	PreviousIP string    `json:"previous_ip,omitempty"`
+	Port       uint16    `json:"port"`
+	Timestamp  time.Time `json:"timestamp"`
+	// FailedProbes is the number of consecutive failed probes.
+	FailedProbes uint `json:"failed_probes"`
+	// Downtime is how long the target has been down, in seconds.
+	// For the "up" event, it's the total duration of the downtime.
+	Downtime float64 `json:"downtime_seconds"`
+}
+
+// notifier sends the state changes of the targets somewhere.
+type notifier interface {
+	// notify sends the state change in the background,
+	// so that it doesn't delay the probes.
+	notify(change stateChange)
+	// flush waits for the pending notifications, up to the timeout.
+	flush(timeout time.Duration)
+}
+
+const (
+	// flushTimeout is how long pending notifications
+	// are waited for before tcping exits.
+	flushTimeout = 5 * time.Second
+	// notificationQueueSize is the number of notifications that can wait to be sent.
+	// Further notifications are dropped, so that probing never blocks.
+	notificationQueueSize = 64
+)
+
+// newErrorReporter returns the onError of the notifiers, which reports their errors
+// through the printer while the probes go on. The database printer exits on errors,
+// so they're written to stderr instead, keeping the session going.
+func newErrorReporter(p printer) func(format string, args ...any) {
+	return func(format string, args ...any) {
+		stateLock.Lock()
+		defer stateLock.Unlock()
+
+		if _, ok := p.(*database); ok {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+			return
+		}
+		p.printError(format, args...)
+	}
+}
+
+// notificationQueue sends notifications one by one in the background,
+// keeping their order. It implements the notifier interface for
+// the notifiers embedding it.
+type notificationQueue struct {
+	name    string
+	queue   chan stateChange
+	pending sync.WaitGroup
+	send    func(change stateChange) error
+	// onError reports the notifications that could not be sent.
+	onError func(format string, args ...any)
+}
+
+// newNotificationQueue creates a queue and starts sending its notifications.
+func newNotificationQueue(name string, send func(change stateChange) error, onError func(format string, args ...any)) *notificationQueue {
+	q := &notificationQueue{
+		name:    name,
+		queue:   make(chan stateChange, notificationQueueSize),
+		send:    send,
+		onError: onError,
+	}
+
+	go q.run()
+
+	return q
+}
+
+func (q *notificationQueue) notify(change stateChange) {
+	q.pending.Add(1)
+
+	select {
+	case q.queue <- change:
+	default:
+		q.pending.Done()
+		// the caller may hold the lock that onError takes
+		go q.onError("The %s queue is full, dropped the %q notification of %s", q.name, change.Event, change.Hostname)
+	}
+}
+
+func (q *notificationQueue) flush(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		q.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// run sends the queued notifications.
+func (q *notificationQueue) run() {
+	for change := range q.queue {
+		if err := q.send(change); err != nil {
+			q.onError("Failed to send the %q notification of %s through the %s: %s", change.Event, change.Hostname, q.name, err)
+		}
+		q.pending.Done()
+	}
+}
+
+// newStateChange creates a state change event for the target.
+func (t *tcping) newStateChange(event string, when time.Time, failedProbes uint, downtime time.Duration) stateChange {
+	return stateChange{
+		Event:        event,
+		Hostname:     t.userInput.hostname,
+		IP:           t.userInput.ip.String(),
+		Port:         t.userInput.port,
+		Timestamp:    when,
+		FailedProbes: failedProbes,
+		Downtime:     downtime.Seconds(),
+	}
+}
+
+// notifyDown notifies once the target has failed as many consecutive
+// probes as required. It should be called after every failed probe.
+func (t *tcping) notifyDown(when time.Time) {
+	if len(t.notifiers) == 0 || t.notifiedDown || t.ongoingUnsuccessfulProbes < t.userInput.alertAfter {
+		return
+	}
+
+	t.notifiedDown = true
+	change := t.newStateChange(stateChangeDown, when, t.ongoingUnsuccessfulProbes, when.Sub(t.startOfDowntime))
+
+	for _, n := range t.notifiers {
+		n.notify(change)
+	}
+}
+
+// notifyUp notifies that the target is up again, if it was notified to be down.
+// It should be called on the first successful probe after a downtime.
+func (t *tcping) notifyUp(when time.Time, failedProbes uint, downtime time.Duration) {
+	if !t.notifiedDown {
+		return
+	}
+
+	t.notifiedDown = false
+	change := t.newStateChange(stateChangeUp, when, failedProbes, downtime)
+
+	for _, n := range t.notifiers {
+		n.notify(change)
+	}
+}
+
+// notifyIPChange notifies that the hostname resolved to a different IP address,
+// after the given number of consecutive failed probes.
+func (t *tcping) notifyIPChange(when time.Time, previousIP string, failedProbes uint) {
+	change := t.newStateChange(stateChangeIPChange, when, failedProbes, 0)
+	change.PreviousIP = previousIP
+
+	for _, n := range t.notifiers {
+		n.notify(change)
+	}
+}
+
+// flushNotifiers waits for the pending notifications of all notifiers.
+func flushNotifiers(notifiers []notifier) {
+	for _, n := range notifiers {
+		n.flush(flushTimeout)
+	}
+}
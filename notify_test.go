@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingNotifier is a fake notifier that records the state changes.
+type recordingNotifier struct {
+	changes []stateChange
+}
+
+func (r *recordingNotifier) notify(change stateChange) { r.changes = append(r.changes, change) }
+func (r *recordingNotifier) flush(_ time.Duration)     {}
+
+func TestNotifyStateChanges(t *testing.T) {
+	tests := []struct {
+		name       string
+		alertAfter uint
+		// probes lists the probe results in order, true being a successful probe
+		probes     []bool
+		wantEvents []string
+	}{
+		{
+			name:       "no downtime",
+			alertAfter: 1,
+			probes:     []bool{true, true, true},
+		},
+		{
+			name:       "down and up",
+			alertAfter: 1,
+			probes:     []bool{true, false, false, true, true},
+			wantEvents: []string{stateChangeDown, stateChangeUp},
+		},
+		{
+			name:       "debounced short downtime",
+			alertAfter: 3,
+			probes:     []bool{true, false, false, true, false, true},
+		},
+		{
+			name:       "debounced long downtime",
+			alertAfter: 3,
+			probes:     []bool{false, false, false, false, true},
+			wantEvents: []string{stateChangeDown, stateChangeUp},
+		},
+		{
+			name:       "flapping",
+			alertAfter: 1,
+			probes:     []bool{false, true, false, true},
+			wantEvents: []string{stateChangeDown, stateChangeUp, stateChangeDown, stateChangeUp},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &recordingNotifier{}
+			stats := createTestStats(t)
+			stats.notifiers = []notifier{recorder}
+			stats.userInput.alertAfter = tt.alertAfter
+
+			now := time.Now()
+			for i, success := range tt.probes {
+				probeTime := now.Add(time.Duration(i) * time.Second)
+				if success {
+					stats.handleConnSuccess("127.0.0.1:4567", 1, probeTime, time.Second, probeDetails{})
+				} else {
+					stats.handleConnError(probeTime, time.Second, probeDetails{})
+				}
+			}
+
+			var events []string
+			for _, change := range recorder.changes {
+				events = append(events, change.Event)
+			}
+			assert.Equal(t, tt.wantEvents, events)
+		})
+	}
+}
+
+func TestNotifyStateChangeDetails(t *testing.T) {
+	recorder := &recordingNotifier{}
+	stats := createTestStats(t)
+	stats.userInput.hostname = "example.com"
+	stats.notifiers = []notifier{recorder}
+	stats.userInput.alertAfter = 2
+
+	now := time.Now()
+	stats.handleConnError(now, time.Second, probeDetails{})
+	stats.handleConnError(now.Add(time.Second), time.Second, probeDetails{})
+	stats.handleConnError(now.Add(2*time.Second), time.Second, probeDetails{})
+	stats.handleConnSuccess("127.0.0.1:4567", 1, now.Add(3*time.Second), time.Second, probeDetails{})
+
+	assert.Len(t, recorder.changes, 2)
+
+	down := recorder.changes[0]
+	assert.Equal(t, stateChangeDown, down.Event)
+	assert.Equal(t, "example.com", down.Hostname)
+	assert.Equal(t, "127.0.0.1", down.IP)
+	assert.Equal(t, uint16(12345), down.Port)
+	assert.Equal(t, uint(2), down.FailedProbes)
+	assert.Equal(t, float64(1), down.Downtime)
+
+	up := recorder.changes[1]
+	assert.Equal(t, stateChangeUp, up.Event)
+	assert.Equal(t, uint(3), up.FailedProbes)
+	assert.Equal(t, float64(3), up.Downtime)
+}
+
+func TestNotifyIPChange(t *testing.T) {
+	recorder := &recordingNotifier{}
+	stats := createTestStats(t)
+	stats.notifiers = []notifier{recorder}
+	stats.userInput.retryHostnameLookupAfter = 2
+	stats.hostnameChanges = []hostnameChange{{Addr: stats.userInput.ip, When: time.Now()}}
+
+	// the "hostname" resolves to itself, which differs from the current IP
+	stats.userInput.hostname = "127.0.0.2"
+
+	stats.handleConnError(time.Now(), time.Second, probeDetails{})
+	stats.handleConnError(time.Now(), time.Second, probeDetails{})
+	retryResolveHostname(stats)
+
+	assert.Len(t, recorder.changes, 2)
+	assert.Equal(t, stateChangeDown, recorder.changes[0].Event)
+
+	change := recorder.changes[1]
+	assert.Equal(t, stateChangeIPChange, change.Event)
+	assert.Equal(t, "127.0.0.2", change.IP)
+	assert.Equal(t, "127.0.0.1", change.PreviousIP)
+	assert.Equal(t, uint(2), change.FailedProbes)
+
+	// resolving to the same IP again is not a change
+	stats.handleConnError(time.Now(), time.Second, probeDetails{})
+	stats.handleConnError(time.Now(), time.Second, probeDetails{})
+	retryResolveHostname(stats)
+	assert.Len(t, recorder.changes, 2)
+}
+
+func TestNotifierErrorsWithDatabase(t *testing.T) {
+	read, write, err := os.Pipe()
+	assert.NoError(t, err)
+
+	stderr := os.Stderr
+	os.Stderr = write
+	t.Cleanup(func() { os.Stderr = stderr })
+
+	// the database printer exits on errors, which would end the test
+	q := newNotificationQueue("hook command", func(stateChange) error { return errors.New("exit status 1") }, newErrorReporter(&database{}))
+	q.notify(stateChange{Event: stateChangeDown, Hostname: "example.com"})
+	q.flush(time.Second)
+
+	write.Close()
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, read)
+	assert.NoError(t, err)
+	assert.Equal(t, "Failed to send the \"down\" notification of example.com through the hook command: exit status 1\n", buf.String())
+}
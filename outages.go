@@ -0,0 +1,97 @@
+// outages.go keeps track of every downtime of a target
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// outage is a window of consecutive failed probes
+type outage struct {
+	start        time.Time
+	end          time.Time
+	duration     time.Duration
+	failedProbes uint
+	ip           netip.Addr // ip is the address of the target when it went down
+	ongoing      bool       // ongoing is set when the target has not come back up yet
+}
+
+// outageSummary describes how often a target went down and how fast it recovered
+type outageSummary struct {
+	count int
+	mttr  time.Duration // mttr is the mean time to recovery, i.e. the average duration of an outage
+	mtbf  time.Duration // mtbf is the mean time between failures, i.e. the uptime per outage
+}
+
+// startOutage begins a new outage at the first failed probe
+func (t *tcping) startOutage(when time.Time) {
+	t.currentOutage = outage{
+		start: when,
+		ip:    t.userInput.ip,
+	}
+}
+
+// endOutage closes the current outage when the target comes back up
+// and adds it to the list of outages.
+func (t *tcping) endOutage(when time.Time) {
+	o := t.currentOutage
+	o.end = when
+	o.duration = when.Sub(o.start)
+
+	t.outages = append(t.outages, o)
+	t.currentOutage = outage{}
+}
+
+// allOutages returns the finished outages along with
+// the ongoing one, if the target is still down.
+func (t *tcping) allOutages() []outage {
+	if !t.destWasDown || t.currentOutage.start.IsZero() {
+		return t.outages
+	}
+
+	end := t.endTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	o := t.currentOutage
+	o.end = end
+	o.duration = end.Sub(o.start)
+	o.ongoing = true
+
+	outages := make([]outage, len(t.outages), len(t.outages)+1)
+	copy(outages, t.outages)
+
+	return append(outages, o)
+}
+
+// summarizeOutages calculates the MTTR and MTBF of the given outages
+func summarizeOutages(outages []outage, totalUptime time.Duration) outageSummary {
+	if len(outages) == 0 {
+		return outageSummary{}
+	}
+
+	var totalOutage time.Duration
+	for _, o := range outages {
+		totalOutage += o.duration
+	}
+
+	count := time.Duration(len(outages))
+
+	return outageSummary{
+		count: len(outages),
+		mttr:  totalOutage / count,
+		mtbf:  totalUptime / count,
+	}
+}
+
+// formatOutageSummary returns a summary such as "5 outages, MTTR 12 seconds, MTBF 40 minutes"
+func formatOutageSummary(s outageSummary) string {
+	noun := "outages"
+	if s.count == 1 {
+		noun = "outage"
+	}
+
+	return fmt.Sprintf("%d %s, MTTR %s, MTBF %s", s.count, noun, durationToString(s.mttr), durationToString(s.mtbf))
+}
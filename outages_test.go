@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutageTracking(t *testing.T) {
+	stats := createTestStats(t)
+
+	// probes lists the probe results in order, true being a successful probe
+	probes := []bool{true, false, false, true, true, false, true}
+
+	now := time.Now()
+	for i, success := range probes {
+		probeTime := now.Add(time.Duration(i) * time.Second)
+		if success {
+			stats.handleConnSuccess("127.0.0.1:4567", 1, probeTime, time.Second, probeDetails{})
+		} else {
+			stats.handleConnError(probeTime, time.Second, probeDetails{})
+		}
+	}
+
+	want := []outage{
+		{
+			start:        now.Add(1 * time.Second),
+			end:          now.Add(3 * time.Second),
+			duration:     2 * time.Second,
+			failedProbes: 2,
+			ip:           netip.MustParseAddr("127.0.0.1"),
+		},
+		{
+			start:        now.Add(5 * time.Second),
+			end:          now.Add(6 * time.Second),
+			duration:     time.Second,
+			failedProbes: 1,
+			ip:           netip.MustParseAddr("127.0.0.1"),
+		},
+	}
+	assert.Equal(t, want, stats.outages)
+	assert.Equal(t, want, stats.allOutages())
+}
+
+func TestAllOutagesOngoing(t *testing.T) {
+	stats := createTestStats(t)
+
+	now := time.Now()
+	stats.handleConnError(now, time.Second, probeDetails{})
+	stats.handleConnSuccess("127.0.0.1:4567", 1, now.Add(time.Second), time.Second, probeDetails{})
+	stats.handleConnError(now.Add(2*time.Second), time.Second, probeDetails{})
+	stats.handleConnError(now.Add(3*time.Second), time.Second, probeDetails{})
+	stats.endTime = now.Add(10 * time.Second)
+
+	outages := stats.allOutages()
+	assert.Len(t, outages, 2)
+	assert.Len(t, stats.outages, 1, "the ongoing outage should not be recorded as finished")
+
+	ongoing := outages[1]
+	assert.True(t, ongoing.ongoing)
+	assert.Equal(t, now.Add(2*time.Second), ongoing.start)
+	assert.Equal(t, stats.endTime, ongoing.end)
+	assert.Equal(t, 8*time.Second, ongoing.duration)
+	assert.Equal(t, uint(2), ongoing.failedProbes)
+}
+
+func TestSummarizeOutages(t *testing.T) {
+	tests := []struct {
+		name        string
+		outages     []outage
+		totalUptime time.Duration
+		want        outageSummary
+	}{
+		{
+			name:        "no outages",
+			totalUptime: time.Hour,
+			want:        outageSummary{},
+		},
+		{
+			name:        "single outage",
+			outages:     []outage{{duration: 12 * time.Second}},
+			totalUptime: 40 * time.Minute,
+			want:        outageSummary{count: 1, mttr: 12 * time.Second, mtbf: 40 * time.Minute},
+		},
+		{
+			name: "multiple outages",
+			outages: []outage{
+				{duration: 10 * time.Second},
+				{duration: 20 * time.Second},
+				{duration: 30 * time.Second},
+			},
+			totalUptime: 6 * time.Minute,
+			want:        outageSummary{count: 3, mttr: 20 * time.Second, mtbf: 2 * time.Minute},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, summarizeOutages(tt.outages, tt.totalUptime))
+		})
+	}
+}
+
+func TestFormatOutageSummary(t *testing.T) {
+	tests := []struct {
+		summary outageSummary
+		want    string
+	}{
+		{
+			summary: outageSummary{count: 1, mttr: 12 * time.Second, mtbf: 40 * time.Minute},
+			want:    "1 outage, MTTR 12 seconds, MTBF 40 minutes 0 seconds",
+		},
+		{
+			summary: outageSummary{count: 5, mttr: 500 * time.Millisecond, mtbf: time.Hour},
+			want:    "5 outages, MTTR 0.5 seconds, MTBF 1 hour",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatOutageSummary(tt.summary))
+	}
+}
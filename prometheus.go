@@ -0,0 +1,195 @@
+// prometheus.go exposes the statistics in the Prometheus text format
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsPath is where the metrics are served.
+const metricsPath = "/metrics"
+
+// metric describes a single metric family.
+type metric struct {
+	name       string
+	help       string
+	metricType string
+	value      func(t *tcping) float64
+}
+
+// metrics are the counters and gauges exposed for every target.
+var metrics = []metric{
+	{
+		name:       "tcping_successful_probes_total",
+		help:       "Total number of successful probes.",
+		metricType: "counter",
+		value:      func(t *tcping) float64 { return float64(t.totalSuccessfulProbes) },
+	},
+	{
+		name:       "tcping_unsuccessful_probes_total",
+		help:       "Total number of unsuccessful probes.",
+		metricType: "counter",
+		value:      func(t *tcping) float64 { return float64(t.totalUnsuccessfulProbes) },
+	},
+	{
+		name:       "tcping_up",
+		help:       "Whether the last probe was successful.",
+		metricType: "gauge",
+		value: func(t *tcping) float64 {
+			if t.totalSuccessfulProbes == 0 || t.destWasDown {
+				return 0
+			}
+			return 1
+		},
+	},
+	{
+		name:       "tcping_successful_probes_streak",
+		help:       "Number of consecutive successful probes, zero while the target is down.",
+		metricType: "gauge",
+		value: func(t *tcping) float64 {
+			if t.destWasDown {
+				return 0
+			}
+			return float64(t.ongoingSuccessfulProbes)
+		},
+	},
+	{
+		name:       "tcping_unsuccessful_probes_streak",
+		help:       "Number of consecutive unsuccessful probes, zero while the target is up.",
+		metricType: "gauge",
+		value: func(t *tcping) float64 {
+			if !t.destWasDown {
+				return 0
+			}
+			return float64(t.ongoingUnsuccessfulProbes)
+		},
+	},
+	{
+		name:       "tcping_last_rtt_milliseconds",
+		help:       "RTT of the last successful probe in milliseconds.",
+		metricType: "gauge",
+		value:      func(t *tcping) float64 { return t.rtt.last },
+	},
+	{
+		name:       "tcping_uptime_seconds_total",
+		help:       "Total time the target was up in seconds.",
+		metricType: "counter",
+		value:      func(t *tcping) float64 { return t.totalUptime.Seconds() },
+	},
+	{
+		name:       "tcping_downtime_seconds_total",
+		help:       "Total time the target was down in seconds.",
+		metricType: "counter",
+		value:      func(t *tcping) float64 { return t.totalDowntime.Seconds() },
+	},
+	{
+		name:       "tcping_hostname_changes_total",
+		help:       "Number of times the hostname resolved to a different IP address.",
+		metricType: "counter",
+		value:      func(t *tcping) float64 { return float64(t.ipChanges()) },
+	},
+	{
+		name:       "tcping_retried_hostname_lookups_total",
+		help:       "Number of times the hostname was resolved again after failed probes.",
+		metricType: "counter",
+		value:      func(t *tcping) float64 { return float64(t.retriedHostnameLookups) },
+	},
+}
+
+// rttHistogramName is the name of the RTT histogram metric family.
+const rttHistogramName = "tcping_rtt_milliseconds"
+
+// escapeLabelValue escapes a label value as required by the text format.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatMetricValue formats a float the way Prometheus expects it.
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// targetLabels returns the labels identifying the target.
+func targetLabels(t *tcping) string {
+	return fmt.Sprintf(`hostname="%s",ip="%s",port="%d"`,
+		escapeLabelValue(t.userInput.hostname), t.userInput.ip, t.userInput.port)
+}
+
+// writeMetrics writes the metrics of all targets in the Prometheus text format.
+func writeMetrics(w io.Writer, list *targetList) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	// the targets removed from the SRV records may come back with the same labels
+	targets := make([]*tcping, 0, len(list.targets))
+	for _, t := range list.targets {
+		if !t.isStopped() {
+			targets = append(targets, t)
+		}
+	}
+
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.metricType)
+
+		for _, t := range targets {
+			fmt.Fprintf(w, "%s{%s} %s\n", m.name, targetLabels(t), formatMetricValue(m.value(t)))
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP %s RTT of the successful probes in milliseconds.\n", rttHistogramName)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", rttHistogramName)
+
+	for _, t := range targets {
+		labels := targetLabels(t)
+
+		var cumulative uint64
+		for i, bound := range rttHistogramBounds {
+			cumulative += t.rtt.histogram[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", rttHistogramName, labels, formatMetricValue(bound), cumulative)
+		}
+
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", rttHistogramName, labels, t.rtt.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", rttHistogramName, labels, formatMetricValue(t.rtt.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", rttHistogramName, labels, t.rtt.count)
+	}
+}
+
+// newMetricsHandler returns a handler serving the metrics of the targets.
+func newMetricsHandler(targets *targetList) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, func(w http.ResponseWriter, _ *http.Request) {
+		// a slow scraper must not hold the lock of the probes
+		var buf bytes.Buffer
+		writeMetrics(&buf, targets)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+
+	return mux
+}
+
+// startMetricsServer serves the metrics of the targets on the given address
+// in the background. It returns the address it's listening on.
+func startMetricsServer(listenAddr string, targets *targetList) (net.Addr, error) {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{
+		Handler:           newMetricsHandler(targets),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go server.Serve(listener)
+
+	return listener.Addr(), nil
+}
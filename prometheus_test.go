@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	stats := createTestStats(t)
+	stats.userInput.hostname = "example.com"
+	stats.hostnameChanges = []hostnameChange{{Addr: stats.userInput.ip, When: time.Now()}}
+
+	now := time.Now()
+	stats.handleConnSuccess("127.0.0.1:4567", 0.5, now, time.Second, probeDetails{})
+	stats.handleConnSuccess("127.0.0.1:4567", 7, now, time.Second, probeDetails{})
+	stats.handleConnSuccess("127.0.0.1:4567", 7000, now, time.Second, probeDetails{})
+	stats.handleConnError(now, 2*time.Second, probeDetails{})
+	stats.handleConnError(now, 2*time.Second, probeDetails{})
+
+	var sb strings.Builder
+	writeMetrics(&sb, &targetList{targets: []*tcping{stats}})
+	output := sb.String()
+
+	labels := `hostname="example.com",ip="127.0.0.1",port="12345"`
+	for _, line := range []string{
+		"# TYPE tcping_successful_probes_total counter",
+		"tcping_successful_probes_total{" + labels + "} 3",
+		"tcping_unsuccessful_probes_total{" + labels + "} 2",
+		"tcping_up{" + labels + "} 0",
+		"tcping_successful_probes_streak{" + labels + "} 0",
+		"tcping_unsuccessful_probes_streak{" + labels + "} 2",
+		"tcping_last_rtt_milliseconds{" + labels + "} 7000",
+		"tcping_uptime_seconds_total{" + labels + "} 3",
+		"tcping_downtime_seconds_total{" + labels + "} 4",
+		"tcping_hostname_changes_total{" + labels + "} 0",
+		"tcping_retried_hostname_lookups_total{" + labels + "} 0",
+		"# TYPE tcping_rtt_milliseconds histogram",
+		"tcping_rtt_milliseconds_bucket{" + labels + `,le="1"} 1`,
+		"tcping_rtt_milliseconds_bucket{" + labels + `,le="5"} 1`,
+		"tcping_rtt_milliseconds_bucket{" + labels + `,le="10"} 2`,
+		"tcping_rtt_milliseconds_bucket{" + labels + `,le="5000"} 2`,
+		"tcping_rtt_milliseconds_bucket{" + labels + `,le="+Inf"} 3`,
+		"tcping_rtt_milliseconds_sum{" + labels + "} 7007.5",
+		"tcping_rtt_milliseconds_count{" + labels + "} 3",
+	} {
+		assert.Contains(t, output, line+"\n")
+	}
+}
+
+func TestWriteMetricsStoppedTarget(t *testing.T) {
+	stats := createTestStats(t)
+	stats.userInput.hostname = "sip.example.com"
+	stats.stop = make(chan struct{})
+	close(stats.stop)
+
+	var sb strings.Builder
+	writeMetrics(&sb, &targetList{targets: []*tcping{stats}})
+	assert.NotContains(t, sb.String(), "sip.example.com", "targets removed from the SRV records are not exported")
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	assert.Equal(t, `a\\b\"c\nd`, escapeLabelValue("a\\b\"c\nd"))
+}
+
+// lockCheckingWriter is a ResponseWriter that records whether the lock was held while writing
+type lockCheckingWriter struct {
+	*httptest.ResponseRecorder
+	locked bool
+}
+
+func (w *lockCheckingWriter) Write(b []byte) (int, error) {
+	if stateLock.TryLock() {
+		stateLock.Unlock()
+	} else {
+		w.locked = true
+	}
+
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestMetricsHandlerUnlocked(t *testing.T) {
+	stats := createTestStats(t)
+	stats.userInput.hostname = "example.com"
+
+	w := &lockCheckingWriter{ResponseRecorder: httptest.NewRecorder()}
+	newMetricsHandler(&targetList{targets: []*tcping{stats}}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+
+	assert.False(t, w.locked, "the response is written without holding the lock")
+	assert.Contains(t, w.Body.String(), `tcping_up{hostname="example.com"`)
+}
+
+func TestMetricsServer(t *testing.T) {
+	stats := createTestStats(t)
+	stats.userInput.hostname = "example.com"
+	stats.handleConnSuccess("127.0.0.1:4567", 3, time.Now(), time.Second, probeDetails{})
+
+	addr, err := startMetricsServer("127.0.0.1:0", &targetList{targets: []*tcping{stats}})
+	if err != nil {
+		t.Fatalf("start metrics server: %v", err)
+	}
+
+	resp, err := http.Get("http://" + addr.String() + metricsPath)
+	if err != nil {
+		t.Fatalf("scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+	assert.Contains(t, string(body), `tcping_up{hostname="example.com",ip="127.0.0.1",port="12345"} 1`)
+	assert.Contains(t, string(body), `tcping_successful_probes_streak{hostname="example.com",ip="127.0.0.1",port="12345"} 1`)
+}
@@ -0,0 +1,609 @@
+// report.go recomputes the statistics of the probes saved by an earlier run
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// reportFormat is the output format of tcping the probes are read from
+type reportFormat string
+
+const (
+	reportFormatCSV  reportFormat = "csv"
+	reportFormatJSON reportFormat = "json"
+	reportFormatDB   reportFormat = "db"
+)
+
+// sqliteHeader is the beginning of every sqlite database file
+const sqliteHeader = "SQLite format 3\x00"
+
+// reportTimeFormats are the accepted formats of the time window,
+// interpreted in the local time zone unless they include one.
+var reportTimeFormats = []string{
+	timeFormat,
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC3339,
+}
+
+// savedProbe is a single probe read from the output of an earlier run
+type savedProbe struct {
+	timestamp     time.Time
+	session       int64 // session is the row of the run in the sessions table, only set for sqlite databases
+	hostname      string
+	addr          netip.Addr
+	port          uint16
+	success       bool
+	rtt           float32
+	failureReason failureReason
+}
+
+// target returns the target of the probe in the <hostname:port> format
+func (p savedProbe) target() string {
+	return net.JoinHostPort(p.hostname, strconv.Itoa(int(p.port)))
+}
+
+// target returns the target of the statistics in the <hostname:port> format,
+// like the targets of the saved probes.
+func (t *tcping) target() string {
+	return net.JoinHostPort(t.userInput.hostname, strconv.Itoa(int(t.userInput.port)))
+}
+
+// reportFilter selects the probes to include in a report.
+// Zero values don't filter anything.
+type reportFilter struct {
+	from    time.Time // from is the time of the first probe to include
+	to      time.Time // to is the time right after the last probe to include
+	target  string    // target is the <hostname:port> of the probes to include
+	session int64
+}
+
+// matches reports whether the probe should be included in the report
+func (f reportFilter) matches(p savedProbe) bool {
+	if !f.from.IsZero() && p.timestamp.Before(f.from) {
+		return false
+	}
+
+	if !f.to.IsZero() && !p.timestamp.Before(f.to) {
+		return false
+	}
+
+	if f.target != "" && p.target() != f.target {
+		return false
+	}
+
+	if f.session != 0 && p.session != f.session {
+		return false
+	}
+
+	return true
+}
+
+// replayPrinter hides the probe messages while the saved probes are replayed.
+// The other methods are not called during a replay,
+// the statistics are printed with the chosen printer afterwards.
+type replayPrinter struct {
+	printer
+}
+
+func (replayPrinter) printProbeSuccess(_ string, _ userInput, _ uint, _ float32, _ probeDetails) {}
+func (replayPrinter) printProbeFail(_ userInput, _ uint, _ probeDetails)                         {}
+func (replayPrinter) printTotalDownTime(_ userInput, _ time.Duration)                            {}
+
+// parseReportTime parses the start or the end of the time window of a report
+func parseReportTime(value string) (time.Time, error) {
+	for _, layout := range reportTimeFormats {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a time like %q", value, timeFormat)
+}
+
+// detectReportFormat guesses the format of the file from its extension,
+// or from its content when the extension is unknown.
+func detectReportFormat(filename string) (reportFormat, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return reportFormatCSV, nil
+	case ".json", ".jsonl", ".ndjson":
+		return reportFormatJSON, nil
+	case ".db", ".sqlite", ".sqlite3":
+		return reportFormatDB, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, len(sqliteHeader))
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	head = head[:n]
+
+	switch {
+	case string(head) == sqliteHeader:
+		return reportFormatDB, nil
+	case bytes.HasPrefix(bytes.TrimSpace(head), []byte("{")):
+		return reportFormatJSON, nil
+	default:
+		return reportFormatCSV, nil
+	}
+}
+
+// readSavedProbes reads the probes from the file in the given format
+func readSavedProbes(filename string, format reportFormat) ([]savedProbe, error) {
+	if format == reportFormatDB {
+		return readDBProbes(filename)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if format == reportFormatJSON {
+		return readJSONProbes(file)
+	}
+
+	return readCSVProbes(file)
+}
+
+// readCSVProbes reads the probes written by the csvPrinter.
+// The other records, e.g. the hostname lookups, are skipped.
+func readCSVProbes(r io.Reader) ([]savedProbe, error) {
+	reader := csv.NewReader(r)
+	// the records of the hostname lookups have fewer fields than the probes
+	reader.FieldsPerRecord = -1
+
+	var probes []savedProbe
+	var columns map[string]int
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		// the header is written again when the file was recreated
+		if record[0] == colStatus {
+			columns = make(map[string]int, len(record))
+			for i, name := range record {
+				columns[name] = i
+			}
+
+			if _, ok := columns[colTimestamp]; !ok {
+				return nil, fmt.Errorf("the CSV file has no %q column, the probes should be saved with the -D flag", colTimestamp)
+			}
+			continue
+		}
+
+		if columns == nil {
+			return nil, fmt.Errorf("the CSV file has no header on line %d", line)
+		}
+
+		status := record[0]
+		if status != "Reply" && !strings.HasPrefix(status, "No reply") {
+			continue
+		}
+
+		probe, err := parseCSVProbe(record, columns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid probe on line %d: %w", line, err)
+		}
+		probes = append(probes, probe)
+	}
+
+	return probes, nil
+}
+
+// parseCSVProbe parses a probe record with the given column indexes
+func parseCSVProbe(record []string, columns map[string]int) (savedProbe, error) {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	timestamp, err := time.ParseInLocation(timeFormat, field(colTimestamp), time.Local)
+	if err != nil {
+		return savedProbe{}, fmt.Errorf("invalid timestamp %q", field(colTimestamp))
+	}
+
+	port, err := strconv.ParseUint(field(colPort), 10, 16)
+	if err != nil {
+		return savedProbe{}, fmt.Errorf("invalid port %q", field(colPort))
+	}
+
+	addr, err := netip.ParseAddr(field(colIP))
+	if err != nil {
+		return savedProbe{}, fmt.Errorf("invalid IP address %q", field(colIP))
+	}
+
+	probe := savedProbe{
+		timestamp: timestamp,
+		hostname:  field(colHostname),
+		addr:      addr,
+		port:      uint16(port),
+		success:   record[0] == "Reply",
+	}
+
+	if probe.success {
+		rtt, err := strconv.ParseFloat(field(colLatency), 32)
+		if err != nil {
+			return savedProbe{}, fmt.Errorf("invalid latency %q", field(colLatency))
+		}
+		probe.rtt = float32(rtt)
+	} else {
+		// failed probes are written as "No reply (timeout)"
+		reason := strings.TrimPrefix(record[0], "No reply")
+		reason = strings.Trim(strings.TrimSpace(reason), "()")
+		probe.failureReason = failureReason(reason)
+	}
+
+	return probe, nil
+}
+
+// readJSONProbes reads the probe events written by the jsonPrinter,
+// either one per line or indented with the --pretty flag.
+// The other events are skipped.
+func readJSONProbes(r io.Reader) ([]savedProbe, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	var probes []savedProbe
+	for {
+		var data JSONData
+		err := decoder.Decode(&data)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JSON: %w", err)
+		}
+
+		// the probes of a previous IP address are not a part of the statistics
+		if data.Type != probeEvent || data.Success == nil || data.Draining {
+			continue
+		}
+
+		addr, err := netip.ParseAddr(data.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q in the probe at %s", data.Addr, data.Timestamp.Format(timeFormat))
+		}
+
+		probes = append(probes, savedProbe{
+			timestamp:     data.Timestamp,
+			hostname:      data.Hostname,
+			addr:          addr,
+			port:          data.Port,
+			success:       *data.Success,
+			rtt:           data.Rtt,
+			failureReason: failureReason(data.FailureReason),
+		})
+	}
+
+	return probes, nil
+}
+
+// readDBProbes reads the probes table of a sqlite database,
+// along with the sessions they belong to.
+func readDBProbes(filename string) ([]savedProbe, error) {
+	// opening a missing file would create an empty database
+	if _, err := os.Stat(filename); err != nil {
+		return nil, err
+	}
+
+	conn, err := sqlite.OpenConn(filename, sqlite.OpenReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	columns, err := tableColumns(conn, "probes")
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("the database has no probes table, it should be opened with the --db flag of this version of tcping first")
+	}
+
+	query := `SELECT session_id, timestamp, hostname, addr, port, success, rtt, failure_reason
+	FROM probes ORDER BY id`
+
+	var probes []savedProbe
+	err = sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			timestamp, err := time.ParseInLocation(timeFormat, stmt.ColumnText(1), time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid timestamp %q", stmt.ColumnText(1))
+			}
+
+			addr, err := netip.ParseAddr(stmt.ColumnText(3))
+			if err != nil {
+				return fmt.Errorf("invalid IP address %q", stmt.ColumnText(3))
+			}
+
+			probes = append(probes, savedProbe{
+				session:       stmt.ColumnInt64(0),
+				timestamp:     timestamp,
+				hostname:      stmt.ColumnText(2),
+				addr:          addr,
+				port:          uint16(stmt.ColumnInt(4)),
+				success:       stmt.ColumnBool(5),
+				rtt:           float32(stmt.ColumnFloat(6)),
+				failureReason: failureReason(stmt.ColumnText(7)),
+			})
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the probes: %w", err)
+	}
+
+	return probes, nil
+}
+
+// parseReportTarget returns the given <hostname/ip:port> target
+// in the same format as the target of the saved probes.
+func parseReportTarget(value string) (string, error) {
+	hostPort := parseHostPortArgs([]string{value})
+	if len(hostPort) != 2 {
+		return "", fmt.Errorf("invalid target %q, expected <hostname/ip:port>", value)
+	}
+
+	return net.JoinHostPort(hostPort[0], hostPort[1]), nil
+}
+
+// loadSavedProbes reads the probes of the file in the given format,
+// or in the detected one when it's empty, and returns the probes matching the filter.
+func loadSavedProbes(filename string, format reportFormat, filter reportFilter) ([]savedProbe, error) {
+	switch format {
+	case "":
+		var err error
+		if format, err = detectReportFormat(filename); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+	case reportFormatCSV, reportFormatJSON, reportFormatDB:
+	default:
+		return nil, fmt.Errorf("invalid format %q, expected csv, json or db", format)
+	}
+
+	if filter.session != 0 && format != reportFormatDB {
+		return nil, fmt.Errorf("sessions only apply to sqlite databases, which %s is not", filename)
+	}
+
+	probes, err := readSavedProbes(filename, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	var selected []savedProbe
+	for _, probe := range probes {
+		if filter.matches(probe) {
+			selected = append(selected, probe)
+		}
+	}
+
+	if len(probes) == 0 {
+		return nil, fmt.Errorf("no probes found in %s", filename)
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("none of the %d probes in %s match the given filters", len(probes), filename)
+	}
+
+	return selected, nil
+}
+
+// replayProbes recomputes the statistics of every target from its probes.
+// The targets are returned in the order of their first probe.
+func replayProbes(probes []savedProbe) []*tcping {
+	sort.SliceStable(probes, func(i, j int) bool {
+		return probes[i].timestamp.Before(probes[j].timestamp)
+	})
+
+	var targets []string
+	probesByTarget := make(map[string][]savedProbe)
+	for _, probe := range probes {
+		target := probe.target()
+		if _, ok := probesByTarget[target]; !ok {
+			targets = append(targets, target)
+		}
+		probesByTarget[target] = append(probesByTarget[target], probe)
+	}
+
+	replayed := make([]*tcping, 0, len(targets))
+	for _, target := range targets {
+		t := &tcping{printer: replayPrinter{}}
+		t.replay(probesByTarget[target])
+		replayed = append(replayed, t)
+	}
+
+	return replayed
+}
+
+// replay feeds the probes of a single target to the same bookkeeping
+// as the live probes, then calculates the statistics at the last probe.
+//
+// Each probe lasts until the next one of the same session,
+// so the gaps between the sessions are not counted as uptime or downtime.
+func (t *tcping) replay(probes []savedProbe) {
+	first := probes[0]
+	t.userInput.hostname = first.hostname
+	t.userInput.port = first.port
+	t.userInput.ip = first.addr
+	t.destIsIP = first.hostname == first.addr.String()
+	t.startTime = first.timestamp
+	t.hostnameChanges = []hostnameChange{
+		{Addr: first.addr, When: first.timestamp},
+	}
+
+	var elapsed time.Duration
+	for i, probe := range probes {
+		// the last probe of a session lasts as long as the one before it
+		if i+1 < len(probes) && probes[i+1].session == probe.session {
+			elapsed = maxDuration(probes[i+1].timestamp.Sub(probe.timestamp), 0)
+		}
+
+		if probe.addr != t.userInput.ip {
+			t.userInput.ip = probe.addr
+			t.hostnameChanges = append(t.hostnameChanges, hostnameChange{
+				Addr: probe.addr,
+				When: probe.timestamp,
+			})
+		}
+
+		if probe.success {
+			t.handleConnSuccess("", probe.rtt, probe.timestamp, elapsed, probeDetails{})
+		} else {
+			t.handleConnError(probe.timestamp, elapsed, probeDetails{failureReason: probe.failureReason})
+		}
+	}
+
+	t.endTime = probes[len(probes)-1].timestamp.Add(elapsed)
+
+	if t.destWasDown {
+		calcLongestDowntime(t, t.endTime.Sub(t.startOfDowntime))
+	} else {
+		calcLongestUptime(t, t.endTime.Sub(t.startOfUptime))
+	}
+	t.rttResults = t.rtt.result()
+}
+
+// reportUsage prints how the report subcommand should be run
+func reportUsage(flagSet *flag.FlagSet) {
+	executableName := os.Args[0]
+
+	colorLightCyan("\nTCPING version %s\n\n", version)
+	colorRed("Try running %s report like:\n", executableName)
+	colorRed("%s report <filename>. For example:\n", executableName)
+	colorRed("%s report --from \"2026-10-16 02:00\" --to \"2026-10-16 03:00\" tcping.db\n", executableName)
+	colorRed("The file can be the output of the --csv (with -D), -j or --db flags.\n")
+	colorYellow("\n[optional flags]\n")
+
+	flagSet.VisitAll(func(f *flag.Flag) {
+		flagName := f.Name
+		if len(f.Name) > 1 {
+			flagName = "-" + flagName
+		}
+
+		colorYellow("  -%s : %s\n", flagName, f.Usage)
+	})
+
+	os.Exit(1)
+}
+
+// runReport reads the probes saved by an earlier run, recomputes
+// their statistics and prints them with the chosen printer.
+func runReport(args []string) {
+	flagSet := flag.NewFlagSet("report", flag.ExitOnError)
+
+	outputJSON := flagSet.Bool("j", false, "output in JSON format.")
+	prettyJSON := flagSet.Bool("pretty", false, "use indentation when using json output format. No effect without the '-j' flag.")
+	noColor := flagSet.Bool("no-color", false, "do not colorize output.")
+	saveToCSV := flagSet.String("csv", "", "path and file name to store the statistics to a CSV file, with _stats appended.")
+	outputDB := flagSet.String("db", "", "path and file name to store the statistics to sqlite database, as a new session.")
+	from := flagSet.String("from", "", "only include the probes since the given time, e.g. \"2026-10-16 02:00\".")
+	to := flagSet.String("to", "", "only include the probes before the given time, e.g. \"2026-10-16 03:00\".")
+	target := flagSet.String("target", "", "only include the probes of the given <hostname/ip:port>.")
+	session := flagSet.Int64("session", 0, "only include the probes of the given session of a sqlite database.")
+	format := flagSet.String("format", "", "format of the file: csv, json or db. Detected from the file by default.")
+	showHelp := flagSet.Bool("h", false, "show help message.")
+
+	flagSet.Usage = func() { reportUsage(flagSet) }
+
+	permuteArgs(flagSet, args)
+	flagSet.Parse(args)
+
+	if *showHelp || flagSet.NArg() != 1 {
+		reportUsage(flagSet)
+	}
+	filename := flagSet.Arg(0)
+
+	var filter reportFilter
+	var inputErr error
+
+	if *from != "" {
+		filter.from, inputErr = parseReportTime(*from)
+	}
+	if *to != "" && inputErr == nil {
+		filter.to, inputErr = parseReportTime(*to)
+	}
+	if inputErr == nil && !filter.from.IsZero() && !filter.to.IsZero() && !filter.to.After(filter.from) {
+		inputErr = fmt.Errorf("'--to' should be after '--from'")
+	}
+
+	if *target != "" && inputErr == nil {
+		filter.target, inputErr = parseReportTarget(*target)
+	}
+	filter.session = *session
+
+	// the CSV printer truncates the file of the probes
+	if inputErr == nil && *saveToCSV != "" {
+		input, _ := filepath.Abs(filename)
+		output, _ := filepath.Abs(addCSVExtension(*saveToCSV, false))
+		if input == output {
+			inputErr = fmt.Errorf("'--csv' should not overwrite %s", filename)
+		}
+	}
+
+	var probes []savedProbe
+	if inputErr == nil {
+		probes, inputErr = loadSavedProbes(filename, reportFormat(*format), filter)
+	}
+
+	// The printer is set once the targets are known, as the database printer
+	// starts a session of them. Errors are never written to the files.
+	base := &tcping{}
+	if inputErr != nil {
+		setTerminalPrinter(base, outputJSON, prettyJSON, noColor)
+		base.printError("%s", inputErr)
+		os.Exit(1)
+	}
+
+	replayed := replayProbes(probes)
+
+	targets := make([][]string, 0, len(replayed))
+	for _, t := range replayed {
+		targets = append(targets, []string{t.userInput.hostname, strconv.Itoa(int(t.userInput.port))})
+	}
+
+	disabled := false
+	setPrinter(base, outputJSON, prettyJSON, noColor, &disabled, &disabled, &disabled, &disabled, &disabled, &disabled, outputDB, saveToCSV, targets, "")
+
+	for _, t := range replayed {
+		t.printer = base.printer
+		t.printStatistics(*t)
+	}
+
+	closePrinter(base.printer)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadCSVProbes(t *testing.T) {
+	input := `Status,Hostname,IP,Port,TCP_Conn,Latency(ms),Timestamp
+Reply,example.com,93.184.216.34,443,1,12.500,2026-10-16 02:00:00
+Resolving,example.com,,,,
+No reply (timeout),example.com,93.184.216.34,443,1,,2026-10-16 02:00:01
+Status,Hostname,IP,Port,TCP_Conn,Latency(ms),Timestamp
+No reply,example.com,93.184.216.35,443,2,,2026-10-16 02:00:02
+`
+
+	probes, err := readCSVProbes(strings.NewReader(input))
+	assert.NoError(t, err)
+
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+	want := []savedProbe{
+		{
+			timestamp: start,
+			hostname:  "example.com",
+			addr:      netip.MustParseAddr("93.184.216.34"),
+			port:      443,
+			success:   true,
+			rtt:       12.5,
+		},
+		{
+			timestamp:     start.Add(time.Second),
+			hostname:      "example.com",
+			addr:          netip.MustParseAddr("93.184.216.34"),
+			port:          443,
+			failureReason: failureTimeout,
+		},
+		{
+			timestamp: start.Add(2 * time.Second),
+			hostname:  "example.com",
+			addr:      netip.MustParseAddr("93.184.216.35"),
+			port:      443,
+		},
+	}
+	assert.Equal(t, want, probes)
+}
+
+func TestReadCSVProbesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "no timestamps",
+			input: "Status,Hostname,IP,Port,TCP_Conn,Latency(ms)\nReply,example.com,93.184.216.34,443,1,12.500\n",
+			err:   `the CSV file has no "Timestamp" column, the probes should be saved with the -D flag`,
+		},
+		{
+			name:  "no header",
+			input: "Reply,example.com,93.184.216.34,443,1,12.500,2026-10-16 02:00:00\n",
+			err:   "the CSV file has no header on line 1",
+		},
+		{
+			name:  "invalid latency",
+			input: "Status,Hostname,IP,Port,TCP_Conn,Latency(ms),Timestamp\nReply,example.com,93.184.216.34,443,1,fast,2026-10-16 02:00:00\n",
+			err:   `invalid probe on line 2: invalid latency "fast"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readCSVProbes(strings.NewReader(tt.input))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestReadJSONProbes(t *testing.T) {
+	// the last probe is indented, like the output of the --pretty flag,
+	// and the probe of the previous IP address is skipped
+	input := `{"type":"start","message":"TCPinging example.com on port 443","timestamp":"2026-10-16T02:00:00Z","hostname":"example.com","port":443}
+{"type":"probe","message":"Reply from example.com (93.184.216.34) on port 443 time=12.500 ms","timestamp":"2026-10-16T02:00:00.5Z","addr":"93.184.216.34","hostname":"example.com","dst_is_ip":false,"port":443,"time":12.5,"success":true,"total_successful_probes":1}
+{"type":"probe","message":"Reply from example.com (93.184.216.35) on port 443 time=10.000 ms","timestamp":"2026-10-16T02:00:01Z","addr":"93.184.216.35","hostname":"example.com","dst_is_ip":false,"port":443,"time":10,"success":true,"draining":true,"total_successful_probes":1}
+{
+	"type": "probe",
+	"message": "No reply from example.com (93.184.216.34) on port 443",
+	"timestamp": "2026-10-16T02:00:01.5Z",
+	"addr": "93.184.216.34",
+	"hostname": "example.com",
+	"dst_is_ip": false,
+	"port": 443,
+	"success": false,
+	"failure_reason": "refused",
+	"total_unsuccessful_probes": 1
+}
+`
+
+	probes, err := readJSONProbes(strings.NewReader(input))
+	assert.NoError(t, err)
+
+	start := time.Date(2026, 10, 16, 2, 0, 0, 500*int(time.Millisecond), time.UTC)
+	want := []savedProbe{
+		{
+			timestamp: start,
+			hostname:  "example.com",
+			addr:      netip.MustParseAddr("93.184.216.34"),
+			port:      443,
+			success:   true,
+			rtt:       12.5,
+		},
+		{
+			timestamp:     start.Add(time.Second),
+			hostname:      "example.com",
+			addr:          netip.MustParseAddr("93.184.216.34"),
+			port:          443,
+			failureReason: failureRefused,
+		},
+	}
+
+	assert.Len(t, probes, len(want))
+	for i := range want {
+		assert.True(t, want[i].timestamp.Equal(probes[i].timestamp))
+		probes[i].timestamp = want[i].timestamp
+	}
+	assert.Equal(t, want, probes)
+}
+
+func TestReadDBProbes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tcping.db")
+	input := userInput{
+		hostname: "localhost",
+		ip:       netip.MustParseAddr("127.0.0.1"),
+		port:     8001,
+	}
+
+	first := newDB(dbPath, []string{"localhost:8001"})
+	first.printProbeSuccess("127.0.0.1:50000", input, 1, 1.5, probeDetails{probeTime: time.Now()})
+	first.printProbeFail(input, 1, probeDetails{failureReason: failureTimeout, probeTime: time.Now()})
+	assert.NoError(t, first.close())
+
+	second := newDB(dbPath, []string{"localhost:8001"})
+	second.printProbeSuccess("127.0.0.1:50001", input, 1, 2.25, probeDetails{probeTime: time.Now()})
+	assert.NoError(t, second.close())
+
+	probes, err := readDBProbes(dbPath)
+	assert.NoError(t, err)
+	assert.Len(t, probes, 3)
+
+	for i, probe := range probes {
+		assert.Equal(t, "localhost", probe.hostname)
+		assert.Equal(t, input.ip, probe.addr)
+		assert.Equal(t, uint16(8001), probe.port)
+		assert.WithinDuration(t, time.Now(), probe.timestamp, time.Minute, "probe %d", i)
+	}
+
+	assert.Equal(t, []int64{first.sessionID, first.sessionID, second.sessionID},
+		[]int64{probes[0].session, probes[1].session, probes[2].session})
+	assert.Equal(t, []bool{true, false, true}, []bool{probes[0].success, probes[1].success, probes[2].success})
+	assert.Equal(t, float32(1.5), probes[0].rtt)
+	assert.Equal(t, failureTimeout, probes[1].failureReason)
+	assert.Equal(t, float32(2.25), probes[2].rtt)
+}
+
+func TestReadDBProbesWithoutProbesTable(t *testing.T) {
+	_, err := readDBProbes(openFixtureDB(t, "v2.7.sql"))
+	assert.EqualError(t, err, "the database has no probes table, it should be opened with the --db flag of this version of tcping first")
+
+	_, err = readDBProbes(filepath.Join(t.TempDir(), "missing.db"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDetectReportFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		filename := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(filename, []byte(content), filePermission))
+		return filename
+	}
+
+	tests := []struct {
+		filename string
+		want     reportFormat
+	}{
+		{filename: write("probes.csv", ""), want: reportFormatCSV},
+		{filename: write("probes.JSON", ""), want: reportFormatJSON},
+		{filename: write("probes.sqlite3", ""), want: reportFormatDB},
+		{filename: write("probes.log", "\n  {\"type\":\"probe\"}"), want: reportFormatJSON},
+		{filename: write("probes", sqliteHeader+"rest of the database"), want: reportFormatDB},
+		{filename: write("probes.txt", "Status,Hostname,IP,Port"), want: reportFormatCSV},
+		{filename: write("empty", ""), want: reportFormatCSV},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.filename), func(t *testing.T) {
+			format, err := detectReportFormat(tt.filename)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, format)
+		})
+	}
+}
+
+func TestParseReportTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{value: "2026-10-16 02:30:15", want: time.Date(2026, 10, 16, 2, 30, 15, 0, time.Local)},
+		{value: "2026-10-16 02:30", want: time.Date(2026, 10, 16, 2, 30, 0, 0, time.Local)},
+		{value: "2026-10-16", want: time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)},
+		{value: "2026-10-16T02:30:00Z", want: time.Date(2026, 10, 16, 2, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseReportTime(tt.value)
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+
+	_, err := parseReportTime("last night")
+	assert.EqualError(t, err, `invalid time "last night", expected a time like "2006-01-02 15:04:05"`)
+}
+
+func TestReportFilter(t *testing.T) {
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+	probe := savedProbe{
+		timestamp: start.Add(30 * time.Minute),
+		hostname:  "example.com",
+		port:      443,
+		session:   2,
+	}
+
+	tests := []struct {
+		name   string
+		filter reportFilter
+		want   bool
+	}{
+		{name: "no filter", filter: reportFilter{}, want: true},
+		{name: "inside the window", filter: reportFilter{from: start, to: start.Add(time.Hour)}, want: true},
+		{name: "at the start of the window", filter: reportFilter{from: probe.timestamp}, want: true},
+		{name: "at the end of the window", filter: reportFilter{to: probe.timestamp}, want: false},
+		{name: "before the window", filter: reportFilter{from: start.Add(time.Hour)}, want: false},
+		{name: "same target", filter: reportFilter{target: "example.com:443"}, want: true},
+		{name: "other target", filter: reportFilter{target: "example.com:80"}, want: false},
+		{name: "same session", filter: reportFilter{session: 2}, want: true},
+		{name: "other session", filter: reportFilter{session: 1}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.matches(probe))
+		})
+	}
+}
+
+func TestReplayProbes(t *testing.T) {
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+	first := netip.MustParseAddr("93.184.216.34")
+	second := netip.MustParseAddr("93.184.216.35")
+
+	newProbe := func(seconds int, target string, addr netip.Addr, rtt float32) savedProbe {
+		probe := savedProbe{
+			timestamp: start.Add(time.Duration(seconds) * time.Second),
+			hostname:  target,
+			addr:      addr,
+			port:      443,
+			session:   1,
+			success:   rtt > 0,
+			rtt:       rtt,
+		}
+		if !probe.success {
+			probe.failureReason = failureTimeout
+		}
+		return probe
+	}
+
+	// the probes are out of order on purpose, like the targets of a CSV file
+	probes := []savedProbe{
+		newProbe(0, "example.com", first, 10),
+		newProbe(1, "example.com", first, 0),
+		newProbe(0, "localhost", netip.MustParseAddr("127.0.0.1"), 1),
+		newProbe(2, "example.com", first, 0),
+		newProbe(3, "example.com", second, 20),
+		newProbe(4, "example.com", second, 30),
+	}
+
+	replayed := replayProbes(probes)
+	assert.Len(t, replayed, 2)
+
+	target := replayed[0]
+	assert.Equal(t, "example.com", target.userInput.hostname)
+	assert.Equal(t, uint16(443), target.userInput.port)
+	assert.Equal(t, second, target.userInput.ip)
+	assert.False(t, target.destIsIP)
+
+	assert.Equal(t, uint(3), target.totalSuccessfulProbes)
+	assert.Equal(t, uint(2), target.totalUnsuccessfulProbes)
+	assert.Equal(t, map[failureReason]uint{failureTimeout: 2}, target.failureReasons)
+	assert.Equal(t, 3*time.Second, target.totalUptime)
+	assert.Equal(t, 2*time.Second, target.totalDowntime)
+
+	assert.Equal(t, start, target.startTime)
+	assert.Equal(t, start.Add(5*time.Second), target.endTime)
+	assert.Equal(t, longestTime{start.Add(3 * time.Second), start.Add(5 * time.Second), 2 * time.Second}, target.longestUptime)
+	assert.Equal(t, longestTime{start.Add(time.Second), start.Add(3 * time.Second), 2 * time.Second}, target.longestDowntime)
+
+	assert.Equal(t, []outage{
+		{
+			start:        start.Add(time.Second),
+			end:          start.Add(3 * time.Second),
+			duration:     2 * time.Second,
+			failedProbes: 2,
+			ip:           first,
+		},
+	}, target.allOutages())
+
+	assert.Equal(t, []hostnameChange{
+		{Addr: first, When: start},
+		{Addr: second, When: start.Add(3 * time.Second)},
+	}, target.hostnameChanges)
+
+	assert.True(t, target.rttResults.hasResults)
+	assert.Equal(t, float32(10), target.rttResults.min)
+	assert.Equal(t, float32(20), target.rttResults.average)
+	assert.Equal(t, float32(30), target.rttResults.max)
+
+	localhost := replayed[1]
+	assert.Equal(t, "localhost", localhost.userInput.hostname)
+	assert.Equal(t, uint(1), localhost.totalSuccessfulProbes)
+	assert.Equal(t, start, localhost.endTime, "a single probe has no duration")
+}
+
+func TestReplayProbesSessions(t *testing.T) {
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+	addr := netip.MustParseAddr("127.0.0.1")
+
+	var probes []savedProbe
+	for session, offset := range []time.Duration{0, time.Hour} {
+		for i := 0; i < 3; i++ {
+			probes = append(probes, savedProbe{
+				timestamp: start.Add(offset + time.Duration(i)*time.Second),
+				hostname:  "127.0.0.1",
+				addr:      addr,
+				port:      22,
+				session:   int64(session + 1),
+				success:   true,
+				rtt:       1,
+			})
+		}
+	}
+
+	replayed := replayProbes(probes)
+	assert.Len(t, replayed, 1)
+
+	// the hour between the sessions is not a part of the uptime
+	target := replayed[0]
+	assert.True(t, target.destIsIP)
+	assert.Equal(t, uint(6), target.totalSuccessfulProbes)
+	assert.Equal(t, 6*time.Second, target.totalUptime)
+	assert.Len(t, target.hostnameChanges, 1)
+}
@@ -0,0 +1,352 @@
+// reresolve.go re-resolves the hostnames periodically, following the TTLs of the DNS records
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minReResolveInterval keeps records with tiny TTLs from flooding the DNS server
+	minReResolveInterval = time.Second
+	// defaultTTLInterval is used when the answer had no TTL, e.g. it came from the hosts file
+	defaultTTLInterval = time.Minute
+	// drainedAfter is the number of consecutive failed probes after which the previous IP counts as drained
+	drainedAfter = 3
+)
+
+// DNS record types holding the addresses of a hostname or the targets of a service, or leading to them
+const (
+	dnsTypeA     = 1
+	dnsTypeCNAME = 5
+	dnsTypeAAAA  = 28
+	dnsTypeSRV   = 33
+)
+
+// reResolveConfig decides how often the hostname is resolved again,
+// regardless of whether the probes are failing.
+type reResolveConfig struct {
+	useTTL          bool          // useTTL follows the lowest TTL of the answer
+	interval        time.Duration // interval is the fixed period, or the fallback when the answer had no TTL
+	probePreviousIP bool          // probePreviousIP keeps probing the previous IP until it's drained
+}
+
+// parseReResolve parses the value of --re-resolve, either "ttl" or a period in seconds
+func parseReResolve(value string, probePreviousIP bool) (*reResolveConfig, error) {
+	if value == "" {
+		if probePreviousIP {
+			return nil, errors.New("'--probe-previous-ip' has no effect without '--re-resolve'")
+		}
+		return nil, nil
+	}
+
+	if strings.EqualFold(value, "ttl") {
+		return &reResolveConfig{useTTL: true, interval: defaultTTLInterval, probePreviousIP: probePreviousIP}, nil
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid '--re-resolve' value %q, it should be \"ttl\" or a number of seconds", value)
+	}
+
+	interval := secondsToDuration(seconds)
+	if interval < minReResolveInterval {
+		return nil, fmt.Errorf("'--re-resolve' should be at least %s", durationToString(minReResolveInterval))
+	}
+
+	return &reResolveConfig{interval: interval, probePreviousIP: probePreviousIP}, nil
+}
+
+// after returns how long to wait before resolving the hostname again
+func (c *reResolveConfig) after(ttl time.Duration) time.Duration {
+	if !c.useTTL || ttl == 0 {
+		return c.interval
+	}
+	return max(ttl, minReResolveInterval)
+}
+
+// ttlRecorder keeps the lowest TTL of the DNS answers read through its connections
+type ttlRecorder struct {
+	mu  sync.Mutex
+	ttl time.Duration
+}
+
+// record parses the DNS reply and keeps the lowest TTL of its address records
+func (r *ttlRecorder) record(reply []byte) {
+	ttl, ok := lowestAnswerTTL(reply)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ttl == 0 || ttl < r.ttl {
+		r.ttl = ttl
+	}
+}
+
+// lowest returns the lowest TTL seen, or 0 if no answer had one
+func (r *ttlRecorder) lowest() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ttl
+}
+
+// wrap returns a dial function whose connections pass the replies to the recorder
+func (r *ttlRecorder) wrap(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		// the resolver tells UDP and TCP apart by the type of the connection
+		if udpConn, ok := conn.(*net.UDPConn); ok {
+			return &ttlPacketConn{UDPConn: udpConn, recorder: r}, nil
+		}
+		return &ttlStreamConn{Conn: conn, recorder: r}, nil
+	}
+}
+
+// ttlPacketConn reads a whole DNS message with every read
+type ttlPacketConn struct {
+	*net.UDPConn
+	recorder *ttlRecorder
+}
+
+func (c *ttlPacketConn) Read(b []byte) (int, error) {
+	n, err := c.UDPConn.Read(b)
+	if n > 0 {
+		c.recorder.record(b[:n])
+	}
+	return n, err
+}
+
+// ttlStreamConn reads DNS messages prefixed by their length, in any number of reads
+type ttlStreamConn struct {
+	net.Conn
+	recorder *ttlRecorder
+	buf      []byte
+}
+
+func (c *ttlStreamConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.buf = append(c.buf, b[:n]...)
+
+	for len(c.buf) >= 2 {
+		length := int(binary.BigEndian.Uint16(c.buf))
+		if len(c.buf) < 2+length {
+			break
+		}
+		c.recorder.record(c.buf[2 : 2+length])
+		c.buf = c.buf[2+length:]
+	}
+
+	return n, err
+}
+
+// skipDNSName returns the offset right after the possibly compressed name at the offset
+func skipDNSName(msg []byte, offset int) (int, bool) {
+	for offset < len(msg) {
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, true
+		case length&0xc0 == 0xc0:
+			// a pointer ends the name
+			return offset + 2, offset+2 <= len(msg)
+		default:
+			offset += length + 1
+		}
+	}
+	return 0, false
+}
+
+// lowestAnswerTTL returns the lowest TTL of the A, AAAA, CNAME and SRV records
+// in the answer section of the DNS message, see RFC 1035 section 4.1.
+func lowestAnswerTTL(msg []byte) (time.Duration, bool) {
+	if len(msg) < 12 {
+		return 0, false
+	}
+
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	answers := int(binary.BigEndian.Uint16(msg[6:]))
+
+	offset := 12
+	for range questions {
+		var ok bool
+		if offset, ok = skipDNSName(msg, offset); !ok {
+			return 0, false
+		}
+		offset += 4 // type and class
+	}
+
+	var lowest uint32
+	found := false
+	for range answers {
+		var ok bool
+		if offset, ok = skipDNSName(msg, offset); !ok || offset+10 > len(msg) {
+			return 0, false
+		}
+
+		recordType := binary.BigEndian.Uint16(msg[offset:])
+		ttl := binary.BigEndian.Uint32(msg[offset+4:])
+		offset += 10 + int(binary.BigEndian.Uint16(msg[offset+8:]))
+
+		switch recordType {
+		case dnsTypeA, dnsTypeAAAA, dnsTypeCNAME, dnsTypeSRV:
+		default:
+			continue
+		}
+		if !found || ttl < lowest {
+			lowest = ttl
+			found = true
+		}
+	}
+
+	return time.Duration(lowest) * time.Second, found
+}
+
+// newTTLResolver returns a resolver asking the DNS server given by the user,
+// or the ones of the system, whose answers pass through the returned recorder.
+func newTTLResolver(custom *dnsResolver) (*net.Resolver, *ttlRecorder) {
+	recorder := &ttlRecorder{}
+
+	var dialer net.Dialer
+	dial := dialer.DialContext
+	if custom != nil {
+		dial = custom.dial
+	}
+
+	return &net.Resolver{PreferGo: true, Dial: recorder.wrap(dial)}, recorder
+}
+
+// lookupTTL resolves the hostname through the DNS server given by the user,
+// or the ones of the system, and returns the lowest TTL of the answers.
+// The TTL is 0 if the answers had none, e.g. they came from the hosts file.
+func lookupTTL(ctx context.Context, custom *dnsResolver, hostname string) ([]netip.Addr, time.Duration, error) {
+	resolver, recorder := newTTLResolver(custom)
+	addrs, err := resolver.LookupNetIP(ctx, "ip", hostname)
+	if custom != nil {
+		custom.nameServer(err)
+	}
+
+	return addrs, recorder.lowest(), err
+}
+
+// formatRecords returns the addresses of a record set, separated by commas
+func formatRecords(records []netip.Addr) string {
+	addrs := make([]string, 0, len(records))
+	for _, addr := range records {
+		addrs = append(addrs, addr.String())
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// reResolveHostname resolves the hostname again and moves to another IP address
+// once the current one is no longer a part of the record set.
+// Unlike resolveHostname, it never exits, as the probes go on with the current IP.
+func reResolveHostname(t *tcping) {
+	ipAddrs, err := lookupHostname(t)
+
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	if err != nil {
+		t.printInfo("Failed to re-resolve %s, still probing %s: %s", t.userInput.hostname, t.userInput.ip, err)
+		return
+	}
+
+	records := filterResolvedIPs(t, ipAddrs)
+	if len(records) == 0 || slices.Equal(records, t.records) {
+		return
+	}
+
+	previousRecords := t.records
+	t.records = records
+	now := time.Now()
+
+	// the change of the records is recorded, even though the IP address stays the same
+	if slices.Contains(records, t.userInput.ip) {
+		t.hostnameChanges = append(t.hostnameChanges, hostnameChange{
+			Addr:            t.userInput.ip,
+			When:            now,
+			PreviousRecords: previousRecords,
+			Records:         records,
+		})
+		t.printInfo("%s now resolves to %s, still probing %s", t.userInput.hostname, formatRecords(records), t.userInput.ip)
+		return
+	}
+
+	previousIP := t.userInput.ip
+	t.setIP(records[rand.Intn(len(records))])
+
+	t.hostnameChanges = append(t.hostnameChanges, hostnameChange{
+		Addr:            t.userInput.ip,
+		When:            now,
+		PreviousRecords: previousRecords,
+		Records:         records,
+	})
+	t.notifyIPChange(now, previousIP.String(), t.ongoingUnsuccessfulProbes)
+	t.printInfo("%s now resolves to %s, moving from %s to %s", t.userInput.hostname, formatRecords(records), previousIP, t.userInput.ip)
+
+	if t.userInput.reResolve.probePreviousIP && !t.draining[previousIP] {
+		if t.draining == nil {
+			t.draining = make(map[netip.Addr]bool)
+		}
+		t.draining[previousIP] = true
+		go t.drain(previousIP, now)
+	}
+}
+
+// drain probes the previous IP address of the target in parallel, until it fails
+// drainedAfter probes in a row or becomes the current IP address again.
+// Its probes are printed like the ones of the target, but not counted in its statistics.
+func (t *tcping) drain(ip netip.Addr, changedAt time.Time) {
+	stateLock.Lock()
+	d := &tcping{printer: t.printer, userInput: t.userInput, startTime: time.Now()}
+	d.setIP(ip)
+	d.userInput.shouldRetryResolve = false
+	d.userInput.reResolve = nil
+	d.userInput.drainingPreviousIP = true
+	d.printInfo("Probing the previous IP %s of %s until it stops answering", ip, t.userInput.hostname)
+	stateLock.Unlock()
+
+	d.ticker = time.NewTicker(d.userInput.intervalBetweenProbes)
+	defer d.ticker.Stop()
+
+	for {
+		if d.userInput.useUDP {
+			udpProbe(d)
+		} else {
+			tcpProbe(d)
+		}
+
+		stateLock.Lock()
+		switch {
+		case d.ongoingUnsuccessfulProbes >= drainedAfter:
+			t.printInfo("The previous IP %s of %s is drained, it stopped answering %s after the change, following %d successful probes",
+				ip, t.userInput.hostname, durationToString(d.startOfDowntime.Sub(changedAt)), d.totalSuccessfulProbes)
+		case t.userInput.ip == ip:
+			t.printInfo("The previous IP %s of %s is probed as the current one again", ip, t.userInput.hostname)
+		default:
+			stateLock.Unlock()
+			continue
+		}
+
+		delete(t.draining, ip)
+		stateLock.Unlock()
+		return
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// infoRecorder is a printer that keeps the info messages
+type infoRecorder struct {
+	dummyPrinter
+	messages []string
+}
+
+func (p *infoRecorder) printInfo(format string, args ...any) {
+	p.messages = append(p.messages, fmt.Sprintf(format, args...))
+}
+
+// drainRecorder is a printer that keeps the info messages and whether the failed probes were draining
+type drainRecorder struct {
+	infoRecorder
+	draining []bool
+}
+
+func (p *drainRecorder) printProbeFail(userInput userInput, _ uint, _ probeDetails) {
+	p.draining = append(p.draining, userInput.drainingPreviousIP)
+}
+
+// changingDNSServer answers with a record set that can be changed during the test
+type changingDNSServer struct {
+	mu    sync.Mutex
+	addrs []netip.Addr
+	ttl   uint32
+}
+
+func (s *changingDNSServer) set(ttl uint32, addrs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ttl = ttl
+	s.addrs = nil
+	for _, addr := range addrs {
+		s.addrs = append(s.addrs, netip.MustParseAddr(addr))
+	}
+}
+
+func (s *changingDNSServer) answer(query []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return dnsReply(query, s.addrs, s.ttl)
+}
+
+func TestParseReResolve(t *testing.T) {
+	c, err := parseReResolve("", false)
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = parseReResolve("ttl", true)
+	assert.NoError(t, err)
+	assert.Equal(t, &reResolveConfig{useTTL: true, interval: defaultTTLInterval, probePreviousIP: true}, c)
+
+	c, err = parseReResolve("30", false)
+	assert.NoError(t, err)
+	assert.Equal(t, &reResolveConfig{interval: 30 * time.Second}, c)
+
+	for _, value := range []string{"0.5", "often", "-1"} {
+		_, err = parseReResolve(value, false)
+		assert.Error(t, err, value)
+	}
+
+	_, err = parseReResolve("", true)
+	assert.Error(t, err, "--probe-previous-ip needs --re-resolve")
+}
+
+func TestReResolveConfigAfter(t *testing.T) {
+	ttl := reResolveConfig{useTTL: true, interval: defaultTTLInterval}
+	assert.Equal(t, 30*time.Second, ttl.after(30*time.Second))
+	assert.Equal(t, minReResolveInterval, ttl.after(time.Millisecond), "tiny TTLs are raised")
+	assert.Equal(t, defaultTTLInterval, ttl.after(0), "answers without a TTL fall back to the interval")
+
+	fixed := reResolveConfig{interval: 10 * time.Second}
+	assert.Equal(t, 10*time.Second, fixed.after(30*time.Second))
+}
+
+func TestLowestAnswerTTL(t *testing.T) {
+	// a query for tcping.test, as sent by the resolver
+	query := []byte{0x12, 0x34, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	query = append(query, 6, 't', 'c', 'p', 'i', 'n', 'g', 4, 't', 'e', 's', 't', 0, 0, dnsTypeA, 0, 1)
+
+	reply := dnsReply(query, []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")}, 42)
+	ttl, ok := lowestAnswerTTL(reply)
+	assert.True(t, ok)
+	assert.Equal(t, 42*time.Second, ttl)
+
+	_, ok = lowestAnswerTTL(dnsReply(query, nil, 42))
+	assert.False(t, ok, "no answers, no TTL")
+
+	_, ok = lowestAnswerTTL(reply[:len(reply)-12])
+	assert.False(t, ok, "truncated messages are ignored")
+}
+
+func TestLookupTTL(t *testing.T) {
+	var server changingDNSServer
+	server.set(30, "192.0.2.1")
+
+	t.Run("udp", func(t *testing.T) {
+		var queries atomic.Int32
+		r, err := newDNSResolver(dnsServeUDP(t, &queries, server.answer), time.Second)
+		assert.NoError(t, err)
+
+		addrs, ttl, err := lookupTTL(t.Context(), r, "tcping.test")
+		assert.NoError(t, err)
+		assert.Equal(t, []netip.Addr{netip.MustParseAddr("192.0.2.1")}, addrs)
+		assert.Equal(t, 30*time.Second, ttl)
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		var queries atomic.Int32
+		dnsServeListener(t, ln, &queries, server.answer)
+
+		r, err := newDNSResolver("tcp://"+ln.Addr().String(), time.Second)
+		assert.NoError(t, err)
+
+		_, ttl, err := lookupTTL(t.Context(), r, "tcping.test")
+		assert.NoError(t, err)
+		assert.Equal(t, 30*time.Second, ttl)
+	})
+}
+
+func TestReResolveHostname(t *testing.T) {
+	var server changingDNSServer
+	server.set(30, "192.0.2.1")
+
+	var queries atomic.Int32
+	r, err := newDNSResolver(dnsServeUDP(t, &queries, server.answer), time.Second)
+	assert.NoError(t, err)
+
+	printer := &infoRecorder{}
+	stats := createTestStats(t)
+	stats.printer = printer
+	stats.userInput.hostname = "tcping.test"
+	stats.userInput.resolver = r
+	stats.userInput.reResolve = &reResolveConfig{useTTL: true, interval: defaultTTLInterval}
+
+	stats.userInput.ip = resolveHostname(stats)
+	stats.hostnameChanges = []hostnameChange{{Addr: stats.userInput.ip, When: time.Now(), Records: stats.records}}
+
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), stats.userInput.ip)
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), stats.nextResolve, time.Second, "the next lookup follows the TTL")
+
+	// the same record set changes nothing
+	reResolveHostname(stats)
+	assert.Len(t, stats.hostnameChanges, 1)
+	assert.Empty(t, printer.messages)
+
+	// the current address is still a part of the record set
+	server.set(10, "192.0.2.1", "192.0.2.3")
+	reResolveHostname(stats)
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), stats.userInput.ip)
+	assert.Equal(t, hostnameChange{
+		Addr:            netip.MustParseAddr("192.0.2.1"),
+		When:            stats.hostnameChanges[1].When,
+		PreviousRecords: []netip.Addr{netip.MustParseAddr("192.0.2.1")},
+		Records:         []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.3")},
+	}, stats.hostnameChanges[1], "the change of the records is recorded")
+	assert.Equal(t, 0, stats.ipChanges())
+	assert.Equal(t, []string{"tcping.test now resolves to 192.0.2.1, 192.0.2.3, still probing 192.0.2.1"}, printer.messages)
+	assert.WithinDuration(t, time.Now().Add(10*time.Second), stats.nextResolve, time.Second)
+
+	// the record set moved away from the current address
+	server.set(10, "192.0.2.2")
+	reResolveHostname(stats)
+	assert.Equal(t, netip.MustParseAddr("192.0.2.2"), stats.userInput.ip)
+	assert.Equal(t, hostnameChange{
+		Addr:            netip.MustParseAddr("192.0.2.2"),
+		When:            stats.hostnameChanges[2].When,
+		PreviousRecords: []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.3")},
+		Records:         []netip.Addr{netip.MustParseAddr("192.0.2.2")},
+	}, stats.hostnameChanges[2])
+	assert.Equal(t, 1, stats.ipChanges())
+	assert.Empty(t, stats.draining, "the previous IP is only probed when asked for")
+}
+
+func TestDrainPreviousIP(t *testing.T) {
+	// the previous IP refuses the connections, so it's drained right away
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	var server changingDNSServer
+	server.set(30, "127.0.0.1")
+
+	var queries atomic.Int32
+	r, err := newDNSResolver(dnsServeUDP(t, &queries, server.answer), time.Second)
+	assert.NoError(t, err)
+
+	printer := &drainRecorder{}
+	stats := createTestStats(t)
+	stats.printer = printer
+	stats.userInput.hostname = "tcping.test"
+	stats.userInput.port = port
+	stats.userInput.intervalBetweenProbes = 10 * time.Millisecond
+	stats.userInput.timeout = 100 * time.Millisecond
+	stats.userInput.resolver = r
+	stats.userInput.reResolve = &reResolveConfig{interval: time.Minute, probePreviousIP: true}
+	stats.userInput.ip = resolveHostname(stats)
+
+	server.set(30, "127.0.0.2")
+	reResolveHostname(stats)
+
+	stateLock.Lock()
+	assert.True(t, stats.draining[netip.MustParseAddr("127.0.0.1")])
+	stateLock.Unlock()
+
+	assert.Eventually(t, func() bool {
+		stateLock.Lock()
+		defer stateLock.Unlock()
+		return len(stats.draining) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	assert.Contains(t, printer.messages, "Probing the previous IP 127.0.0.1 of tcping.test until it stops answering")
+	assert.Contains(t, printer.messages[len(printer.messages)-1], "The previous IP 127.0.0.1 of tcping.test is drained")
+	assert.Equal(t, uint(0), stats.totalUnsuccessfulProbes, "the probes of the previous IP are not counted")
+	assert.Equal(t, []bool{true, true, true}, printer.draining, "the probes of the previous IP are not saved")
+}
+
+func TestReResolveNetworkInterface(t *testing.T) {
+	// only the new IP accepts the connections
+	ln, err := net.Listen("tcp", "127.0.0.2:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+
+	var server changingDNSServer
+	server.set(30, "127.0.0.1")
+
+	var queries atomic.Int32
+	r, err := newDNSResolver(dnsServeUDP(t, &queries, server.answer), time.Second)
+	assert.NoError(t, err)
+
+	printer := &drainRecorder{}
+	stats := createTestStats(t)
+	stats.printer = printer
+	stats.userInput.hostname = "tcping.test"
+	stats.userInput.port = port
+	stats.userInput.intervalBetweenProbes = 10 * time.Millisecond
+	stats.userInput.timeout = 100 * time.Millisecond
+	stats.userInput.resolver = r
+	stats.userInput.reResolve = &reResolveConfig{interval: time.Minute, probePreviousIP: true}
+	stats.userInput.networkInterface = networkInterface{
+		use:    true,
+		dialer: net.Dialer{LocalAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}, Timeout: stats.userInput.timeout},
+	}
+	stats.setIP(resolveHostname(stats))
+
+	server.set(30, "127.0.0.2")
+	reResolveHostname(stats)
+
+	stateLock.Lock()
+	assert.Equal(t, "127.0.0.2:"+strconv.Itoa(int(port)), stats.userInput.networkInterface.remoteAddr.String())
+	stateLock.Unlock()
+
+	tcpProbe(stats)
+	assert.Equal(t, uint(1), stats.totalSuccessfulProbes, "the new IP is dialed through the interface")
+
+	// the previous IP refuses the connections, so it's drained right away
+	assert.Eventually(t, func() bool {
+		stateLock.Lock()
+		defer stateLock.Unlock()
+		return len(stats.draining) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	assert.Contains(t, printer.messages[len(printer.messages)-1], "The previous IP 127.0.0.1 of tcping.test is drained")
+}
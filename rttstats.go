@@ -0,0 +1,186 @@
+// rttstats.go keeps track of RTT statistics in constant memory
+package main
+
+import "math"
+
+const (
+	// sketchRelativeAccuracy is the maximum relative error
+	// of the percentiles calculated by the quantileSketch.
+	sketchRelativeAccuracy = 0.01
+	// sketchMinValue is the smallest RTT in milliseconds
+	// that gets its own bucket. Lower values are counted as zero.
+	sketchMinValue = 0.001
+)
+
+var (
+	sketchGamma    = (1 + sketchRelativeAccuracy) / (1 - sketchRelativeAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// rttHistogramBounds are the upper bounds, in milliseconds,
+// of the buckets of the RTT histogram.
+var rttHistogramBounds = [...]float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// rttStats is a streaming statistics engine for RTT values.
+//
+// Unlike keeping every RTT around, its memory usage doesn't grow
+// with the number of probes, which matters for long-running sessions.
+type rttStats struct {
+	sketch quantileSketch
+	count  uint64
+	min    float64
+	max    float64
+	mean   float64 // mean is the running mean, using Welford's algorithm
+	m2     float64 // m2 is the running sum of squared differences from the mean
+	last   float64
+	jitter float64
+	sum    float64
+	// histogram[i] holds the number of values in (rttHistogramBounds[i-1], rttHistogramBounds[i]].
+	// Values above the last bound are only a part of count.
+	histogram [len(rttHistogramBounds)]uint64
+}
+
+// add records a new RTT value, in milliseconds.
+func (s *rttStats) add(rtt float32) {
+	value := float64(rtt)
+
+	s.count++
+	if s.count == 1 {
+		s.min = value
+		s.max = value
+	} else {
+		s.min = math.Min(s.min, value)
+		s.max = math.Max(s.max, value)
+
+		// RFC 3550, section 6.4.1: J(i) = J(i-1) + (|D(i-1,i)| - J(i-1))/16
+		s.jitter += (math.Abs(value-s.last) - s.jitter) / 16
+	}
+	s.last = value
+
+	delta := value - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (value - s.mean)
+
+	s.sum += value
+	for i, bound := range rttHistogramBounds {
+		if value <= bound {
+			s.histogram[i]++
+			break
+		}
+	}
+
+	s.sketch.add(value)
+}
+
+// result calculates min, avg and max RTT values,
+// along with the percentiles, standard deviation and jitter.
+func (s *rttStats) result() rttResult {
+	if s.count == 0 {
+		return rttResult{}
+	}
+
+	return rttResult{
+		min:        float32(s.min),
+		max:        float32(s.max),
+		average:    float32(s.mean),
+		p50:        float32(s.percentile(50)),
+		p90:        float32(s.percentile(90)),
+		p95:        float32(s.percentile(95)),
+		p99:        float32(s.percentile(99)),
+		stdDev:     float32(math.Sqrt(s.m2 / float64(s.count))),
+		jitter:     float32(s.jitter),
+		hasResults: true,
+	}
+}
+
+// sampleVariance returns the unbiased variance of the recorded values,
+// as used to compare the RTTs of two runs.
+func (s *rttStats) sampleVariance() float64 {
+	if s.count < 2 {
+		return 0
+	}
+
+	return s.m2 / float64(s.count-1)
+}
+
+// percentile returns the approximate percentile of the recorded values.
+// As min and max are known exactly, the estimation never exceeds them.
+func (s *rttStats) percentile(percentile float64) float64 {
+	value := s.sketch.quantile(percentile / 100)
+
+	return math.Min(math.Max(value, s.min), s.max)
+}
+
+// quantileSketch estimates quantiles with a bounded relative error.
+//
+// Values are counted in logarithmically sized buckets, so the number
+// of buckets depends only on the range of the values (a few thousands
+// at most for float32 RTTs), and not on how many values were added.
+type quantileSketch struct {
+	counts    []uint64 // counts[i] holds the number of values in bucket offset+i
+	offset    int
+	zeroCount uint64
+	count     uint64
+}
+
+// bucketIndex returns the index of the bucket holding the value.
+func bucketIndex(value float64) int {
+	return int(math.Ceil(math.Log(value) / sketchLogGamma))
+}
+
+// bucketValue returns the value representing all values in the bucket.
+func bucketValue(index int) float64 {
+	return 2 * math.Pow(sketchGamma, float64(index)) / (sketchGamma + 1)
+}
+
+// add records a value in the sketch.
+func (q *quantileSketch) add(value float64) {
+	q.count++
+
+	if value < sketchMinValue {
+		q.zeroCount++
+		return
+	}
+
+	index := bucketIndex(value)
+
+	switch {
+	case len(q.counts) == 0:
+		q.counts = make([]uint64, 1)
+		q.offset = index
+	case index < q.offset:
+		grown := make([]uint64, len(q.counts)+q.offset-index)
+		copy(grown[q.offset-index:], q.counts)
+		q.counts = grown
+		q.offset = index
+	case index >= q.offset+len(q.counts):
+		grown := make([]uint64, index-q.offset+1)
+		copy(grown, q.counts)
+		q.counts = grown
+	}
+
+	q.counts[index-q.offset]++
+}
+
+// quantile returns the estimated value at the given quantile, from 0 to 1.
+func (q *quantileSketch) quantile(quantile float64) float64 {
+	if q.count == 0 {
+		return 0
+	}
+
+	rank := quantile * float64(q.count-1)
+
+	cumulative := q.zeroCount
+	if float64(cumulative) > rank {
+		return 0
+	}
+
+	for i, count := range q.counts {
+		cumulative += count
+		if float64(cumulative) > rank {
+			return bucketValue(q.offset + i)
+		}
+	}
+
+	return bucketValue(q.offset + len(q.counts) - 1)
+}
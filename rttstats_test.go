@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRttStatsResult(t *testing.T) {
+	t.Run("no results", func(t *testing.T) {
+		var stats rttStats
+		got := stats.result()
+		assert.False(t, got.hasResults)
+	})
+
+	t.Run("percentiles, standard deviation and jitter", func(t *testing.T) {
+		var stats rttStats
+		for i := 100; i >= 1; i-- {
+			stats.add(float32(i))
+		}
+
+		got := stats.result()
+
+		assert.True(t, got.hasResults)
+		assert.Equal(t, float32(1), got.min)
+		assert.Equal(t, float32(100), got.max)
+		assert.Equal(t, float32(50.5), got.average)
+		assert.InEpsilon(t, 50.5, got.p50, 2*sketchRelativeAccuracy)
+		assert.InEpsilon(t, 90.1, got.p90, 2*sketchRelativeAccuracy)
+		assert.InEpsilon(t, 95.05, got.p95, 2*sketchRelativeAccuracy)
+		assert.InEpsilon(t, 99.01, got.p99, 2*sketchRelativeAccuracy)
+		assert.InDelta(t, 28.866, got.stdDev, 0.001)
+		// every consecutive probe differs by 1ms, so jitter converges towards 1
+		assert.InDelta(t, 0.998, got.jitter, 0.001)
+	})
+
+	t.Run("stable latency has no jitter", func(t *testing.T) {
+		var stats rttStats
+		for i := 0; i < 4; i++ {
+			stats.add(5)
+		}
+
+		got := stats.result()
+
+		assert.Equal(t, float32(0), got.stdDev)
+		assert.Equal(t, float32(0), got.jitter)
+		assert.Equal(t, float32(5), got.p99)
+	})
+}
+
+func TestRttStatsSampleVariance(t *testing.T) {
+	var stats rttStats
+	stats.add(10)
+	assert.Equal(t, float64(0), stats.sampleVariance(), "a single value has no variance")
+
+	stats = rttStats{}
+	for i := 1; i <= 100; i++ {
+		stats.add(float32(i))
+	}
+	assert.InDelta(t, 841.667, stats.sampleVariance(), 0.001)
+}
+
+func TestQuantileSketchAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	var sketch quantileSketch
+	values := make([]float64, 0, 100_000)
+	for i := 0; i < 100_000; i++ {
+		// log-normal latencies with a long tail, like real networks
+		value := math.Exp(rng.NormFloat64()) * 20
+		values = append(values, value)
+		sketch.add(value)
+	}
+	slices.Sort(values)
+
+	for _, quantile := range []float64{0.5, 0.9, 0.95, 0.99} {
+		want := values[int(quantile*float64(len(values)-1))]
+		assert.InEpsilon(t, want, sketch.quantile(quantile), sketchRelativeAccuracy)
+	}
+}
+
+func TestRttStatsBoundedMemory(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomRtt := func() float32 {
+		return float32(math.Exp(rng.NormFloat64()*3) * 20)
+	}
+
+	var stats rttStats
+	// warm up, so that the buckets for the whole range of values exist
+	for i := 0; i < 100_000; i++ {
+		stats.add(randomRtt())
+	}
+	buckets := len(stats.sketch.counts)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	const probes = 5_000_000
+	for i := 0; i < probes; i++ {
+		stats.add(randomRtt())
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	assert.Equal(t, uint64(probes+100_000), stats.count)
+	// a []float32 would have grown by 20MB
+	assert.Less(t, int64(after.HeapAlloc)-int64(before.HeapAlloc), int64(1<<20))
+	assert.Less(t, len(stats.sketch.counts), 2*buckets)
+	assert.Zero(t, testing.AllocsPerRun(1000, func() {
+		stats.add(randomRtt())
+	}))
+}
@@ -0,0 +1,345 @@
+// srv.go discovers the targets of a service published as DNS SRV records, see RFC 2782
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// srvRecord is one of the targets of a service
+type srvRecord struct {
+	host     string
+	port     uint16
+	priority uint16
+	weight   uint16
+}
+
+// String returns the host:port of the target
+func (r srvRecord) String() string {
+	return net.JoinHostPort(r.host, strconv.Itoa(int(r.port)))
+}
+
+// srvDiscovery keeps the probed targets in line with the SRV records of a service.
+//
+// The records are looked up again on their TTL, or the period of --re-resolve.
+// Targets are added for the new records and stopped for the removed ones,
+// and every change of the target list is kept in changes.
+type srvDiscovery struct {
+	name       string
+	printer    printer
+	resolver   *dnsResolver     // resolver is set when the records are resolved through the DNS server given by the user
+	reResolve  *reResolveConfig // reResolve decides when to look the records up again
+	userInput  userInput        // userInput holds the IP version to resolve the targets to
+	newTarget  func(args []string, records []netip.Addr) *tcping
+	nextLookup time.Time
+	records    []srvRecord        // records is the last record set, sorted
+	targets    map[string]*tcping // targets are the probed targets of the current records, by their host:port
+	members    []*tcping          // members are all targets ever discovered, including the stopped ones
+	changes    []hostnameChange   // changes starts with the initial target list, followed by every change of it
+	running    int                // running is the number of targets still probing
+	finished   chan struct{}      // finished is closed once the current targets are done probing, e.g. with -c
+	finishOnce sync.Once
+}
+
+// newSRVDiscovery looks up the SRV records of the service and creates a target for each of them.
+// newTarget creates a target with the options of the user for the given host and port.
+func newSRVDiscovery(name string, p printer, resolver *dnsResolver, reResolve *reResolveConfig, ipFlags userInput,
+	newTarget func(args []string, records []netip.Addr) *tcping,
+) (*srvDiscovery, error) {
+	if reResolve == nil {
+		reResolve = &reResolveConfig{useTTL: true, interval: defaultTTLInterval}
+	}
+
+	d := &srvDiscovery{
+		name:      strings.TrimSuffix(name, "."),
+		printer:   p,
+		resolver:  resolver,
+		reResolve: reResolve,
+		userInput: userInput{useIPv4: ipFlags.useIPv4, useIPv6: ipFlags.useIPv6, resolver: resolver},
+		newTarget: newTarget,
+		targets:   make(map[string]*tcping),
+		finished:  make(chan struct{}),
+	}
+
+	records, err := d.lookup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the SRV records of %s: %w", d.name, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no SRV targets found for %s", d.name)
+	}
+
+	d.records = records
+	d.changes = []hostnameChange{{When: time.Now(), Targets: formatSRVRecords(records)}}
+
+	var added []*tcping
+	for _, r := range records {
+		if t := d.add(r); t != nil {
+			added = append(added, t)
+		}
+	}
+
+	stateLock.Lock()
+	for _, t := range added {
+		d.targets[t.srvRecord.String()] = t
+		d.members = append(d.members, t)
+	}
+	stateLock.Unlock()
+
+	if len(d.members) == 0 {
+		return nil, fmt.Errorf("none of the SRV targets of %s could be resolved", d.name)
+	}
+	d.running = len(d.members)
+
+	d.printer.printInfo("Probing %d SRV targets of %s: %s", len(records), d.name, strings.Join(formatSRVRecords(records), ", "))
+
+	return d, nil
+}
+
+// lookupSRV resolves the SRV records of the service, sorted by priority and weight,
+// and returns the lowest TTL of the answer, or 0 if it had none.
+func lookupSRV(ctx context.Context, custom *dnsResolver, name string) ([]srvRecord, time.Duration, error) {
+	resolver, recorder := newTTLResolver(custom)
+	_, srvs, err := resolver.LookupSRV(ctx, "", "", name)
+	if custom != nil {
+		custom.nameServer(err)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	records := make([]srvRecord, 0, len(srvs))
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+
+		// a target of "." means the service is not available
+		if host == "" || srv.Port == 0 {
+			continue
+		}
+
+		records = append(records, srvRecord{host: host, port: srv.Port, priority: srv.Priority, weight: srv.Weight})
+	}
+
+	// the resolver shuffles the records of the same priority by their weight
+	slices.SortFunc(records, func(a, b srvRecord) int {
+		return cmp.Or(
+			cmp.Compare(a.priority, b.priority),
+			cmp.Compare(b.weight, a.weight),
+			cmp.Compare(a.host, b.host),
+			cmp.Compare(a.port, b.port),
+		)
+	})
+
+	return slices.Compact(records), recorder.lowest(), nil
+}
+
+// lookup resolves the SRV records and schedules the next lookup
+func (d *srvDiscovery) lookup() ([]srvRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout(d.resolver))
+	defer cancel()
+
+	records, ttl, err := lookupSRV(ctx, d.resolver, d.name)
+	d.nextLookup = time.Now().Add(d.reResolve.after(ttl))
+
+	return records, err
+}
+
+// add creates a target for the record, or returns nil if its host can't be resolved.
+// The host is resolved beforehand, as unlike the hostnames given by the user,
+// failing to resolve it should not exit. It's tried again with the next change of the records.
+// The caller adds the target to the targets while holding stateLock.
+func (d *srvDiscovery) add(r srvRecord) *tcping {
+	resolving := &tcping{userInput: d.userInput}
+	resolving.userInput.hostname = r.host
+
+	ipAddrs, err := lookupHostname(resolving)
+	records := filterResolvedIPs(resolving, ipAddrs)
+	if err != nil || len(records) == 0 {
+		if err == nil {
+			err = errors.New("no IP address of the IP version asked for")
+		}
+
+		stateLock.Lock()
+		d.printer.printInfo("Failed to resolve the SRV target %s of %s, skipping it: %s", r, d.name, err)
+		stateLock.Unlock()
+
+		return nil
+	}
+
+	t := d.newTarget([]string{r.host, strconv.Itoa(int(r.port))}, records)
+	t.srv = d
+	t.srvRecord = r
+	t.stop = make(chan struct{})
+
+	return t
+}
+
+// run looks up the SRV records again whenever they expire, until the current
+// targets are done probing. The caller adds it to the wait group of the list,
+// so that tcping keeps running while every target is removed, waiting for new ones.
+func (d *srvDiscovery) run(list *targetList) {
+	defer list.wg.Done()
+
+	for {
+		select {
+		case <-time.After(time.Until(d.nextLookup)):
+			d.rediscover(list)
+		case <-d.finished:
+			return
+		}
+	}
+}
+
+// targetDone is called once a target of the records stops probing. The discovery
+// is finished once the current targets are done, which doesn't happen while
+// they're all removed, as they're stopped rather than done.
+func (d *srvDiscovery) targetDone() {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	d.running--
+	if d.running == 0 && len(d.targets) > 0 {
+		d.finishOnce.Do(func() { close(d.finished) })
+	}
+}
+
+// rediscover looks up the SRV records, starting the targets of the new records
+// and stopping the ones of the removed records.
+// The records are looked up while probing, so the lookups don't hold stateLock.
+func (d *srvDiscovery) rediscover(list *targetList) {
+	records, err := d.lookup()
+	if err != nil || len(records) == 0 {
+		stateLock.Lock()
+		if err == nil {
+			err = errors.New("no SRV targets found")
+		}
+		d.printer.printInfo("Failed to re-discover the SRV targets of %s, still probing the previous ones: %s", d.name, err)
+		stateLock.Unlock()
+		return
+	}
+
+	if slices.Equal(records, d.records) {
+		return
+	}
+
+	var missing []srvRecord
+	current := make(map[string]srvRecord, len(records))
+	stateLock.Lock()
+	for _, r := range records {
+		current[r.String()] = r
+		if _, ok := d.targets[r.String()]; !ok {
+			missing = append(missing, r)
+		}
+	}
+	stateLock.Unlock()
+
+	var added []*tcping
+	for _, r := range missing {
+		if t := d.add(r); t != nil {
+			added = append(added, t)
+		}
+	}
+
+	stateLock.Lock()
+	now := time.Now()
+
+	// the new targets start before the removed ones stop, so that the list is never done probing
+	for _, t := range added {
+		d.targets[t.srvRecord.String()] = t
+		d.members = append(d.members, t)
+		d.running++
+		list.targets = append(list.targets, t)
+		if dashboard, ok := t.printer.(*tuiPrinter); ok {
+			dashboard.addTarget(t)
+		}
+		t.printStart(t.userInput.hostname, t.userInput.port)
+		list.run(t)
+	}
+
+	var removed []string
+	for target, t := range d.targets {
+		// the targets to keep may have a different priority or weight
+		if r, ok := current[target]; ok {
+			t.srvRecord = r
+			continue
+		}
+
+		close(t.stop)
+		t.endTime = now
+		delete(d.targets, target)
+		removed = append(removed, target)
+	}
+
+	// only the changes of the target list are recorded, not the ones of the priority or weight
+	previous := d.records
+	d.records = records
+	if slices.Equal(formatSRVRecords(previous), formatSRVRecords(records)) {
+		stateLock.Unlock()
+		return
+	}
+
+	d.changes = append(d.changes, hostnameChange{
+		When:            now,
+		PreviousTargets: formatSRVRecords(previous),
+		Targets:         formatSRVRecords(records),
+	})
+
+	d.printer.printInfo("The SRV targets of %s changed to %s", d.name, strings.Join(formatSRVRecords(records), ", "))
+	if len(removed) > 0 {
+		slices.Sort(removed)
+		d.printer.printInfo("Stopped probing the removed SRV targets %s", strings.Join(removed, ", "))
+	}
+	stateLock.Unlock()
+}
+
+// isLastSRVTarget reports whether the target is the last one discovered through
+// its SRV records, whose statistics are followed by the summary of all of them.
+func (t *tcping) isLastSRVTarget() bool {
+	return t.srv != nil && t.srv.members[len(t.srv.members)-1] == t
+}
+
+// isStopped reports whether the target was removed from the SRV records
+func (t *tcping) isStopped() bool {
+	if t.stop == nil {
+		return false
+	}
+
+	select {
+	case <-t.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// srvChanges returns the changes of the target list of the SRV records, which are
+// reported along with the per-target summary, in the statistics of the last target.
+func (t *tcping) srvChanges() []hostnameChange {
+	if t.srv == nil || len(t.addressSummaries) == 0 || len(t.srv.changes) < 2 {
+		return nil
+	}
+	return t.srv.changes[1:]
+}
+
+// formatSRVRecords returns the host:port of every record
+func formatSRVRecords(records []srvRecord) []string {
+	targets := make([]string, 0, len(records))
+	for _, r := range records {
+		targets = append(targets, r.String())
+	}
+	return targets
+}
+
+// formatSRVChange returns a change of the target list, e.g. "a:1, b:2 -> a:1, c:3"
+func formatSRVChange(change hostnameChange) string {
+	return strings.Join(change.PreviousTargets, ", ") + " -> " + strings.Join(change.Targets, ", ")
+}
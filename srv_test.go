@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// dnsName encodes the labels of the host as in DNS messages, without the final root label
+func dnsName(host string) []byte {
+	var name []byte
+	for label := range strings.SplitSeq(host, ".") {
+		if label != "" {
+			name = append(name, byte(len(label)))
+			name = append(name, label...)
+		}
+	}
+	return name
+}
+
+// srvReply replies to a DNS query with the records as SRV records, all with the given TTL,
+// and with testDNSAddr for the A records of their targets.
+func srvReply(query []byte, records []srvRecord, ttl uint32) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// the question ends after the labels of the name, the type and the class
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5
+	if end > len(query) {
+		return nil
+	}
+
+	if binary.BigEndian.Uint16(query[end-4:]) != dnsTypeSRV {
+		return dnsReply(query, []netip.Addr{testDNSAddr}, ttl)
+	}
+
+	reply := append([]byte{}, query[:2]...) // ID
+	reply = append(reply, 0x81, 0x80)       // a recursive reply with no error
+	reply = append(reply, 0, 1, byte(len(records)>>8), byte(len(records)), 0, 0, 0, 0)
+	reply = append(reply, query[12:end]...) // the question
+	for _, r := range records {
+		target := append(dnsName(r.host), 0)
+
+		reply = append(reply, 0xc0, 12)    // a pointer to the name of the question
+		reply = append(reply, 0, 33, 0, 1) // SRV and IN
+		reply = binary.BigEndian.AppendUint32(reply, ttl)
+		reply = binary.BigEndian.AppendUint16(reply, uint16(6+len(target)))
+		reply = binary.BigEndian.AppendUint16(reply, r.priority)
+		reply = binary.BigEndian.AppendUint16(reply, r.weight)
+		reply = binary.BigEndian.AppendUint16(reply, r.port)
+		reply = append(reply, target...)
+	}
+
+	return reply
+}
+
+// changingSRVServer answers with SRV records that can be changed during the test
+type changingSRVServer struct {
+	mu           sync.Mutex
+	records      []srvRecord
+	ttl          uint32
+	unresolvable string // unresolvable is a host whose lookups fail
+}
+
+func (s *changingSRVServer) set(ttl uint32, records ...srvRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ttl = ttl
+	s.records = records
+}
+
+func (s *changingSRVServer) answer(query []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.unresolvable != "" && len(query) > 12 && bytes.Contains(query[12:], dnsName(s.unresolvable)) {
+		// the question without any answer, and a name error
+		reply := append([]byte{}, query...)
+		reply[2], reply[3] = 0x81, 0x83
+		return reply
+	}
+
+	return srvReply(query, s.records, s.ttl)
+}
+
+func TestLookupSRV(t *testing.T) {
+	var server changingSRVServer
+	server.set(120,
+		srvRecord{host: "b.tcping.test", port: 5060, priority: 10, weight: 20},
+		srvRecord{host: "a.tcping.test", port: 5060, priority: 20, weight: 10},
+		srvRecord{host: "c.tcping.test", port: 5061, priority: 10, weight: 80},
+		srvRecord{host: "d.tcping.test", port: 0, priority: 0, weight: 0},
+	)
+
+	var queries atomic.Int32
+	r, err := newDNSResolver(dnsServeUDP(t, &queries, server.answer), time.Second)
+	assert.NoError(t, err)
+
+	records, ttl, err := lookupSRV(t.Context(), r, "_sip._tcp.tcping.test")
+	assert.NoError(t, err)
+	assert.Equal(t, []srvRecord{
+		{host: "c.tcping.test", port: 5061, priority: 10, weight: 80},
+		{host: "b.tcping.test", port: 5060, priority: 10, weight: 20},
+		{host: "a.tcping.test", port: 5060, priority: 20, weight: 10},
+	}, records, "sorted by priority, then by weight, without the ones of port 0")
+	assert.Equal(t, 120*time.Second, ttl)
+
+	// a target of "." means the service is not available
+	server.set(120, srvRecord{host: "."})
+	records, _, err = lookupSRV(t.Context(), r, "_sip._tcp.tcping.test")
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestLowestAnswerTTLSRV(t *testing.T) {
+	// a query for the SRV records of tcping.test, as sent by the resolver
+	query := []byte{0x12, 0x34, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	query = append(query, 6, 't', 'c', 'p', 'i', 'n', 'g', 4, 't', 'e', 's', 't', 0, 0, dnsTypeSRV, 0, 1)
+
+	ttl, ok := lowestAnswerTTL(srvReply(query, []srvRecord{{host: "a.tcping.test", port: 443}}, 300))
+	assert.True(t, ok)
+	assert.Equal(t, 300*time.Second, ttl)
+}
+
+func TestSRVDiscovery(t *testing.T) {
+	a := srvRecord{host: "a.tcping.test", port: 5060, priority: 10, weight: 50}
+	b := srvRecord{host: "b.tcping.test", port: 5060, priority: 10, weight: 50}
+	c := srvRecord{host: "c.tcping.test", port: 5061, priority: 20, weight: 0}
+
+	var server changingSRVServer
+	server.set(60, a, b)
+
+	var queries atomic.Int32
+	r, err := newDNSResolver(dnsServeUDP(t, &queries, server.answer), time.Second)
+	assert.NoError(t, err)
+
+	// the targets are probed once, and the test address never answers
+	newTarget := func(args []string, records []netip.Addr) *tcping {
+		target := createTestStats(t)
+		target.userInput.hostname = args[0]
+		port, err := strconv.ParseUint(args[1], 10, 16)
+		assert.NoError(t, err)
+		target.userInput.port = uint16(port)
+		target.userInput.ip = records[0]
+		target.userInput.intervalBetweenProbes = 10 * time.Millisecond
+		target.userInput.timeout = 10 * time.Millisecond
+		target.userInput.probesBeforeQuit = 1
+		target.records = records
+		return target
+	}
+
+	printer := &infoRecorder{}
+	d, err := newSRVDiscovery("_sip._tcp.tcping.test.", printer, r, nil, userInput{}, newTarget)
+	assert.NoError(t, err)
+	assert.Equal(t, "_sip._tcp.tcping.test", d.name)
+	assert.Len(t, d.members, 2)
+	assert.Equal(t, []string{"Probing 2 SRV targets of _sip._tcp.tcping.test: a.tcping.test:5060, b.tcping.test:5060"}, printer.messages)
+	assert.Equal(t, []string{"a.tcping.test:5060", "b.tcping.test:5060"}, d.changes[0].Targets)
+	assert.WithinDuration(t, time.Now().Add(60*time.Second), d.nextLookup, time.Second, "the next lookup follows the TTL")
+
+	first, second := d.members[0], d.members[1]
+	assert.Equal(t, a, first.srvRecord)
+	assert.Equal(t, []netip.Addr{testDNSAddr}, first.records)
+	assert.False(t, first.isLastSRVTarget())
+	assert.True(t, second.isLastSRVTarget())
+
+	list := &targetList{targets: d.members}
+
+	// the same records change nothing
+	printer.messages = nil
+	d.rediscover(list)
+	assert.Len(t, d.changes, 1)
+	assert.Empty(t, printer.messages)
+
+	// b is replaced by c, while the weight of a changes
+	a.weight = 80
+	server.set(60, a, c)
+	d.rediscover(list)
+	list.wg.Wait()
+
+	assert.True(t, second.isStopped())
+	assert.False(t, second.endTime.IsZero())
+	assert.False(t, first.isStopped())
+	assert.Equal(t, a, first.srvRecord, "the target is kept with its new weight")
+
+	assert.Len(t, d.members, 3)
+	assert.Len(t, list.targets, 3)
+	third := d.members[2]
+	assert.Equal(t, c, third.srvRecord)
+	assert.True(t, third.isLastSRVTarget())
+	assert.Equal(t, uint(1), third.totalSuccessfulProbes+third.totalUnsuccessfulProbes, "the new target is probed")
+
+	assert.Len(t, d.changes, 2)
+	assert.Equal(t, "a.tcping.test:5060, b.tcping.test:5060 -> a.tcping.test:5060, c.tcping.test:5061", formatSRVChange(d.changes[1]))
+	assert.Equal(t, []string{
+		"The SRV targets of _sip._tcp.tcping.test changed to a.tcping.test:5060, c.tcping.test:5061",
+		"Stopped probing the removed SRV targets b.tcping.test:5060",
+	}, printer.messages)
+
+	// a change of the weight alone is not a change of the target list
+	a.weight = 10
+	server.set(60, a, c)
+	printer.messages = nil
+	d.rediscover(list)
+	assert.Len(t, d.changes, 2)
+	assert.Empty(t, printer.messages)
+	assert.Equal(t, a, first.srvRecord)
+
+	summaries := summarizeAddresses(d.members)
+	assert.Equal(t, "gone", summaries[1].status())
+	assert.Equal(t, "c.tcping.test:5061 192.0.2.1 priority 20 weight 0", summaries[2].label())
+	assert.Equal(t, "--- _sip._tcp.tcping.test SRV per-target summary ---", addressSummaryTitle(*third))
+
+	// the previous targets are kept when the service is not available
+	server.set(60, srvRecord{host: "."})
+	printer.messages = nil
+	d.rediscover(list)
+	assert.Len(t, d.changes, 2)
+	assert.Equal(t, []string{
+		"Failed to re-discover the SRV targets of _sip._tcp.tcping.test, still probing the previous ones: no SRV targets found",
+	}, printer.messages)
+
+	_, err = newSRVDiscovery("_sip._tcp.tcping.test", printer, r, nil, userInput{}, newTarget)
+	assert.EqualError(t, err, "no SRV targets found for _sip._tcp.tcping.test")
+}
+
+func TestSRVDiscoveryReplaced(t *testing.T) {
+	a := srvRecord{host: "a.tcping.test", port: 5060}
+	b := srvRecord{host: "b.tcping.test", port: 5060}
+	gone := srvRecord{host: "gone.tcping.test", port: 5060}
+
+	server := changingSRVServer{unresolvable: gone.host}
+	server.set(60, a)
+
+	var queries atomic.Int32
+	r, err := newDNSResolver(dnsServeUDP(t, &queries, server.answer), time.Second)
+	assert.NoError(t, err)
+
+	// the targets are probed until they're stopped, or count times
+	newTargetProbing := func(count uint) func(args []string, records []netip.Addr) *tcping {
+		return func(args []string, records []netip.Addr) *tcping {
+			target := createTestStats(t)
+			target.userInput.hostname = args[0]
+			target.userInput.ip = records[0]
+			target.userInput.intervalBetweenProbes = 10 * time.Millisecond
+			target.userInput.timeout = 10 * time.Millisecond
+			target.userInput.probesBeforeQuit = count
+			target.records = records
+			return target
+		}
+	}
+
+	isFinished := func(d *srvDiscovery) bool {
+		select {
+		case <-d.finished:
+			return true
+		default:
+			return false
+		}
+	}
+
+	isRunning := func(d *srvDiscovery, running int) func() bool {
+		return func() bool {
+			stateLock.Lock()
+			defer stateLock.Unlock()
+			return d.running == running
+		}
+	}
+
+	t.Run("unresolvable", func(t *testing.T) {
+		server.set(60, a)
+		printer := &infoRecorder{}
+		d, err := newSRVDiscovery("_sip._tcp.tcping.test", printer, r, nil, userInput{}, newTargetProbing(0))
+		assert.NoError(t, err)
+
+		list := &targetList{targets: d.members}
+		list.run(d.members[0])
+
+		// the only target is replaced by one that can't be resolved
+		server.set(60, gone)
+		printer.messages = nil
+		d.rediscover(list)
+		assert.True(t, d.members[0].isStopped())
+		assert.Empty(t, d.targets)
+		assert.Contains(t, printer.messages[0], "Failed to resolve the SRV target gone.tcping.test:5060 of _sip._tcp.tcping.test, skipping it")
+
+		assert.Eventually(t, isRunning(d, 0), time.Second, 10*time.Millisecond)
+		assert.False(t, isFinished(d), "the discovery waits for new targets")
+
+		// the next change is tried again
+		server.set(60, b)
+		d.rediscover(list)
+		assert.Len(t, d.members, 2)
+		assert.Equal(t, b, d.members[1].srvRecord)
+		assert.Equal(t, 1, d.running)
+
+		server.set(60, gone)
+		d.rediscover(list)
+		list.wg.Wait()
+		assert.False(t, isFinished(d))
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		server.set(60, a)
+		d, err := newSRVDiscovery("_sip._tcp.tcping.test", &infoRecorder{}, r, nil, userInput{}, newTargetProbing(0))
+		assert.NoError(t, err)
+
+		list := &targetList{targets: d.members}
+		list.run(d.members[0])
+
+		// the targets of other records finish probing while the records change, as with -c
+		stop, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				// the last target to finish looks at the targets
+				stateLock.Lock()
+				d.running = 1
+				stateLock.Unlock()
+				d.targetDone()
+			}
+		}()
+
+		server.set(60, a, b)
+		d.rediscover(list)
+		server.set(60, a)
+		d.rediscover(list)
+		close(stop)
+		<-done
+
+		stateLock.Lock()
+		d.running = 1
+		assert.Len(t, d.targets, 1)
+		assert.Len(t, d.members, 2)
+		stateLock.Unlock()
+
+		server.set(60, gone)
+		d.rediscover(list)
+		list.wg.Wait()
+	})
+
+	t.Run("count", func(t *testing.T) {
+		server.set(60, a)
+		d, err := newSRVDiscovery("_sip._tcp.tcping.test", &infoRecorder{}, r, nil, userInput{}, newTargetProbing(2))
+		assert.NoError(t, err)
+
+		// the discovery is done once its targets are done probing
+		list := &targetList{targets: d.members}
+		list.run(d.members[0])
+		list.wg.Add(1)
+		go d.run(list)
+		list.wg.Wait()
+
+		assert.True(t, isFinished(d))
+		assert.Equal(t, uint(2), d.members[0].totalUnsuccessfulProbes)
+	})
+}
+
+func TestSRVChangesOutput(t *testing.T) {
+	start := time.Now()
+	d := &srvDiscovery{
+		name: "_sip._tcp.tcping.test",
+		changes: []hostnameChange{
+			{When: start, Targets: []string{"a.tcping.test:5060"}},
+			{When: start.Add(time.Minute), PreviousTargets: []string{"a.tcping.test:5060"}, Targets: []string{"b.tcping.test:5060"}},
+		},
+	}
+
+	first, last := createTestStats(t), createTestStats(t)
+	for _, target := range []*tcping{first, last} {
+		target.srv = d
+		target.userInput.hostname = "a.tcping.test"
+		target.startTime = start
+		target.endTime = start.Add(2 * time.Minute)
+	}
+	d.members = []*tcping{first, last}
+
+	assert.Empty(t, first.srvChanges(), "the changes come with the summary")
+	last.addressSummaries = summarizeAddresses(d.members)
+	assert.Equal(t, d.changes[1:], last.srvChanges())
+
+	t.Run("csv", func(t *testing.T) {
+		showTimestamp, showSourceAddress := false, false
+		cp, err := newCSVPrinter(filepath.Join(t.TempDir(), "srv.csv"), &showTimestamp, &showSourceAddress)
+		assert.NoError(t, err)
+		defer cp.cleanup()
+
+		cp.printStatistics(*last)
+		content, err := os.ReadFile(cp.statsFilename)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "SRV Targets Change,a.tcping.test:5060\nTo,b.tcping.test:5060\n")
+	})
+
+	t.Run("db", func(t *testing.T) {
+		db := newDB(":memory:", []string{d.name})
+		defer db.conn.Close()
+
+		db.printStatistics(*last)
+
+		var changedTo []string
+		query := fmt.Sprintf("SELECT hostname_changed_to FROM events WHERE event_type IS '%s' AND hostname IS '%s';", eventTypeHostnameChange, d.name)
+		err := sqlitex.Execute(db.conn, query, &sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				changedTo = append(changedTo, stmt.ColumnText(0))
+				return nil
+			}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b.tcping.test:5060"}, changedTo)
+	})
+
+	t.Run("html", func(t *testing.T) {
+		target := newHTMLTarget(last)
+		assert.Equal(t, []htmlHostnameChange{
+			{Addr: "SRV _sip._tcp.tcping.test", Since: start.Format(timeFormat), Records: "a.tcping.test:5060"},
+			{Addr: "SRV _sip._tcp.tcping.test", Since: start.Add(time.Minute).Format(timeFormat), Records: "b.tcping.test:5060"},
+		}, target.HostnameChanges)
+	})
+}
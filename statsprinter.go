@@ -4,8 +4,10 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gookit/color"
@@ -77,6 +79,16 @@ func (p *colorPrinter) printStatistics(t tcping) {
 	colorYellow("unsuccessful probes: ")
 	colorRed("%d\n", t.totalUnsuccessfulProbes)
 
+	if len(t.failureReasons) > 0 {
+		colorYellow("failure reasons:     ")
+		colorRed("%s\n", formatFailureReasons(t.failureReasons))
+	}
+
+	if len(t.raceWins) > 0 {
+		colorYellow("happy eyeballs wins: ")
+		colorCyan("%s\n", formatRaceWins(t.raceWins))
+	}
+
 	colorYellow("last successful probe:   ")
 	if t.lastSuccessfulProbe.IsZero() {
 		colorRed("Never succeeded\n")
@@ -121,6 +133,26 @@ func (p *colorPrinter) printStatistics(t tcping) {
 		colorLightBlue("%v\n", t.longestDowntime.end.Format(timeFormat))
 	}
 
+	/* outage stats */
+	if outages := t.allOutages(); len(outages) > 0 {
+		colorYellow("outage summary: ")
+		colorRed("%s\n", formatOutageSummary(summarizeOutages(outages, t.totalUptime)))
+
+		for i, o := range outages {
+			colorYellow("  #%d ", i+1)
+			colorRed("%v ", durationToString(o.duration))
+			colorYellow("from ")
+			colorLightBlue("%v ", o.start.Format(timeFormat))
+			colorYellow("to ")
+			colorLightBlue("%v", o.end.Format(timeFormat))
+			colorYellow(", %d failed probes, IP %s", o.failedProbes, o.ip)
+			if o.ongoing {
+				colorRed(" (ongoing)")
+			}
+			fmt.Println()
+		}
+	}
+
 	/* resolve retry stats */
 	if !t.destIsIP {
 		colorYellow("retried to resolve hostname ")
@@ -130,12 +162,22 @@ func (p *colorPrinter) printStatistics(t tcping) {
 		if len(t.hostnameChanges) >= 2 {
 			colorYellow("IP address changes:\n")
 			for i := 0; i < len(t.hostnameChanges)-1; i++ {
-				colorYellow("  from ")
-				colorRed(t.hostnameChanges[i].Addr.String())
-				colorYellow(" to ")
-				colorGreen(t.hostnameChanges[i+1].Addr.String())
-				colorYellow(" at ")
+				// only the records changed
+				if t.hostnameChanges[i].Addr == t.hostnameChanges[i+1].Addr {
+					colorYellow("  still ")
+					colorGreen(t.hostnameChanges[i+1].Addr.String())
+					colorYellow(" at ")
+				} else {
+					colorYellow("  from ")
+					colorRed(t.hostnameChanges[i].Addr.String())
+					colorYellow(" to ")
+					colorGreen(t.hostnameChanges[i+1].Addr.String())
+					colorYellow(" at ")
+				}
 				colorLightBlue("%v\n", t.hostnameChanges[i+1].When.Format(timeFormat))
+				if change := t.hostnameChanges[i+1]; len(change.PreviousRecords) > 0 {
+					colorYellow("    records %s -> %s\n", formatRecords(change.PreviousRecords), formatRecords(change.Records))
+				}
 			}
 		}
 	}
@@ -153,6 +195,14 @@ func (p *colorPrinter) printStatistics(t tcping) {
 		colorYellow("/")
 		colorRed("%.3f", t.rttResults.max)
 		colorYellow(" ms\n")
+
+		colorYellow("rtt p50/p90/p95/p99: ")
+		colorCyan("%.3f/%.3f/%.3f/%.3f", t.rttResults.p50, t.rttResults.p90, t.rttResults.p95, t.rttResults.p99)
+		colorYellow(" ms\n")
+
+		colorYellow("rtt stddev/jitter: ")
+		colorCyan("%.3f/%.3f", t.rttResults.stdDev, t.rttResults.jitter)
+		colorYellow(" ms\n")
 	}
 
 	colorYellow("--------------------------------------\n")
@@ -165,9 +215,44 @@ func (p *colorPrinter) printStatistics(t tcping) {
 
 	durationTime := time.Time{}.Add(t.totalDowntime + t.totalUptime)
 	colorYellow("duration (HH:MM:SS): %v\n\n", durationTime.Format(hourFormat))
+
+	/* per-address summary */
+	if len(t.addressSummaries) > 0 {
+		width := addressColumnWidth(t.addressSummaries)
+
+		colorYellow("%s\n", addressSummaryTitle(t))
+		for _, s := range t.addressSummaries {
+			colorLightBlue("%-*s ", width, s.label())
+			switch {
+			case s.stopped:
+				colorYellow("%-4s ", s.status())
+			case s.down:
+				colorRed("%-4s ", s.status())
+			default:
+				colorGreen("%-4s ", s.status())
+			}
+			colorYellow("%s\n", formatAddressSummary(s))
+		}
+		if comparison := formatFamilyComparison(t.addressSummaries); comparison != "" {
+			colorCyan("%s\n", comparison)
+		}
+		if changes := t.srvChanges(); len(changes) > 0 {
+			colorYellow("SRV target changes:\n")
+			for _, change := range changes {
+				colorYellow("  %s at ", formatSRVChange(change))
+				colorLightBlue("%v\n", change.When.Format(timeFormat))
+			}
+		}
+		fmt.Println()
+	}
 }
 
-func (p *colorPrinter) printProbeSuccess(sourceAddr string, userInput userInput, streak uint, rtt float32) {
+func (p *colorPrinter) printProbeSuccess(sourceAddr string, userInput userInput, streak uint, rtt float32, details probeDetails) {
+	if userInput.showFailuresOnly {
+		return
+	}
+
+	extra := probeDetailsToString(details)
 	timestamp := ""
 	if *p.showTimestamp {
 		timestamp = time.Now().Format(timeFormat)
@@ -175,60 +260,65 @@ func (p *colorPrinter) printProbeSuccess(sourceAddr string, userInput userInput,
 	if userInput.hostname == "" {
 		if timestamp == "" {
 			if userInput.showSourceAddress {
-				colorLightGreen("Reply from %s on port %d using %s TCP_conn=%d time=%.3f ms\n", userInput.ip.String(), userInput.port, sourceAddr, streak, rtt)
+				colorLightGreen("Reply from %s on port %d using %s TCP_conn=%d time=%.3f ms%s\n", userInput.ip.String(), userInput.port, sourceAddr, streak, rtt, extra)
 			} else {
-				colorLightGreen("Reply from %s on port %d TCP_conn=%d time=%.3f ms\n", userInput.ip.String(), userInput.port, streak, rtt)
+				colorLightGreen("Reply from %s on port %d TCP_conn=%d time=%.3f ms%s\n", userInput.ip.String(), userInput.port, streak, rtt, extra)
 			}
 		} else {
 			if userInput.showSourceAddress {
-				colorLightGreen("%s Reply from %s on port %d using %s TCP_conn=%d time=%.3f ms\n", timestamp, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt)
+				colorLightGreen("%s Reply from %s on port %d using %s TCP_conn=%d time=%.3f ms%s\n", timestamp, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt, extra)
 			} else {
-				colorLightGreen("%s Reply from %s on port %d TCP_conn=%d time=%.3f ms\n", timestamp, userInput.ip.String(), userInput.port, streak, rtt)
+				colorLightGreen("%s Reply from %s on port %d TCP_conn=%d time=%.3f ms%s\n", timestamp, userInput.ip.String(), userInput.port, streak, rtt, extra)
 			}
 		}
 	} else {
 		if timestamp == "" {
 			if userInput.showSourceAddress {
-				colorLightGreen("Reply from %s (%s) on port %d using %s TCP_conn=%d time=%.3f ms\n", userInput.hostname, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt)
+				colorLightGreen("Reply from %s (%s) on port %d using %s TCP_conn=%d time=%.3f ms%s\n", userInput.hostname, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt, extra)
 			} else {
-				colorLightGreen("Reply from %s (%s) on port %d TCP_conn=%d time=%.3f ms\n", userInput.hostname, userInput.ip.String(), userInput.port, streak, rtt)
+				colorLightGreen("Reply from %s (%s) on port %d TCP_conn=%d time=%.3f ms%s\n", userInput.hostname, userInput.ip.String(), userInput.port, streak, rtt, extra)
 			}
 		} else {
 			if userInput.showSourceAddress {
-				colorLightGreen("%s Reply from %s (%s) on port %d using %s TCP_conn=%d time=%.3f ms\n", timestamp, userInput.hostname, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt)
+				colorLightGreen("%s Reply from %s (%s) on port %d using %s TCP_conn=%d time=%.3f ms%s\n", timestamp, userInput.hostname, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt, extra)
 			} else {
-				colorLightGreen("%s Reply from %s (%s) on port %d TCP_conn=%d time=%.3f ms\n", timestamp, userInput.hostname, userInput.ip.String(), userInput.port, streak, rtt)
+				colorLightGreen("%s Reply from %s (%s) on port %d TCP_conn=%d time=%.3f ms%s\n", timestamp, userInput.hostname, userInput.ip.String(), userInput.port, streak, rtt, extra)
 			}
 		}
 	}
 }
 
-func (p *colorPrinter) printProbeFail(userInput userInput, streak uint) {
+func (p *colorPrinter) printProbeFail(userInput userInput, streak uint, details probeDetails) {
+	extra := probeDetailsToString(details)
 	timestamp := ""
 	if *p.showTimestamp {
 		timestamp = time.Now().Format(timeFormat)
 	}
 	if userInput.hostname == "" {
 		if timestamp == "" {
-			colorRed("No reply from %s on port %d TCP_conn=%d\n", userInput.ip, userInput.port, streak)
+			colorRed("No reply from %s on port %d TCP_conn=%d%s\n", userInput.ip, userInput.port, streak, extra)
 		} else {
-			colorRed("%s No reply from %s on port %d TCP_conn=%d\n", timestamp, userInput.ip, userInput.port, streak)
+			colorRed("%s No reply from %s on port %d TCP_conn=%d%s\n", timestamp, userInput.ip, userInput.port, streak, extra)
 		}
 	} else {
 		if timestamp == "" {
-			colorRed("No reply from %s (%s) on port %d TCP_conn=%d\n", userInput.hostname, userInput.ip, userInput.port, streak)
+			colorRed("No reply from %s (%s) on port %d TCP_conn=%d%s\n", userInput.hostname, userInput.ip, userInput.port, streak, extra)
 		} else {
-			colorRed("%s No reply from %s (%s) on port %d TCP_conn=%d\n", timestamp, userInput.hostname, userInput.ip, userInput.port, streak)
+			colorRed("%s No reply from %s (%s) on port %d TCP_conn=%d%s\n", timestamp, userInput.hostname, userInput.ip, userInput.port, streak, extra)
 		}
 	}
 }
 
-func (p *colorPrinter) printTotalDownTime(downtime time.Duration) {
-	colorYellow("No response received for %s\n", durationToString(downtime))
+func (p *colorPrinter) printTotalDownTime(userInput userInput, downtime time.Duration) {
+	if userInput.hostname == "" {
+		colorYellow("No response received from %s on port %d for %s\n", userInput.ip, userInput.port, durationToString(downtime))
+	} else {
+		colorYellow("No response received from %s (%s) on port %d for %s\n", userInput.hostname, userInput.ip, userInput.port, durationToString(downtime))
+	}
 }
 
-func (p *colorPrinter) printRetryingToResolve(hostname string) {
-	colorLightYellow("retrying to resolve %s\n", hostname)
+func (p *colorPrinter) printRetryingToResolve(hostname, resolver string) {
+	colorLightYellow("%s\n", retryingToResolveMessage(hostname, resolver))
 }
 
 func (p *colorPrinter) printInfo(format string, args ...any) {
@@ -243,18 +333,57 @@ func (p *colorPrinter) printVersion() {
 	colorGreen("TCPING version %s\n", version)
 }
 
+func (p *colorPrinter) printComparison(c comparison) {
+	colorYellow("\n%s\n", c.title())
+	colorYellow("%-18s %16s %16s   %s\n", "", "before", "after", "change")
+
+	for _, m := range c.metrics {
+		colorYellow("%-18s ", m.name+":")
+		colorCyan("%16s %16s   ", m.format(m.before), m.format(m.after))
+
+		switch {
+		case m.worse():
+			colorRed("%s\n", m.formatChange())
+		case m.better():
+			colorGreen("%s\n", m.formatChange())
+		default:
+			colorYellow("%s\n", m.formatChange())
+		}
+	}
+
+	switch {
+	case c.shift.significant() && c.shift.difference > 0:
+		colorRed("%s\n\n", c.shift)
+	case c.shift.significant():
+		colorGreen("%s\n\n", c.shift)
+	default:
+		colorYellow("%s\n\n", c.shift)
+	}
+}
+
 // MARK: PLAIN PRINTER
 
 type plainPrinter struct {
 	showTimestamp *bool
+	out           io.Writer // out is set when the output is embedded, e.g. in the HTML report
 }
 
 func newPlainPrinter(showTimestamp *bool) *plainPrinter {
 	return &plainPrinter{showTimestamp: showTimestamp}
 }
 
+// printf writes to out, or to the standard output when it's not set
+func (p *plainPrinter) printf(format string, args ...any) {
+	out := p.out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	fmt.Fprintf(out, format, args...)
+}
+
 func (p *plainPrinter) printStart(hostname string, port uint16) {
-	fmt.Printf("TCPinging %s on port %d\n", hostname, port)
+	p.printf("TCPinging %s on port %d\n", hostname, port)
 }
 
 func (p *plainPrinter) printStatistics(t tcping) {
@@ -267,90 +396,153 @@ func (p *plainPrinter) printStatistics(t tcping) {
 
 	/* general stats */
 	if !t.destIsIP {
-		fmt.Printf("\n--- %s (%s) TCPing statistics ---\n", t.userInput.hostname, t.userInput.ip)
+		p.printf("\n--- %s (%s) TCPing statistics ---\n", t.userInput.hostname, t.userInput.ip)
 	} else {
-		fmt.Printf("\n--- %s TCPing statistics ---\n", t.userInput.hostname)
+		p.printf("\n--- %s TCPing statistics ---\n", t.userInput.hostname)
 	}
-	fmt.Printf("%d probes transmitted on port %d | %d received, ", totalPackets, t.userInput.port, t.totalSuccessfulProbes)
+	p.printf("%d probes transmitted on port %d | %d received, ", totalPackets, t.userInput.port, t.totalSuccessfulProbes)
 
 	/* packet loss stats */
-	fmt.Printf("%.2f%% packet loss\n", packetLoss)
+	p.printf("%.2f%% packet loss\n", packetLoss)
 
 	/* successful packet stats */
-	fmt.Printf("successful probes:   %d\n", t.totalSuccessfulProbes)
+	p.printf("successful probes:   %d\n", t.totalSuccessfulProbes)
 
 	/* unsuccessful packet stats */
-	fmt.Printf("unsuccessful probes: %d\n", t.totalUnsuccessfulProbes)
+	p.printf("unsuccessful probes: %d\n", t.totalUnsuccessfulProbes)
 
-	fmt.Printf("last successful probe:   ")
+	if len(t.failureReasons) > 0 {
+		p.printf("failure reasons:     %s\n", formatFailureReasons(t.failureReasons))
+	}
+
+	if len(t.raceWins) > 0 {
+		p.printf("happy eyeballs wins: %s\n", formatRaceWins(t.raceWins))
+	}
+
+	p.printf("last successful probe:   ")
 	if t.lastSuccessfulProbe.IsZero() {
-		fmt.Printf("Never succeeded\n")
+		p.printf("Never succeeded\n")
 	} else {
-		fmt.Printf("%v\n", t.lastSuccessfulProbe.Format(timeFormat))
+		p.printf("%v\n", t.lastSuccessfulProbe.Format(timeFormat))
 	}
 
-	fmt.Printf("last unsuccessful probe: ")
+	p.printf("last unsuccessful probe: ")
 	if t.lastUnsuccessfulProbe.IsZero() {
-		fmt.Printf("Never failed\n")
+		p.printf("Never failed\n")
 	} else {
-		fmt.Printf("%v\n", t.lastUnsuccessfulProbe.Format(timeFormat))
+		p.printf("%v\n", t.lastUnsuccessfulProbe.Format(timeFormat))
 	}
 
 	/* uptime and downtime stats */
-	fmt.Printf("total uptime: %s\n", durationToString(t.totalUptime))
-	fmt.Printf("total downtime: %s\n", durationToString(t.totalDowntime))
+	p.printf("total uptime: %s\n", durationToString(t.totalUptime))
+	p.printf("total downtime: %s\n", durationToString(t.totalDowntime))
 
 	/* longest uptime stats */
 	if t.longestUptime.duration != 0 {
 		uptime := durationToString(t.longestUptime.duration)
 
-		fmt.Printf("longest consecutive uptime:   ")
-		fmt.Printf("%v ", uptime)
-		fmt.Printf("from %v ", t.longestUptime.start.Format(timeFormat))
-		fmt.Printf("to %v\n", t.longestUptime.end.Format(timeFormat))
+		p.printf("longest consecutive uptime:   ")
+		p.printf("%v ", uptime)
+		p.printf("from %v ", t.longestUptime.start.Format(timeFormat))
+		p.printf("to %v\n", t.longestUptime.end.Format(timeFormat))
 	}
 
 	/* longest downtime stats */
 	if t.longestDowntime.duration != 0 {
 		downtime := durationToString(t.longestDowntime.duration)
 
-		fmt.Printf("longest consecutive downtime: %v ", downtime)
-		fmt.Printf("from %v ", t.longestDowntime.start.Format(timeFormat))
-		fmt.Printf("to %v\n", t.longestDowntime.end.Format(timeFormat))
+		p.printf("longest consecutive downtime: %v ", downtime)
+		p.printf("from %v ", t.longestDowntime.start.Format(timeFormat))
+		p.printf("to %v\n", t.longestDowntime.end.Format(timeFormat))
+	}
+
+	/* outage stats */
+	if outages := t.allOutages(); len(outages) > 0 {
+		p.printf("outage summary: %s\n", formatOutageSummary(summarizeOutages(outages, t.totalUptime)))
+
+		for i, o := range outages {
+			p.printf("  #%d %v ", i+1, durationToString(o.duration))
+			p.printf("from %v ", o.start.Format(timeFormat))
+			p.printf("to %v", o.end.Format(timeFormat))
+			p.printf(", %d failed probes, IP %s", o.failedProbes, o.ip)
+			if o.ongoing {
+				p.printf(" (ongoing)")
+			}
+			p.printf("\n")
+		}
 	}
 
 	/* resolve retry stats */
 	if !t.destIsIP {
-		fmt.Printf("retried to resolve hostname %d times\n", t.retriedHostnameLookups)
+		p.printf("retried to resolve hostname %d times\n", t.retriedHostnameLookups)
 
 		if len(t.hostnameChanges) >= 2 {
-			fmt.Printf("IP address changes:\n")
+			p.printf("IP address changes:\n")
 			for i := 0; i < len(t.hostnameChanges)-1; i++ {
-				fmt.Printf("  from %s", t.hostnameChanges[i].Addr.String())
-				fmt.Printf(" to %s", t.hostnameChanges[i+1].Addr.String())
-				fmt.Printf(" at %v\n", t.hostnameChanges[i+1].When.Format(timeFormat))
+				// only the records changed
+				if t.hostnameChanges[i].Addr == t.hostnameChanges[i+1].Addr {
+					p.printf("  still %s", t.hostnameChanges[i+1].Addr.String())
+				} else {
+					p.printf("  from %s", t.hostnameChanges[i].Addr.String())
+					p.printf(" to %s", t.hostnameChanges[i+1].Addr.String())
+				}
+				p.printf(" at %v\n", t.hostnameChanges[i+1].When.Format(timeFormat))
+				if change := t.hostnameChanges[i+1]; len(change.PreviousRecords) > 0 {
+					p.printf("    records %s -> %s\n", formatRecords(change.PreviousRecords), formatRecords(change.Records))
+				}
 			}
 		}
 	}
 
 	if t.rttResults.hasResults {
-		fmt.Printf("rtt min/avg/max: ")
-		fmt.Printf("%.3f/%.3f/%.3f ms\n", t.rttResults.min, t.rttResults.average, t.rttResults.max)
+		p.printf("rtt min/avg/max: ")
+		p.printf("%.3f/%.3f/%.3f ms\n", t.rttResults.min, t.rttResults.average, t.rttResults.max)
+
+		p.printf("rtt p50/p90/p95/p99: ")
+		p.printf("%.3f/%.3f/%.3f/%.3f ms\n", t.rttResults.p50, t.rttResults.p90, t.rttResults.p95, t.rttResults.p99)
+
+		p.printf("rtt stddev/jitter: ")
+		p.printf("%.3f/%.3f ms\n", t.rttResults.stdDev, t.rttResults.jitter)
 	}
 
-	fmt.Printf("--------------------------------------\n")
-	fmt.Printf("TCPing started at: %v\n", t.startTime.Format(timeFormat))
+	p.printf("--------------------------------------\n")
+	p.printf("TCPing started at: %v\n", t.startTime.Format(timeFormat))
 
 	/* If the program was not terminated, no need to show the end time */
 	if !t.endTime.IsZero() {
-		fmt.Printf("TCPing ended at:   %v\n", t.endTime.Format(timeFormat))
+		p.printf("TCPing ended at:   %v\n", t.endTime.Format(timeFormat))
 	}
 
 	durationTime := time.Time{}.Add(t.totalDowntime + t.totalUptime)
-	fmt.Printf("duration (HH:MM:SS): %v\n\n", durationTime.Format(hourFormat))
+	p.printf("duration (HH:MM:SS): %v\n\n", durationTime.Format(hourFormat))
+
+	/* per-address summary */
+	if len(t.addressSummaries) > 0 {
+		width := addressColumnWidth(t.addressSummaries)
+
+		p.printf("%s\n", addressSummaryTitle(t))
+		for _, s := range t.addressSummaries {
+			p.printf("%-*s %-4s %s\n", width, s.label(), s.status(), formatAddressSummary(s))
+		}
+		if comparison := formatFamilyComparison(t.addressSummaries); comparison != "" {
+			p.printf("%s\n", comparison)
+		}
+		if changes := t.srvChanges(); len(changes) > 0 {
+			p.printf("SRV target changes:\n")
+			for _, change := range changes {
+				p.printf("  %s at %v\n", formatSRVChange(change), change.When.Format(timeFormat))
+			}
+		}
+		p.printf("\n")
+	}
 }
 
-func (p *plainPrinter) printProbeSuccess(sourceAddr string, userInput userInput, streak uint, rtt float32) {
+func (p *plainPrinter) printProbeSuccess(sourceAddr string, userInput userInput, streak uint, rtt float32, details probeDetails) {
+	if userInput.showFailuresOnly {
+		return
+	}
+
+	extra := probeDetailsToString(details)
 	timestamp := ""
 	if *p.showTimestamp {
 		timestamp = time.Now().Format(timeFormat)
@@ -358,72 +550,88 @@ func (p *plainPrinter) printProbeSuccess(sourceAddr string, userInput userInput,
 	if userInput.hostname == "" {
 		if timestamp == "" {
 			if userInput.showSourceAddress {
-				fmt.Printf("Reply from %s on port %d using %s TCP_conn=%d time=%.3f ms\n", userInput.ip.String(), userInput.port, sourceAddr, streak, rtt)
+				p.printf("Reply from %s on port %d using %s TCP_conn=%d time=%.3f ms%s\n", userInput.ip.String(), userInput.port, sourceAddr, streak, rtt, extra)
 			} else {
-				fmt.Printf("Reply from %s on port %d TCP_conn=%d time=%.3f ms\n", userInput.ip.String(), userInput.port, streak, rtt)
+				p.printf("Reply from %s on port %d TCP_conn=%d time=%.3f ms%s\n", userInput.ip.String(), userInput.port, streak, rtt, extra)
 			}
 		} else {
 			if userInput.showSourceAddress {
-				fmt.Printf("%s Reply from %s on port %d using %s TCP_conn=%d time=%.3f ms\n", timestamp, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt)
+				p.printf("%s Reply from %s on port %d using %s TCP_conn=%d time=%.3f ms%s\n", timestamp, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt, extra)
 			} else {
-				fmt.Printf("%s Reply from %s on port %d TCP_conn=%d time=%.3f ms\n", timestamp, userInput.ip.String(), userInput.port, streak, rtt)
+				p.printf("%s Reply from %s on port %d TCP_conn=%d time=%.3f ms%s\n", timestamp, userInput.ip.String(), userInput.port, streak, rtt, extra)
 			}
 		}
 	} else {
 		if timestamp == "" {
 			if userInput.showSourceAddress {
-				fmt.Printf("Reply from %s (%s) on port %d using %s TCP_conn=%d time=%.3f ms\n", userInput.hostname, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt)
+				p.printf("Reply from %s (%s) on port %d using %s TCP_conn=%d time=%.3f ms%s\n", userInput.hostname, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt, extra)
 			} else {
-				fmt.Printf("Reply from %s (%s) on port %d TCP_conn=%d time=%.3f ms\n", userInput.hostname, userInput.ip.String(), userInput.port, streak, rtt)
+				p.printf("Reply from %s (%s) on port %d TCP_conn=%d time=%.3f ms%s\n", userInput.hostname, userInput.ip.String(), userInput.port, streak, rtt, extra)
 			}
 		} else {
 			if userInput.showSourceAddress {
-				fmt.Printf("%s Reply from %s (%s) on port %d using %s TCP_conn=%d time=%.3f ms\n", timestamp, userInput.hostname, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt)
+				p.printf("%s Reply from %s (%s) on port %d using %s TCP_conn=%d time=%.3f ms%s\n", timestamp, userInput.hostname, userInput.ip.String(), userInput.port, sourceAddr, streak, rtt, extra)
 			} else {
-				fmt.Printf("%s Reply from %s (%s) on port %d TCP_conn=%d time=%.3f ms\n", timestamp, userInput.hostname, userInput.ip.String(), userInput.port, streak, rtt)
+				p.printf("%s Reply from %s (%s) on port %d TCP_conn=%d time=%.3f ms%s\n", timestamp, userInput.hostname, userInput.ip.String(), userInput.port, streak, rtt, extra)
 			}
 		}
 	}
 }
 
-func (p *plainPrinter) printProbeFail(userInput userInput, streak uint) {
+func (p *plainPrinter) printProbeFail(userInput userInput, streak uint, details probeDetails) {
+	extra := probeDetailsToString(details)
 	timestamp := ""
 	if *p.showTimestamp {
 		timestamp = time.Now().Format(timeFormat)
 	}
 	if userInput.hostname == "" {
 		if timestamp == "" {
-			fmt.Printf("No reply from %s on port %d TCP_conn=%d\n", userInput.ip, userInput.port, streak)
+			p.printf("No reply from %s on port %d TCP_conn=%d%s\n", userInput.ip, userInput.port, streak, extra)
 		} else {
-			fmt.Printf("%s No reply from %s on port %d TCP_conn=%d\n", timestamp, userInput.ip, userInput.port, streak)
+			p.printf("%s No reply from %s on port %d TCP_conn=%d%s\n", timestamp, userInput.ip, userInput.port, streak, extra)
 		}
 	} else {
 		if timestamp == "" {
-			fmt.Printf("No reply from %s (%s) on port %d TCP_conn=%d\n", userInput.hostname, userInput.ip, userInput.port, streak)
+			p.printf("No reply from %s (%s) on port %d TCP_conn=%d%s\n", userInput.hostname, userInput.ip, userInput.port, streak, extra)
 		} else {
-			fmt.Printf("%s No reply from %s (%s) on port %d TCP_conn=%d\n", timestamp, userInput.hostname, userInput.ip, userInput.port, streak)
+			p.printf("%s No reply from %s (%s) on port %d TCP_conn=%d%s\n", timestamp, userInput.hostname, userInput.ip, userInput.port, streak, extra)
 		}
 	}
 }
 
-func (p *plainPrinter) printTotalDownTime(downtime time.Duration) {
-	fmt.Printf("No response received for %s\n", durationToString(downtime))
+func (p *plainPrinter) printTotalDownTime(userInput userInput, downtime time.Duration) {
+	if userInput.hostname == "" {
+		p.printf("No response received from %s on port %d for %s\n", userInput.ip, userInput.port, durationToString(downtime))
+	} else {
+		p.printf("No response received from %s (%s) on port %d for %s\n", userInput.hostname, userInput.ip, userInput.port, durationToString(downtime))
+	}
 }
 
-func (p *plainPrinter) printRetryingToResolve(hostname string) {
-	fmt.Printf("retrying to resolve %s\n", hostname)
+func (p *plainPrinter) printRetryingToResolve(hostname, resolver string) {
+	p.printf("%s\n", retryingToResolveMessage(hostname, resolver))
 }
 
 func (p *plainPrinter) printInfo(format string, args ...any) {
-	fmt.Printf(format+"\n", args...)
+	p.printf(format+"\n", args...)
 }
 
 func (p *plainPrinter) printError(format string, args ...any) {
-	fmt.Printf(format+"\n", args...)
+	p.printf(format+"\n", args...)
 }
 
 func (p *plainPrinter) printVersion() {
-	fmt.Printf("TCPING version %s\n", version)
+	p.printf("TCPING version %s\n", version)
+}
+
+func (p *plainPrinter) printComparison(c comparison) {
+	p.printf("\n%s\n", c.title())
+	p.printf("%-18s %16s %16s   %s\n", "", "before", "after", "change")
+
+	for _, m := range c.metrics {
+		p.printf("%-18s %16s %16s   %s\n", m.name+":", m.format(m.before), m.format(m.after), m.formatChange())
+	}
+
+	p.printf("%s\n\n", c.shift)
 }
 
 // MARK: JSON PRINTER
@@ -470,8 +678,94 @@ const (
 	versionEvent JSONEventType = "version"
 	// errorEvent is a event type for [printError] method.
 	errorEvent JSONEventType = "error"
+	// comparisonEvent is a event type for [printComparison] method.
+	comparisonEvent JSONEventType = "comparison"
 )
 
+// JSONOutage is a single downtime of the target in the stats event.
+type JSONOutage struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Duration in seconds.
+	//
+	// It's a string on purpose, as we'd like to have exactly
+	// 3 decimal places without doing extra math.
+	Duration     string `json:"duration"`
+	FailedProbes uint   `json:"failed_probes"`
+	// Addr is the IP address of the target when it went down.
+	Addr string `json:"addr,omitempty"`
+	// Ongoing is set when the target has not come back up yet.
+	Ongoing bool `json:"ongoing,omitempty"`
+}
+
+// JSONSRVRecord is the SRV record a target was discovered through
+type JSONSRVRecord struct {
+	// Target is the host:port of the record.
+	Target   string `json:"target"`
+	Priority uint16 `json:"priority"`
+	Weight   uint16 `json:"weight"`
+}
+
+// JSONAddress is the summary of one of the addresses of the hostname in the stats event,
+// when every address or both address families are probed, or of one of the targets of SRV records.
+type JSONAddress struct {
+	Addr string `json:"addr"`
+	// Family is "IPv4" or "IPv6" when both families are probed.
+	Family string `json:"family,omitempty"`
+	// SRV is the record of the target when it was discovered through SRV records.
+	SRV *JSONSRVRecord `json:"srv,omitempty"`
+	// Status is "up" or "down", as of the last probe of the address,
+	// or "gone" when its SRV record was removed.
+	Status                string `json:"status"`
+	TotalPackets          uint   `json:"total_packets"`
+	TotalSuccessfulProbes uint   `json:"total_successful_probes"`
+	// TotalPacketLoss in percent.
+	//
+	// It's a string on purpose, as we'd like to have exactly
+	// 2 decimal places without doing extra math.
+	TotalPacketLoss string `json:"total_packet_loss"`
+	Outages         int    `json:"outages"`
+	// LatencyAvg and LatencyP99 are omitted when the address never answered.
+	//
+	// They're strings on purpose, as we'd like to have exactly
+	// 3 decimal places without doing extra math.
+	LatencyAvg string `json:"latency_avg,omitempty"`
+	LatencyP99 string `json:"latency_p99,omitempty"`
+}
+
+// JSONComparedMetric is a single statistic of both runs in the comparison event.
+//
+// The values are strings on purpose, as we'd like to have exactly
+// 3 decimal places without doing extra math.
+type JSONComparedMetric struct {
+	Name   string `json:"name"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+	Change string `json:"change"`
+	// Unit is "%" for the packet loss, "ms" for the RTTs, "s" for
+	// the durations and empty for the counts of probes and outages.
+	Unit string `json:"unit,omitempty"`
+}
+
+// JSONLatencyShift is the change of the average RTT between
+// both runs, tested by Welch's t-test, in the comparison event.
+type JSONLatencyShift struct {
+	// Difference is the average RTT of the second run minus the one of the first run in ms.
+	//
+	// It's a string on purpose, as we'd like to have exactly
+	// 3 decimal places without doing extra math.
+	Difference string `json:"difference"`
+	// T, DF and P are the t value, degrees of freedom and two-tailed p-value of the test,
+	// omitted when a run has fewer than two successful probes.
+	//
+	// They're strings on purpose, as we'd like to have exactly
+	// 3 decimal places without doing extra math.
+	T           string `json:"t,omitempty"`
+	DF          string `json:"df,omitempty"`
+	P           string `json:"p,omitempty"`
+	Significant bool   `json:"significant"`
+}
+
 // JSONData contains all possible fields for JSON output.
 // Because one event usually contains only a subset of fields,
 // other fields will be omitted in the output.
@@ -489,6 +783,7 @@ type JSONData struct {
 	LocalAddr            string           `json:"local_address,omitempty"`
 	Hostname             string           `json:"hostname,omitempty"`
 	HostnameResolveTries uint             `json:"hostname_resolve_tries,omitempty"`
+	Resolver             string           `json:"resolver,omitempty"`
 	HostnameChanges      []hostnameChange `json:"hostname_changes,omitempty"`
 	DestIsIP             *bool            `json:"dst_is_ip,omitempty"`
 	Port                 uint16           `json:"port,omitempty"`
@@ -499,10 +794,52 @@ type JSONData struct {
 	// It's a pointer on purpose, otherwise success=false will be omitted,
 	// but we still need to omit it for non-probe messages.
 	Success *bool `json:"success,omitempty"`
+	// FailureReason is the category of the error of a failed probe,
+	// e.g. "timeout" or "refused".
+	FailureReason string `json:"failure_reason,omitempty"`
+	// Draining is set on the probes of a previous IP address after re-resolving,
+	// which are not a part of the statistics.
+	Draining bool `json:"draining,omitempty"`
 
 	// Latency in ms for a successful probe messages.
 	Latency float32 `json:"latency,omitempty"`
 
+	// DNSTime is the time in ms to resolve the hostname before the probe,
+	// only set in timings mode when the hostname was resolved.
+	DNSTime float32 `json:"dns_time,omitempty"`
+	// TCPConnectTime is the time in ms to establish the TCP connection,
+	// when the probe does more than connecting, e.g. a TLS handshake.
+	TCPConnectTime float32 `json:"tcp_connect_time,omitempty"`
+	// FirstByteTime is the time in ms from connecting to
+	// receiving the first byte sent by the server.
+	FirstByteTime float32 `json:"first_byte_time,omitempty"`
+	// TLSHandshakeTime is the time in ms to complete the TLS handshake.
+	TLSHandshakeTime float32    `json:"tls_handshake_time,omitempty"`
+	TLSVersion       string     `json:"tls_version,omitempty"`
+	TLSCipherSuite   string     `json:"tls_cipher_suite,omitempty"`
+	TLSALPN          string     `json:"tls_alpn,omitempty"`
+	CertSubject      string     `json:"cert_subject,omitempty"`
+	CertExpiry       *time.Time `json:"cert_expiry,omitempty"`
+	// TLSError is the reason of a failed TLS handshake.
+	TLSError string `json:"tls_error,omitempty"`
+
+	// HappyEyeballsWinner is the address family of the connection that won the race,
+	// "IPv4" or "IPv6", and HappyEyeballsAttempts the number of connection attempts.
+	HappyEyeballsWinner   string `json:"happy_eyeballs_winner,omitempty"`
+	HappyEyeballsAttempts int    `json:"happy_eyeballs_attempts,omitempty"`
+
+	// HTTPStatusCode is the status code of the HTTP response.
+	HTTPStatusCode int `json:"http_status_code,omitempty"`
+	// HTTPTTFB is the time in ms from sending the HTTP request
+	// to receiving the first byte of the response.
+	HTTPTTFB float32 `json:"http_ttfb,omitempty"`
+	// HTTPTotalTime is the time in ms from sending the HTTP request
+	// to reading the whole response.
+	HTTPTotalTime float32 `json:"http_total_time,omitempty"`
+	// HTTPError is the reason of a failed HTTP request,
+	// including an unexpected status code.
+	HTTPError string `json:"http_error,omitempty"`
+
 	// LatencyMin is a latency stat for the stats event.
 	//
 	// It's a string on purpose, as we'd like to have exactly
@@ -519,6 +856,26 @@ type JSONData struct {
 	// 3 decimal places without doing extra math.
 	LatencyMax string `json:"latency_max,omitempty"`
 
+	// LatencyP50, LatencyP90, LatencyP95 and LatencyP99
+	// are the latency percentiles for the stats event.
+	//
+	// They're strings on purpose, as we'd like to have exactly
+	// 3 decimal places without doing extra math.
+	LatencyP50 string `json:"latency_p50,omitempty"`
+	LatencyP90 string `json:"latency_p90,omitempty"`
+	LatencyP95 string `json:"latency_p95,omitempty"`
+	LatencyP99 string `json:"latency_p99,omitempty"`
+	// LatencyStdDev is the standard deviation of latency for the stats event.
+	//
+	// It's a string on purpose, as we'd like to have exactly
+	// 3 decimal places without doing extra math.
+	LatencyStdDev string `json:"latency_stddev,omitempty"`
+	// LatencyJitter is the RFC 3550 inter-probe jitter for the stats event.
+	//
+	// It's a string on purpose, as we'd like to have exactly
+	// 3 decimal places without doing extra math.
+	LatencyJitter string `json:"latency_jitter,omitempty"`
+
 	// TotalDuration is a total amount of seconds that program was running.
 	//
 	// It's a string on purpose, as we'd like to have exactly
@@ -548,6 +905,24 @@ type JSONData struct {
 	LongestDowntimeEnd   *time.Time `json:"longest_downtime_end,omitempty"`
 	LongestDowntimeStart *time.Time `json:"longest_downtime_start,omitempty"`
 
+	// Outages lists every downtime of the target, including the ongoing one.
+	Outages []JSONOutage `json:"outages,omitempty"`
+	// Addresses summarizes every address of the hostname, or one of each family, when they are all probed,
+	// or every target of the SRV records. It's only a part of the stats event of the last address or target.
+	Addresses []JSONAddress `json:"addresses,omitempty"`
+	// SRVChanges lists every change of the targets of the SRV records, along with Addresses.
+	SRVChanges []hostnameChange `json:"srv_changes,omitempty"`
+	// MTTR is the mean time to recovery in seconds, i.e. the average duration of an outage.
+	//
+	// It's a string on purpose, as we'd like to have exactly
+	// 3 decimal places without doing extra math.
+	MTTR string `json:"mttr,omitempty"`
+	// MTBF is the mean time between failures in seconds, i.e. the uptime per outage.
+	//
+	// It's a string on purpose, as we'd like to have exactly
+	// 3 decimal places without doing extra math.
+	MTBF string `json:"mtbf,omitempty"`
+
 	// TotalPacketLoss in seconds.
 	//
 	// It's a string on purpose, as we'd like to have exactly
@@ -556,10 +931,22 @@ type JSONData struct {
 	TotalPackets            uint   `json:"total_packets,omitempty"`
 	TotalSuccessfulProbes   uint   `json:"total_successful_probes,omitempty"`
 	TotalUnsuccessfulProbes uint   `json:"total_unsuccessful_probes,omitempty"`
+	// FailureReasons counts the failed probes by their reason.
+	FailureReasons map[failureReason]uint `json:"failure_reasons,omitempty"`
+	// HappyEyeballsWins counts the races won by each address family.
+	HappyEyeballsWins map[string]uint `json:"happy_eyeballs_wins,omitempty"`
 	// TotalUptime in seconds.
 	TotalUptime float64 `json:"total_uptime,omitempty"`
 	// TotalDowntime in seconds.
 	TotalDowntime float64 `json:"total_downtime,omitempty"`
+
+	// BeforeTarget and AfterTarget are the compared targets of the comparison event.
+	BeforeTarget string `json:"before_target,omitempty"`
+	AfterTarget  string `json:"after_target,omitempty"`
+	// Comparison lists the statistics of both runs for the comparison event.
+	Comparison []JSONComparedMetric `json:"comparison,omitempty"`
+	// LatencyShift is the change of the average RTT for the comparison event.
+	LatencyShift *JSONLatencyShift `json:"latency_shift,omitempty"`
 }
 
 // printStart prints the initial message before doing probes.
@@ -573,7 +960,11 @@ func (p *jsonPrinter) printStart(hostname string, port uint16) {
 }
 
 // printReply prints TCP probe replies according to our policies in JSON format.
-func (p *jsonPrinter) printProbeSuccess(sourceAddr string, userInput userInput, streak uint, rtt float32) {
+func (p *jsonPrinter) printProbeSuccess(sourceAddr string, userInput userInput, streak uint, rtt float32, details probeDetails) {
+	if userInput.showFailuresOnly {
+		return
+	}
+
 	var (
 		// for *bool fields
 		f    = false
@@ -587,6 +978,7 @@ func (p *jsonPrinter) printProbeSuccess(sourceAddr string, userInput userInput,
 			DestIsIP:              &t,
 			Success:               &t,
 			TotalSuccessfulProbes: streak,
+			Draining:              userInput.drainingPreviousIP,
 		}
 	)
 	if userInput.showSourceAddress {
@@ -612,10 +1004,12 @@ func (p *jsonPrinter) printProbeSuccess(sourceAddr string, userInput userInput,
 		}
 	}
 
+	setJSONProbeDetails(&data, details)
+
 	p.print(data)
 }
 
-func (p *jsonPrinter) printProbeFail(userInput userInput, streak uint) {
+func (p *jsonPrinter) printProbeFail(userInput userInput, streak uint, details probeDetails) {
 	var (
 		// for *bool fields
 		f    = false
@@ -628,6 +1022,7 @@ func (p *jsonPrinter) printProbeFail(userInput userInput, streak uint) {
 			DestIsIP:                &t,
 			Success:                 &f,
 			TotalUnsuccessfulProbes: streak,
+			Draining:                userInput.drainingPreviousIP,
 		}
 	)
 
@@ -640,9 +1035,51 @@ func (p *jsonPrinter) printProbeFail(userInput userInput, streak uint) {
 			userInput.ip.String(), userInput.port)
 	}
 
+	setJSONProbeDetails(&data, details)
+
 	p.print(data)
 }
 
+// setJSONProbeDetails fills the fields of the probe details
+// that apply to the current probing mode.
+func setJSONProbeDetails(data *JSONData, details probeDetails) {
+	data.FailureReason = string(details.failureReason)
+	data.DNSTime = details.dnsTime
+	data.TCPConnectTime = details.connectTime
+	data.FirstByteTime = details.firstByteTime
+
+	if tlsInfo := details.tls; tlsInfo != nil {
+		data.TLSHandshakeTime = tlsInfo.handshakeTime
+
+		if tlsInfo.err != nil {
+			data.TLSError = tlsInfo.err.Error()
+		} else {
+			data.TLSVersion = tlsInfo.version
+			data.TLSCipherSuite = tlsInfo.cipherSuite
+			data.TLSALPN = tlsInfo.alpn
+			data.CertSubject = tlsInfo.certSubject
+			if !tlsInfo.certExpiry.IsZero() {
+				data.CertExpiry = &tlsInfo.certExpiry
+			}
+		}
+	}
+
+	if race := details.race; race != nil {
+		data.HappyEyeballsWinner = addressFamily(race.addr)
+		data.HappyEyeballsAttempts = race.attempts
+	}
+
+	if httpInfo := details.http; httpInfo != nil {
+		data.HTTPStatusCode = httpInfo.statusCode
+		data.HTTPTTFB = httpInfo.ttfb
+		data.HTTPTotalTime = httpInfo.total
+
+		if httpInfo.err != nil {
+			data.HTTPError = httpInfo.err.Error()
+		}
+	}
+}
+
 // printStatistics prints all gathered stats when program exits.
 func (p *jsonPrinter) printStatistics(t tcping) {
 	data := JSONData{
@@ -650,6 +1087,7 @@ func (p *jsonPrinter) printStatistics(t tcping) {
 		Message:  fmt.Sprintf("stats for %s", t.userInput.hostname),
 		Addr:     t.userInput.ip.String(),
 		Hostname: t.userInput.hostname,
+		Port:     t.userInput.port,
 
 		StartTimestamp:          &t.startTime,
 		TotalDowntime:           t.totalDowntime.Seconds(),
@@ -663,6 +1101,14 @@ func (p *jsonPrinter) printStatistics(t tcping) {
 		data.HostnameChanges = t.hostnameChanges
 	}
 
+	if len(t.failureReasons) > 0 {
+		data.FailureReasons = t.failureReasons
+	}
+
+	if len(t.raceWins) > 0 {
+		data.HappyEyeballsWins = t.raceWins
+	}
+
 	loss := (float32(data.TotalUnsuccessfulProbes) / float32(data.TotalPackets)) * 100
 	if math.IsNaN(float64(loss)) {
 		loss = 0
@@ -688,6 +1134,46 @@ func (p *jsonPrinter) printStatistics(t tcping) {
 		data.LongestDowntimeEnd = &t.longestDowntime.end
 	}
 
+	if outages := t.allOutages(); len(outages) > 0 {
+		summary := summarizeOutages(outages, t.totalUptime)
+		data.MTTR = fmt.Sprintf("%.3f", summary.mttr.Seconds())
+		data.MTBF = fmt.Sprintf("%.3f", summary.mtbf.Seconds())
+
+		data.Outages = make([]JSONOutage, 0, len(outages))
+		for _, o := range outages {
+			data.Outages = append(data.Outages, JSONOutage{
+				Start:        o.start,
+				End:          o.end,
+				Duration:     fmt.Sprintf("%.3f", o.duration.Seconds()),
+				FailedProbes: o.failedProbes,
+				Addr:         o.ip.String(),
+				Ongoing:      o.ongoing,
+			})
+		}
+	}
+
+	for _, s := range t.addressSummaries {
+		address := JSONAddress{
+			Addr:                  s.addr.String(),
+			Family:                s.family,
+			Status:                s.status(),
+			TotalPackets:          s.totalProbes,
+			TotalSuccessfulProbes: s.successfulProbes,
+			TotalPacketLoss:       fmt.Sprintf("%.2f", s.packetLoss),
+			Outages:               s.outages,
+		}
+		if s.srv != nil {
+			address.SRV = &JSONSRVRecord{Target: s.srv.String(), Priority: s.srv.priority, Weight: s.srv.weight}
+		}
+		if s.rttResults.hasResults {
+			address.LatencyAvg = fmt.Sprintf("%.3f", s.rttResults.average)
+			address.LatencyP99 = fmt.Sprintf("%.3f", s.rttResults.p99)
+		}
+		data.Addresses = append(data.Addresses, address)
+	}
+
+	data.SRVChanges = t.srvChanges()
+
 	if !t.destIsIP {
 		data.HostnameResolveTries = t.retriedHostnameLookups
 	}
@@ -696,6 +1182,12 @@ func (p *jsonPrinter) printStatistics(t tcping) {
 		data.LatencyMin = fmt.Sprintf("%.3f", t.rttResults.min)
 		data.LatencyAvg = fmt.Sprintf("%.3f", t.rttResults.average)
 		data.LatencyMax = fmt.Sprintf("%.3f", t.rttResults.max)
+		data.LatencyP50 = fmt.Sprintf("%.3f", t.rttResults.p50)
+		data.LatencyP90 = fmt.Sprintf("%.3f", t.rttResults.p90)
+		data.LatencyP95 = fmt.Sprintf("%.3f", t.rttResults.p95)
+		data.LatencyP99 = fmt.Sprintf("%.3f", t.rttResults.p99)
+		data.LatencyStdDev = fmt.Sprintf("%.3f", t.rttResults.stdDev)
+		data.LatencyJitter = fmt.Sprintf("%.3f", t.rttResults.jitter)
 	}
 
 	if !t.endTime.IsZero() {
@@ -710,21 +1202,34 @@ func (p *jsonPrinter) printStatistics(t tcping) {
 
 // printTotalDownTime prints the total downtime,
 // if the next retry was successful.
-func (p *jsonPrinter) printTotalDownTime(downtime time.Duration) {
-	p.print(JSONData{
+func (p *jsonPrinter) printTotalDownTime(userInput userInput, downtime time.Duration) {
+	data := JSONData{
 		Type:          retrySuccessEvent,
-		Message:       fmt.Sprintf("no response received for %s", durationToString(downtime)),
+		Hostname:      userInput.hostname,
+		Addr:          userInput.ip.String(),
+		Port:          userInput.port,
 		TotalDowntime: downtime.Seconds(),
-	})
+	}
+
+	if userInput.hostname != "" {
+		data.Message = fmt.Sprintf("no response received from %s (%s) on port %d for %s",
+			userInput.hostname, userInput.ip.String(), userInput.port, durationToString(downtime))
+	} else {
+		data.Message = fmt.Sprintf("no response received from %s on port %d for %s",
+			userInput.ip.String(), userInput.port, durationToString(downtime))
+	}
+
+	p.print(data)
 }
 
 // printRetryingToResolve print the message retrying to resolve,
 // after n failed probes.
-func (p *jsonPrinter) printRetryingToResolve(hostname string) {
+func (p *jsonPrinter) printRetryingToResolve(hostname, resolver string) {
 	p.print(JSONData{
 		Type:     retryEvent,
-		Message:  fmt.Sprintf("retrying to resolve %s", hostname),
+		Message:  retryingToResolveMessage(hostname, resolver),
 		Hostname: hostname,
+		Resolver: resolver,
 	})
 }
 
@@ -749,6 +1254,109 @@ func (p *jsonPrinter) printVersion() {
 	})
 }
 
+// printComparison prints the statistics of both runs of a target.
+func (p *jsonPrinter) printComparison(c comparison) {
+	data := JSONData{
+		Type:         comparisonEvent,
+		Message:      c.shift.String(),
+		BeforeTarget: c.before,
+		AfterTarget:  c.after,
+		LatencyShift: &JSONLatencyShift{
+			Difference:  fmt.Sprintf("%.3f", c.shift.difference),
+			Significant: c.shift.significant(),
+		},
+	}
+
+	if c.shift.tested {
+		data.LatencyShift.T = fmt.Sprintf("%.3f", c.shift.t)
+		data.LatencyShift.DF = fmt.Sprintf("%.3f", c.shift.df)
+		data.LatencyShift.P = fmt.Sprintf("%.3f", c.shift.p)
+	}
+
+	units := map[metricKind]string{
+		metricPercent:      "%",
+		metricMilliseconds: "ms",
+		metricDuration:     "s",
+	}
+
+	for _, m := range c.metrics {
+		data.Comparison = append(data.Comparison, JSONComparedMetric{
+			Name:   m.name,
+			Before: fmt.Sprintf("%.3f", m.before),
+			After:  fmt.Sprintf("%.3f", m.after),
+			Change: fmt.Sprintf("%.3f", m.change()),
+			Unit:   units[m.kind],
+		})
+	}
+
+	p.print(data)
+}
+
+// probeDetailsToString creates a human-readable string of the probe details,
+// to be appended to the probe messages. It's empty if there are no details.
+func probeDetailsToString(details probeDetails) string {
+	var sb strings.Builder
+
+	if details.failureReason != "" {
+		fmt.Fprintf(&sb, " reason=%s", details.failureReason)
+	}
+
+	if details.dnsTime > 0 {
+		fmt.Fprintf(&sb, " dns=%.3f ms", details.dnsTime)
+	}
+
+	if details.connectTime > 0 {
+		fmt.Fprintf(&sb, " connect=%.3f ms", details.connectTime)
+	}
+
+	if details.firstByteTime > 0 {
+		fmt.Fprintf(&sb, " first_byte=%.3f ms", details.firstByteTime)
+	}
+
+	if tlsInfo := details.tls; tlsInfo != nil {
+		if tlsInfo.err != nil {
+			fmt.Fprintf(&sb, " tls_error=%q", tlsInfo.err)
+		} else {
+			fmt.Fprintf(&sb, " handshake=%.3f ms version=%s cipher=%s",
+				tlsInfo.handshakeTime, tlsInfo.version, tlsInfo.cipherSuite)
+
+			if tlsInfo.alpn != "" {
+				fmt.Fprintf(&sb, " alpn=%s", tlsInfo.alpn)
+			}
+
+			if tlsInfo.certSubject != "" {
+				fmt.Fprintf(&sb, " cert=%q expires=%s", tlsInfo.certSubject, tlsInfo.certExpiry.Format(timeFormat))
+			}
+		}
+	}
+
+	if race := details.race; race != nil {
+		fmt.Fprintf(&sb, " winner=%s attempts=%d", addressFamily(race.addr), race.attempts)
+	}
+
+	if httpInfo := details.http; httpInfo != nil {
+		if httpInfo.statusCode != 0 {
+			fmt.Fprintf(&sb, " ttfb=%.3f ms total=%.3f ms status=%d",
+				httpInfo.ttfb, httpInfo.total, httpInfo.statusCode)
+		}
+
+		if httpInfo.err != nil {
+			fmt.Fprintf(&sb, " http_error=%q", httpInfo.err)
+		}
+	}
+
+	return sb.String()
+}
+
+// retryingToResolveMessage creates the message of the retry event,
+// naming the DNS server unless it's the system resolver.
+func retryingToResolveMessage(hostname, resolver string) string {
+	if resolver == "" {
+		return fmt.Sprintf("retrying to resolve %s", hostname)
+	}
+	return fmt.Sprintf("retrying to resolve %s using %s", hostname, resolver)
+}
+
 // durationToString creates a human-readable string for a given duration
 func durationToString(duration time.Duration) string {
 	hours := math.Floor(duration.Hours())
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
 	"testing"
 	"time"
@@ -16,15 +17,15 @@ import (
 // of a printer that does nothing.
 type dummyPrinter struct{}
 
-func (fp *dummyPrinter) printStart(_ string, _ uint16)                              {}
-func (fp *dummyPrinter) printProbeSuccess(_ string, _ userInput, _ uint, _ float32) {}
-func (fp *dummyPrinter) printProbeFail(_ userInput, _ uint)                         {}
-func (fp *dummyPrinter) printRetryingToResolve(_ string)                            {}
-func (fp *dummyPrinter) printTotalDownTime(_ time.Duration)                         {}
-func (fp *dummyPrinter) printStatistics(_ tcping)                                   {}
-func (fp *dummyPrinter) printVersion()                                              {}
-func (fp *dummyPrinter) printInfo(_ string, _ ...interface{})                       {}
-func (fp *dummyPrinter) printError(_ string, _ ...interface{})                      {}
+func (fp *dummyPrinter) printStart(_ string, _ uint16)                                              {}
+func (fp *dummyPrinter) printProbeSuccess(_ string, _ userInput, _ uint, _ float32, _ probeDetails) {}
+func (fp *dummyPrinter) printProbeFail(_ userInput, _ uint, _ probeDetails)                         {}
+func (fp *dummyPrinter) printRetryingToResolve(_, _ string)                                         {}
+func (fp *dummyPrinter) printTotalDownTime(_ userInput, _ time.Duration)                            {}
+func (fp *dummyPrinter) printStatistics(_ tcping)                                                   {}
+func (fp *dummyPrinter) printVersion()                                                              {}
+func (fp *dummyPrinter) printInfo(_ string, _ ...interface{})                                       {}
+func (fp *dummyPrinter) printError(_ string, _ ...interface{})                                      {}
 
 func TestDurationToString(t *testing.T) {
 	t.Parallel()
@@ -183,7 +184,7 @@ func TestPrintProbeSuccess(t *testing.T) {
 				stats.userInput.showSourceAddress = true
 			}
 
-			pp.printProbeSuccess(sourceAddr, stats.userInput, streak, rtt)
+			pp.printProbeSuccess(sourceAddr, stats.userInput, streak, rtt, probeDetails{})
 
 			write.Close()
 
@@ -223,6 +224,28 @@ func TestPrintProbeSuccess(t *testing.T) {
 	}
 }
 
+func TestPrintProbeSuccessFailuresOnly(t *testing.T) {
+	stats := createTestStats(t)
+	stats.userInput.showFailuresOnly = true
+	showTimestamp := false
+	pp := newPlainPrinter(&showTimestamp)
+
+	stdout := os.Stdout
+	t.Cleanup(func() { os.Stdout = stdout })
+	read, write, _ := os.Pipe()
+	os.Stdout = write
+
+	pp.printProbeSuccess("127.0.0.1:4567", stats.userInput, 1, 1.5, probeDetails{})
+	write.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, read); err != nil {
+		t.Fatalf("Failed to read from pipe: %v", err)
+	}
+
+	assert.Empty(t, buf.String(), "the successful probes are hidden")
+}
+
 func TestPrintProbeFail(t *testing.T) {
 	stats := createTestStats(t)
 	stats.userInput.hostname = "example.com"
@@ -274,7 +297,7 @@ func TestPrintProbeFail(t *testing.T) {
 				stats.userInput.hostname = ""
 			}
 
-			pp.printProbeFail(stats.userInput, streak)
+			pp.printProbeFail(stats.userInput, streak, probeDetails{})
 
 			write.Close()
 
@@ -304,3 +327,75 @@ func TestPrintProbeFail(t *testing.T) {
 		})
 	}
 }
+
+func TestProbeDetailsToString(t *testing.T) {
+	expiry := time.Date(2030, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	assert.Equal(t, "", probeDetailsToString(probeDetails{}))
+	assert.Equal(t,
+		` connect=1.000 ms handshake=2.500 ms version=TLS 1.3 cipher=TLS_AES_128_GCM_SHA256 alpn=h2 cert="CN=example.com" expires=2030-01-02 03:04:05`,
+		probeDetailsToString(probeDetails{connectTime: 1, tls: &tlsDetails{
+			handshakeTime: 2.5,
+			version:       "TLS 1.3",
+			cipherSuite:   "TLS_AES_128_GCM_SHA256",
+			alpn:          "h2",
+			certSubject:   "CN=example.com",
+			certExpiry:    expiry,
+		}}),
+	)
+	assert.Equal(t,
+		` connect=1.000 ms ttfb=3.250 ms total=4.000 ms status=200`,
+		probeDetailsToString(probeDetails{connectTime: 1, http: &httpDetails{
+			ttfb:       3.25,
+			total:      4,
+			statusCode: 200,
+		}}),
+	)
+	assert.Equal(t,
+		` connect=1.000 ms ttfb=3.250 ms total=4.000 ms status=503 http_error="unexpected status code 503"`,
+		probeDetailsToString(probeDetails{connectTime: 1, http: &httpDetails{
+			ttfb:       3.25,
+			total:      4,
+			statusCode: 503,
+			err:        fmt.Errorf("unexpected status code 503"),
+		}}),
+	)
+	assert.Equal(t,
+		` reason=refused`,
+		probeDetailsToString(probeDetails{failureReason: failureRefused}),
+	)
+	assert.Equal(t,
+		` dns=0.500 ms connect=1.000 ms first_byte=12.000 ms`,
+		probeDetailsToString(probeDetails{dnsTime: 0.5, connectTime: 1, firstByteTime: 12}),
+	)
+	assert.Equal(t,
+		` winner=IPv6 attempts=1`,
+		probeDetailsToString(probeDetails{race: &raceDetails{addr: netip.MustParseAddr("2001:db8::1"), attempts: 1}}),
+	)
+}
+
+func TestPrintHostnameChanges(t *testing.T) {
+	first, second := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")
+	now := time.Now()
+
+	stats := createTestStats(t)
+	stats.userInput.hostname = "example.com"
+	stats.hostnameChanges = []hostnameChange{
+		{Addr: first, When: now, Records: []netip.Addr{first}},
+		{Addr: first, When: now.Add(time.Minute), PreviousRecords: []netip.Addr{first}, Records: []netip.Addr{first, second}},
+		{Addr: second, When: now.Add(2 * time.Minute), PreviousRecords: []netip.Addr{first, second}, Records: []netip.Addr{second}},
+	}
+
+	var buf bytes.Buffer
+	showTimestamp := false
+	pp := newPlainPrinter(&showTimestamp)
+	pp.out = &buf
+	pp.printStatistics(*stats)
+
+	assert.Contains(t, buf.String(), fmt.Sprintf("IP address changes:\n"+
+		"  still 192.0.2.1 at %s\n"+
+		"    records 192.0.2.1 -> 192.0.2.1, 192.0.2.2\n"+
+		"  from 192.0.2.1 to 192.0.2.2 at %s\n"+
+		"    records 192.0.2.1, 192.0.2.2 -> 192.0.2.2\n",
+		now.Add(time.Minute).Format(timeFormat), now.Add(2*time.Minute).Format(timeFormat)))
+}
@@ -4,15 +4,20 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"flag"
+	"fmt"
 	"math/rand"
 	"net"
 	"net/netip"
+	"net/url"
 	"os"
 	"os/signal"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -27,6 +32,9 @@ const (
 	dnsTimeout = 2 * time.Second
 )
 
+// systemDNSTimeout is the time to wait for the system resolver, set by --dns-timeout
+var systemDNSTimeout = dnsTimeout
+
 // printer is a set of methods for printers to implement.
 //
 // Printers should NOT modify any existing data nor do any calculations.
@@ -39,24 +47,29 @@ type printer interface {
 	// printProbeSuccess should print a message after each successful probe.
 	// hostname could be empty, meaning it's pinging an address.
 	// streak is the number of successful consecutive probes.
-	printProbeSuccess(sourceAddr string, userInput userInput, streak uint, rtt float32)
+	// details holds the optional details of the probe, e.g. the TLS handshake.
+	// It's called even with --show-failures-only, so that the probe can be saved,
+	// while the printers showing the probes should skip it.
+	printProbeSuccess(sourceAddr string, userInput userInput, streak uint, rtt float32, details probeDetails)
 
 	// printProbeFail should print a message after each failed probe.
 	// hostname could be empty, meaning it's pinging an address.
 	// streak is the number of successful consecutive probes.
-	printProbeFail(userInput userInput, streak uint)
+	// details holds the optional details of the probe, e.g. the TLS handshake.
+	printProbeFail(userInput userInput, streak uint, details probeDetails)
 
 	// printRetryingToResolve should print a message with the hostname
 	// it is trying to resolve an ip for.
+	// resolver is the DNS server asked, or empty for the system resolver.
 	//
 	// This is only being printed when the -r flag is applied.
-	printRetryingToResolve(hostname string)
+	printRetryingToResolve(hostname, resolver string)
 
 	// printTotalDownTime should print a downtime duration.
 	//
 	// This is being called when host was unavailable for some time
 	// but the latest probe was successful (became available).
-	printTotalDownTime(downtime time.Duration)
+	printTotalDownTime(userInput userInput, downtime time.Duration)
 
 	// printStatistics should print a message with
 	// helpful statistics information.
@@ -89,8 +102,22 @@ type tcping struct {
 	ticker                    *time.Ticker // ticker is used to handle time between probes.
 	longestUptime             longestTime
 	longestDowntime           longestTime
-	rtt                       []float32
+	rtt                       rttStats
 	hostnameChanges           []hostnameChange
+	outages                   []outage               // outages holds every finished downtime of the target
+	currentOutage             outage                 // currentOutage is the downtime in progress while destWasDown is set
+	failureReasons            map[failureReason]uint // failureReasons counts the failed probes by their reason
+	timeline                  *probeTimeline         // timeline is only kept for the charts of the HTML report
+	records                   []netip.Addr           // records is the last resolved record set of the hostname, sorted
+	nextResolve               time.Time              // nextResolve is when the hostname is re-resolved, if it's done periodically
+	draining                  map[netip.Addr]bool    // draining holds the previous IP addresses still being probed
+	addressGroup              []*tcping              // addressGroup holds the targets of every address of the hostname, with --all-addresses
+	addressSummaries          []addressSummary       // addressSummaries is only set on the last target of an addressGroup when printing the statistics
+	raceWins                  map[string]uint        // raceWins counts the Happy Eyeballs races won by each address family
+	srv                       *srvDiscovery          // srv is set on the targets discovered through SRV records, with --srv
+	srvRecord                 srvRecord              // srvRecord is the SRV record the target was discovered through
+	stop                      chan struct{}          // stop is closed once the SRV record of the target is removed
+	notifiers                 []notifier             // notifiers are told when the target goes down and comes back up
 	userInput                 userInput
 	ongoingSuccessfulProbes   uint
 	ongoingUnsuccessfulProbes uint
@@ -100,15 +127,23 @@ type tcping struct {
 	totalUnsuccessfulProbes   uint
 	retriedHostnameLookups    uint
 	rttResults                rttResult
-	destWasDown               bool // destWasDown is used to determine the duration of a downtime
-	destIsIP                  bool // destIsIP suppresses printing the IP information twice when hostname is not provided
+	dnsTime                   float32 // dnsTime is the duration of the last hostname lookup in ms, reported by the next probe in timings mode
+	destWasDown               bool    // destWasDown is used to determine the duration of a downtime
+	destIsIP                  bool    // destIsIP suppresses printing the IP information twice when hostname is not provided
+	notifiedDown              bool    // notifiedDown is set once the notifiers were told the target is down
 }
 
 type userInput struct {
 	ip                       netip.Addr
 	hostname                 string
 	networkInterface         networkInterface
-	retryHostnameLookupAfter uint // Retry resolving target's hostname after a certain number of failed requests
+	tlsConfig                *tls.Config      // tlsConfig is set when a TLS handshake should follow the TCP connection
+	resolver                 *dnsResolver     // resolver is set when the hostname is resolved through the DNS server given by the user
+	reResolve                *reResolveConfig // reResolve is set when the hostname is re-resolved periodically, not only after failures
+	httpConfig               *httpProbeConfig // httpConfig is set when an HTTP request should follow the connection
+	udpPayload               []byte           // udpPayload is sent in every probe when useUDP is set
+	retryHostnameLookupAfter uint             // Retry resolving target's hostname after a certain number of failed requests
+	alertAfter               uint             // alertAfter is the number of consecutive failed probes before notifying about a downtime
 	probesBeforeQuit         uint
 	timeout                  time.Duration
 	intervalBetweenProbes    time.Duration
@@ -116,8 +151,24 @@ type userInput struct {
 	useIPv4                  bool
 	useIPv6                  bool
 	shouldRetryResolve       bool
-	showFailuresOnly         bool
+	showFailuresOnly         bool // showFailuresOnly hides the successful probes, which are still saved with --db
 	showSourceAddress        bool
+	showTimings              bool // showTimings reports the duration of every phase of the probes
+	waitForFirstByte         bool // waitForFirstByte waits for the server to speak first after connecting
+	useUDP                   bool // useUDP probes the target with UDP datagrams instead of TCP connections
+	dualStack                bool // dualStack is set on the targets of the IPv4 and IPv6 addresses of a hostname
+	happyEyeballs            bool // happyEyeballs races the addresses of both families in every probe, see RFC 8305
+	drainingPreviousIP       bool // drainingPreviousIP is set on the probes of a previous IP address, which are not saved
+}
+
+// globalInput holds the user input that applies to the whole session,
+// rather than to the individual targets.
+type globalInput struct {
+	prometheusListen string          // prometheusListen is the address to serve the Prometheus metrics on
+	htmlReport       string          // htmlReport is the file to write the HTML report to at shutdown
+	useTUI           bool            // useTUI is set when the dashboard shows the statistics, instead of pressing Enter
+	thresholds       thresholds      // thresholds decide the exit code based on the final statistics
+	srvDiscoveries   []*srvDiscovery // srvDiscoveries keep the targets of the SRV records up to date
 }
 
 type genericUserInputArgs struct {
@@ -128,6 +179,20 @@ type genericUserInputArgs struct {
 	intName              *string
 	showFailuresOnly     *bool
 	showSourceAddress    *bool
+	useTLS               *bool
+	tlsInsecure          *bool
+	tlsServerName        *string
+	tlsALPN              *string
+	useHTTP              *bool
+	httpMethod           *string
+	httpPath             *string
+	httpHost             *string
+	httpStatus           *string
+	showTimings          *bool
+	waitForFirstByte     *bool
+	useUDP               *bool
+	udpPayload           *string
+	udpPayloadFile       *string
 	args                 []string
 }
 
@@ -147,22 +212,101 @@ type rttResult struct {
 	min        float32
 	max        float32
 	average    float32
+	p50        float32
+	p90        float32
+	p95        float32
+	p99        float32
+	stdDev     float32
+	jitter     float32 // jitter is the RFC 3550 smoothed variation between consecutive RTTs
 	hasResults bool
 }
 
 type hostnameChange struct {
-	Addr netip.Addr `json:"addr,omitempty"`
-	When time.Time  `json:"when,omitempty"`
+	Addr            netip.Addr   `json:"addr,omitzero"`
+	When            time.Time    `json:"when,omitempty"`
+	PreviousRecords []netip.Addr `json:"previous_records,omitempty"` // PreviousRecords is the record set before the change
+	Records         []netip.Addr `json:"records,omitempty"`          // Records is the record set the address was picked from
+	PreviousTargets []string     `json:"previous_targets,omitempty"` // PreviousTargets is the SRV target list before the change
+	Targets         []string     `json:"targets,omitempty"`          // Targets is the SRV target list, as host:port
+}
+
+// ipChanges returns the number of times the hostname resolved to a different IP address,
+// leaving out the changes of its records which kept the IP address.
+func (t *tcping) ipChanges() int {
+	changes := 0
+	for i := 1; i < len(t.hostnameChanges); i++ {
+		if t.hostnameChanges[i].Addr != t.hostnameChanges[i-1].Addr {
+			changes++
+		}
+	}
+	return changes
+}
+
+// changedTo returns the IP address the hostname changed to,
+// or the target list of the SRV records.
+func (c hostnameChange) changedTo() string {
+	if c.Addr.IsValid() {
+		return c.Addr.String()
+	}
+	return strings.Join(c.Targets, ", ")
+}
+
+// probeDetails holds the details of a probe, besides its RTT,
+// which depend on the probing mode.
+// Fields are nil when they don't apply to the current mode.
+type probeDetails struct {
+	// failureReason is the category of the error of a failed probe.
+	failureReason failureReason
+	// dnsTime is the time it took to resolve the hostname in ms,
+	// when it was resolved before the probe in timings mode.
+	dnsTime float32
+	// connectTime is the time it took to establish the TCP connection in ms,
+	// when the probe does more than connecting, e.g. a TLS handshake.
+	connectTime float32
+	// firstByteTime is the time from connecting to receiving
+	// the first byte sent by the server in ms.
+	firstByteTime float32
+	tls           *tlsDetails
+	http          *httpDetails
+	race          *raceDetails
+	// probeTime is when the probe started.
+	probeTime time.Time
+}
+
+// stateLock serializes the bookkeeping and the output of all targets.
+// Each target is probed from its own goroutine, while they all share
+// a single printer and are read when printing the statistics.
+var stateLock sync.Mutex
+
+// targetList holds the targets being probed. The targets discovered
+// through SRV records are added to it while probing, so it's read
+// and written while holding stateLock, once the probes have started.
+type targetList struct {
+	targets []*tcping
+	wg      sync.WaitGroup // wg waits for all targets to finish probing
+}
+
+// run probes the target in the background
+func (l *targetList) run(t *tcping) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		t.run()
+
+		if t.srv != nil {
+			t.srv.targetDone()
+		}
+	}()
 }
 
 // signalHandler catches SIGINT and SIGTERM then prints tcping stats
-func signalHandler(tcping *tcping) {
+func signalHandler(targets *targetList, global globalInput) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
 		<-sigChan
-		shutdown(tcping)
+		shutdown(targets, global)
 	}()
 }
 
@@ -184,33 +328,94 @@ func monitorSTDIN(stdinChan chan bool) {
 // This should be used instead, as it makes
 // all the necessary calculations beforehand.
 func (t *tcping) printStats() {
+	// the targets removed from the SRV records stopped at endTime
+	now := time.Now()
+	if t.isStopped() {
+		now = t.endTime
+	}
+
 	if t.destWasDown {
-		calcLongestDowntime(t, time.Since(t.startOfDowntime))
+		calcLongestDowntime(t, now.Sub(t.startOfDowntime))
 	} else {
-		calcLongestUptime(t, time.Since(t.startOfUptime))
+		calcLongestUptime(t, now.Sub(t.startOfUptime))
+	}
+	t.rttResults = t.rtt.result()
+
+	switch {
+	case t.isLastAddress():
+		t.addressSummaries = summarizeAddresses(t.addressGroup)
+	case t.isLastSRVTarget():
+		t.addressSummaries = summarizeAddresses(t.srv.members)
 	}
-	t.rttResults = calcMinAvgMaxRttTime(t.rtt)
 
 	t.printStatistics(*t)
 }
 
-// shutdown calculates endTime, prints statistics and calls os.Exit(0).
-// This should be used as the main exit-point.
-func shutdown(tcping *tcping) {
-	tcping.endTime = time.Now()
-	tcping.printStats()
+// printAllStats prints the statistics of every target.
+func printAllStats(list *targetList) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	for _, t := range list.targets {
+		t.printStats()
+	}
+}
+
+// shutdown calculates endTime, prints statistics, writes the HTML report
+// and calls os.Exit(0). This should be used as the main exit-point.
+func shutdown(list *targetList, global globalInput) {
+	// all targets share the same notifiers
+	stateLock.Lock()
+	notifiers := list.targets[0].notifiers
+	stateLock.Unlock()
+	flushNotifiers(notifiers)
+
+	// The lock is never released, so that no target
+	// prints anything after the final statistics.
+	stateLock.Lock()
+	targets := list.targets
+
+	var violations []thresholdViolation
+	for _, t := range targets {
+		// the targets removed from the SRV records stopped earlier
+		if t.endTime.IsZero() {
+			t.endTime = time.Now()
+		}
+		t.printStats()
+		violations = append(violations, global.thresholds.check(t)...)
+	}
+
+	for _, v := range violations {
+		targets[0].printError("Threshold exceeded: %s", v.message)
+	}
+
+	// all targets share the same printer
+	closePrinter(targets[0].printer)
 
+	if global.htmlReport != "" {
+		if err := writeHTMLReport(global.htmlReport, targets); err != nil {
+			targets[0].printError("Failed to write the HTML report: %s", err)
+		} else {
+			targets[0].printInfo("HTML report written to %s", global.htmlReport)
+		}
+	}
+
+	os.Exit(exitCode(violations))
+}
+
+// closePrinter closes the database or the files of the printer, if it has any
+func closePrinter(p printer) {
 	// if the printer type is `database`, close it before exiting
-	if db, ok := tcping.printer.(*database); ok {
-		db.conn.Close()
+	if db, ok := p.(*database); ok {
+		if err := db.close(); err != nil {
+			db.printError("\nError while closing the database %q\nerr: %s", db.dbPath, err)
+		}
 	}
 
 	// if the printer type is `csvPrinter`, call the cleanup function before exiting
-	if cp, ok := tcping.printer.(*csvPrinter); ok {
+	if cp, ok := p.(*csvPrinter); ok {
 		cp.cleanup()
 	}
-
-	os.Exit(0)
 }
 
 // usage prints how tcping should be run
@@ -223,6 +428,14 @@ func usage() {
 	colorRed("%s www.example.com 443\n", executableName)
 	colorRed("Or use the <hostname/ip:port> format:\n")
 	colorRed("%s www.example.com:443\n", executableName)
+	colorRed("Multiple targets can be probed at once in the <hostname/ip:port> format:\n")
+	colorRed("%s www.example.com:443 192.168.1.1:22\n", executableName)
+	colorRed("Or the targets of DNS SRV records with:\n")
+	colorRed("%s --srv _sip._tcp.example.com\n", executableName)
+	colorRed("The statistics of saved probes can be recomputed with:\n")
+	colorRed("%s report <filename>\n", executableName)
+	colorRed("Or compared between two runs with:\n")
+	colorRed("%s compare <before filename> <after filename>\n", executableName)
 	colorYellow("\n[optional flags]\n")
 
 	flag.VisitAll(func(f *flag.Flag) {
@@ -238,7 +451,7 @@ func usage() {
 }
 
 // setPrinter selects the printer
-func setPrinter(tcping *tcping, outputJSON, prettyJSON *bool, noColor *bool, timeStamp *bool, sourceAddress *bool, outputDb *string, outputCSV *string, args []string) {
+func setPrinter(tcping *tcping, outputJSON, prettyJSON *bool, noColor *bool, timeStamp *bool, sourceAddress *bool, useTLS, useHTTP, showTimings, waitForFirstByte *bool, outputDb *string, outputCSV *string, targets [][]string, srvName string) {
 	if *prettyJSON && !*outputJSON {
 		colorRed("--pretty has no effect without the -j flag.")
 		usage()
@@ -247,14 +460,29 @@ func setPrinter(tcping *tcping, outputJSON, prettyJSON *bool, noColor *bool, tim
 	if *outputJSON {
 		tcping.printer = newJSONPrinter(*prettyJSON)
 	} else if *outputDb != "" {
-		tcping.printer = newDB(*outputDb, args)
+		if len(targets) == 0 && srvName == "" {
+			usage()
+		}
+
+		// the session lists every target, and the SRV name their targets are discovered through
+		names := make([]string, 0, len(targets)+1)
+		for _, target := range targets {
+			names = append(names, net.JoinHostPort(target[0], target[1]))
+		}
+		if srvName != "" {
+			names = append(names, srvName)
+		}
+		tcping.printer = newDB(*outputDb, names)
 	} else if *outputCSV != "" {
-		var err error
-		tcping.printer, err = newCSVPrinter(*outputCSV, timeStamp, sourceAddress)
+		cp, err := newCSVPrinter(*outputCSV, timeStamp, sourceAddress)
 		if err != nil {
 			tcping.printError("Failed to create CSV file: %s", err)
 			os.Exit(1)
 		}
+		cp.showTLS = *useTLS
+		cp.showHTTP = *useHTTP
+		cp.showTimings = *showTimings || *waitForFirstByte
+		tcping.printer = cp
 	} else if *noColor {
 		tcping.printer = newPlainPrinter(timeStamp)
 	} else {
@@ -262,6 +490,14 @@ func setPrinter(tcping *tcping, outputJSON, prettyJSON *bool, noColor *bool, tim
 	}
 }
 
+// setTerminalPrinter selects the printer among the ones writing to the terminal,
+// for the output that doesn't fit in the database or CSV files.
+func setTerminalPrinter(tcping *tcping, outputJSON, prettyJSON, noColor *bool) {
+	disabled := false
+	noOutput := ""
+	setPrinter(tcping, outputJSON, prettyJSON, noColor, &disabled, &disabled, &disabled, &disabled, &disabled, &disabled, &noOutput, &noOutput, nil, "")
+}
+
 // showVersion displays the version and exits
 func showVersion(tcping *tcping) {
 	tcping.printVersion()
@@ -323,6 +559,72 @@ func parseHostPortArgs(args []string) []string {
 	return args
 }
 
+// parseTargets returns the [host, port] pairs of all targets given
+// on the command line and in the targets file, if there is one.
+//
+// Both "host port" and "host:port" formats are accepted for a single target,
+// while multiple targets must be given in the "host:port" format.
+func parseTargets(args []string, targetsFile string) ([][]string, error) {
+	var targets [][]string
+
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && !strings.Contains(args[1], ":"):
+		targets = append(targets, args)
+	default:
+		for _, arg := range args {
+			target := parseHostPortArgs([]string{arg})
+			if len(target) != 2 {
+				return nil, fmt.Errorf("invalid target %q, expected <hostname/ip:port>", arg)
+			}
+			targets = append(targets, target)
+		}
+	}
+
+	if targetsFile == "" {
+		return targets, nil
+	}
+
+	fileTargets, err := readTargetsFile(targetsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(targets, fileTargets...), nil
+}
+
+// readTargetsFile reads the targets from a file, one per line.
+// Empty lines and lines starting with '#' are ignored.
+func readTargetsFile(filename string) ([][]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open targets file: %w", err)
+	}
+	defer file.Close()
+
+	var targets [][]string
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		target := parseHostPortArgs(strings.Fields(line))
+		if len(target) != 2 {
+			return nil, fmt.Errorf("invalid target %q on line %d of %s", line, lineNumber, filename)
+		}
+		targets = append(targets, target)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read targets file: %w", err)
+	}
+
+	return targets, nil
+}
+
 // setGenericArgs assigns the generic flags after sanity checks
 func setGenericArgs(tcping *tcping, genericArgs genericUserInputArgs) {
 	if *genericArgs.retryResolve > 0 {
@@ -330,7 +632,9 @@ func setGenericArgs(tcping *tcping, genericArgs genericUserInputArgs) {
 	}
 
 	tcping.userInput.hostname = genericArgs.args[0]
-	tcping.userInput.ip = resolveHostname(tcping)
+	if !tcping.userInput.ip.IsValid() {
+		tcping.userInput.ip = resolveHostname(tcping)
+	}
 	tcping.startTime = time.Now()
 	tcping.userInput.probesBeforeQuit = *genericArgs.probesBeforeQuit
 	tcping.userInput.timeout = secondsToDuration(*genericArgs.timeout)
@@ -343,7 +647,7 @@ func setGenericArgs(tcping *tcping, genericArgs genericUserInputArgs) {
 
 	// this serves as a default starting value for tracking IP changes.
 	tcping.hostnameChanges = []hostnameChange{
-		{tcping.userInput.ip, time.Now()},
+		{Addr: tcping.userInput.ip, When: time.Now(), Records: tcping.records},
 	}
 
 	if tcping.userInput.hostname == tcping.userInput.ip.String() {
@@ -361,10 +665,84 @@ func setGenericArgs(tcping *tcping, genericArgs genericUserInputArgs) {
 	tcping.userInput.showFailuresOnly = *genericArgs.showFailuresOnly
 
 	tcping.userInput.showSourceAddress = *genericArgs.showSourceAddress
+
+	tcping.userInput.showTimings = *genericArgs.showTimings || *genericArgs.waitForFirstByte
+	if *genericArgs.waitForFirstByte {
+		if *genericArgs.useTLS || *genericArgs.useHTTP || *genericArgs.useUDP {
+			tcping.printError("'--first-byte' cannot be combined with '--tls', '--http' or '--udp'")
+			os.Exit(1)
+		}
+
+		tcping.userInput.waitForFirstByte = true
+	}
+
+	if *genericArgs.useUDP {
+		if *genericArgs.useTLS || *genericArgs.useHTTP {
+			tcping.printError("'--udp' cannot be combined with '--tls' or '--http'")
+			os.Exit(1)
+		}
+
+		payload, err := readUDPPayload(*genericArgs.udpPayload, *genericArgs.udpPayloadFile)
+		if err != nil {
+			tcping.printError("Invalid UDP payload: %s", err)
+			os.Exit(1)
+		}
+
+		tcping.userInput.useUDP = true
+		tcping.userInput.udpPayload = payload
+	}
+
+	if *genericArgs.useTLS {
+		serverName := tcping.userInput.hostname
+		if *genericArgs.tlsServerName != "" {
+			serverName = *genericArgs.tlsServerName
+		}
+		alpn := *genericArgs.tlsALPN
+		if *genericArgs.useHTTP {
+			// the HTTP probes speak HTTP/1.1 only
+			alpn = "http/1.1"
+		}
+
+		tcping.userInput.tlsConfig = newTLSConfig(serverName, *genericArgs.tlsInsecure, alpn)
+	}
+
+	if *genericArgs.useHTTP {
+		expectedStatus, err := parseStatusCodes(*genericArgs.httpStatus)
+		if err != nil {
+			tcping.printError("Invalid HTTP status codes: %s", err)
+			os.Exit(1)
+		}
+
+		if !strings.HasPrefix(*genericArgs.httpPath, "/") {
+			tcping.printError("HTTP path should start with '/'")
+			os.Exit(1)
+		}
+
+		host := tcping.userInput.hostname
+		defaultPort := uint16(80)
+		if *genericArgs.useTLS {
+			defaultPort = 443
+		}
+		if tcping.userInput.port != defaultPort {
+			host = net.JoinHostPort(host, strconv.Itoa(int(tcping.userInput.port)))
+		}
+		if *genericArgs.httpHost != "" {
+			host = *genericArgs.httpHost
+		}
+
+		tcping.userInput.httpConfig = &httpProbeConfig{
+			method:         strings.ToUpper(*genericArgs.httpMethod),
+			path:           *genericArgs.httpPath,
+			host:           host,
+			expectedStatus: expectedStatus,
+		}
+	}
 }
 
-// processUserInput gets and validate user input
-func processUserInput(tcping *tcping) {
+// processUserInput gets and validate user input.
+// It returns one tcping per target, all sharing the same printer,
+// and the input that applies to all of them.
+func processUserInput() ([]*tcping, globalInput) {
 	useIPv4 := flag.Bool("4", false, "only use IPv4.")
 	useIPv6 := flag.Bool("6", false, "only use IPv6.")
 	retryHostnameResolveAfter := flag.Uint("r", 0, "retry resolving target's hostname after <n> number of failed probes. e.g. -r 10 to retry after 10 failed probes.")
@@ -382,23 +760,80 @@ func processUserInput(tcping *tcping) {
 	interfaceName := flag.String("I", "", "interface name or address.")
 	showSourceAddress := flag.Bool("show-source-address", false, "Show source address and port used for probes.")
 	showFailuresOnly := flag.Bool("show-failures-only", false, "Show only the failed probes.")
+	targetsFile := flag.String("targets", "", "path to a file with targets to probe, one <hostname/ip:port> per line.")
+	useTLS := flag.Bool("tls", false, "complete a TLS handshake after connecting. Failed handshakes count as failed probes.")
+	tlsInsecure := flag.Bool("tls-insecure", false, "do not verify the server certificate. No effect without the '--tls' flag.")
+	tlsServerName := flag.String("tls-server-name", "", "server name to send via SNI and to verify the certificate against. Defaults to the hostname.")
+	tlsALPN := flag.String("tls-alpn", "h2,http/1.1", "comma-separated list of protocols to offer via ALPN.")
+	useHTTP := flag.Bool("http", false, "send an HTTP request after connecting. Combine with '--tls' for HTTPS.")
+	httpMethod := flag.String("http-method", "GET", "HTTP method of the request. No effect without the '--http' flag.")
+	httpPath := flag.String("http-path", "/", "HTTP path of the request. No effect without the '--http' flag.")
+	httpHost := flag.String("http-host", "", "Host header of the request. Defaults to the hostname.")
+	httpStatus := flag.String("http-status", "200-399", "expected HTTP status codes, e.g. 200,204 or 200-299. Other status codes count as failed probes.")
+	showTimings := flag.Bool("timings", false, "show the DNS resolution and TCP connect times of every probe.")
+	waitForFirstByte := flag.Bool("first-byte", false, "wait for the server to send the first byte after connecting, e.g. an SSH banner, and show the time to first byte. Implies '--timings'.")
+	useUDP := flag.Bool("udp", false, "probe with UDP datagrams. A reply within the timeout counts as a successful probe.")
+	udpPayload := flag.String("udp-payload", "", "hex encoded payload of the UDP probes, e.g. 0x0a0b. Empty by default.")
+	udpPayloadFile := flag.String("udp-payload-file", "", "path to a file with the payload of the UDP probes.")
+	maxLoss := flag.String("max-loss", "", "exit with code 3 if the packet loss exceeds the given percentage, e.g. 5%.")
+	maxAvgRTT := flag.String("max-avg-rtt", "", "exit with code 4 if the average RTT exceeds the given duration, e.g. 50ms.")
+	maxP99RTT := flag.String("max-p99-rtt", "", "exit with code 5 if the p99 RTT exceeds the given duration, e.g. 100ms.")
+	requireSuccess := flag.Bool("require-success", false, "exit with code 2 if a target had no successful probes.")
+	webhookURL := flag.String("webhook", "", "URL to POST a JSON payload to when a target goes down and comes back up.")
+	webhookRetries := flag.Uint("webhook-retries", 3, "number of retries of a failed webhook request, with an exponential backoff.")
+	hookCommand := flag.String("hook", "", "command to run when a target goes down, comes back up or resolves to a different IP address.")
+	hookTimeout := flag.Float64("hook-timeout", 10, "time to wait for the hook command to finish, in seconds.")
+	alertAfter := flag.Uint("alert-after", 1, "number of consecutive failed probes before notifying that a target is down.")
+	configFile := flag.String("config", "", "path to a YAML file with options and targets. Flags given on the command line take precedence.")
+	prometheusListen := flag.String("prometheus-listen", "", "serve Prometheus metrics on the given address, e.g. :9110.")
+	htmlReport := flag.String("html", "", "path and file name to write a self-contained HTML report to when tcping exits.")
+	useTUI := flag.Bool("tui", false, "redraw a full-screen dashboard of the targets instead of printing a line per probe.")
+	dnsServer := flag.String("dns-server", "", "DNS server to resolve the hostnames through, e.g. 1.1.1.1, tcp://1.1.1.1:53, tls://1.1.1.1 or https://1.1.1.1/dns-query.")
+	dnsTimeoutSeconds := flag.Float64("dns-timeout", dnsTimeout.Seconds(), "time to wait for the DNS server, or the system resolver, to answer, in seconds.")
+	reResolve := flag.String("re-resolve", "", "re-resolve the hostname when its DNS records expire with 'ttl', or every <n> seconds.")
+	probePreviousIP := flag.Bool("probe-previous-ip", false, "keep probing the previous IP address after re-resolving, until it stops answering. Requires '--re-resolve'.")
+	allAddresses := flag.Bool("all-addresses", false, "probe every resolved address of the hostname, instead of a random one, with statistics per address.")
+	dualStack := flag.Bool("dual-stack", false, "probe an IPv4 and an IPv6 address of the hostname, with statistics per address family.")
+	happyEyeballs := flag.Bool("happy-eyeballs", false, "race the IPv6 and IPv4 addresses of the hostname in every probe, as in RFC 8305, and count the races won by each family.")
+	srvName := flag.String("srv", "", "probe every target of the SRV records of the given name, e.g. _sip._tcp.example.com, discovering them again when the records change.")
 	showHelp := flag.Bool("h", false, "show help message.")
 
 	flag.CommandLine.Usage = usage
 
-	permuteArgs(os.Args[1:])
+	permuteArgs(flag.CommandLine, os.Args[1:])
 	flag.Parse()
 
+	// flags set on the command line take precedence over the config file
+	var cfg config
+	var configErr error
+	if *configFile != "" {
+		cfg, configErr = readConfigFile(*configFile, flag.CommandLine)
+		if configErr == nil {
+			configErr = cfg.apply(flag.CommandLine)
+		}
+	}
+
 	// validation for flag and args
 	args := flag.Args()
 
+	// host and port must be specified
+	// Support both "host port" and "host:port" formats
+	targets, targetsErr := parseTargets(args, *targetsFile)
+
+	// targets given on the command line replace the ones in the config file
+	if len(args) == 0 {
+		targets = append(cfg.targets, targets...)
+	}
+
 	// we need to set printers first, because they're used for
 	// error reporting and other output.
-	setPrinter(tcping, outputJSON, prettyJSON, noColor, showTimestamp, showSourceAddress, outputDB, saveToCSV, args)
+	// The same printer is shared among all targets.
+	base := &tcping{}
+	setPrinter(base, outputJSON, prettyJSON, noColor, showTimestamp, showSourceAddress, useTLS, useHTTP, showTimings, waitForFirstByte, outputDB, saveToCSV, targets, *srvName)
 
 	// Handle -v flag
 	if *showVer {
-		showVersion(tcping)
+		showVersion(base)
 	}
 
 	// Handle -h flag
@@ -408,36 +843,233 @@ func processUserInput(tcping *tcping) {
 
 	// Handle -u flag
 	if *checkUpdates {
-		checkForUpdates(tcping)
+		checkForUpdates(base)
 	}
 
-	// host and port must be specified
-	// Support both "host port" and "host:port" formats
-	args = parseHostPortArgs(args)
-	
-	if len(args) != 2 {
+	if configErr != nil {
+		base.printError("%s", configErr)
+		os.Exit(1)
+	}
+
+	if *alertAfter == 0 {
+		base.printError("'--alert-after' should be at least 1")
+		os.Exit(1)
+	}
+
+	// notifiers report their errors while the probes go on
+	reportError := newErrorReporter(base.printer)
+
+	var notifiers []notifier
+	if *webhookURL != "" {
+		if u, err := url.Parse(*webhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			base.printError("Invalid webhook URL: %s", *webhookURL)
+			os.Exit(1)
+		}
+
+		notifiers = append(notifiers, newWebhookNotifier(*webhookURL, *webhookRetries, time.Second, reportError))
+	}
+
+	if *hookCommand != "" {
+		if *hookTimeout <= 0 {
+			base.printError("'--hook-timeout' should be more than 0")
+			os.Exit(1)
+		}
+
+		notifiers = append(notifiers, newHookNotifier(*hookCommand, secondsToDuration(*hookTimeout), reportError))
+	}
+
+	th, err := newThresholds(*maxLoss, *maxAvgRTT, *maxP99RTT, *requireSuccess)
+	if err != nil {
+		base.printError("%s", err)
+		os.Exit(1)
+	}
+
+	if targetsErr != nil {
+		base.printError("%s", targetsErr)
+		os.Exit(1)
+	}
+
+	if len(targets) == 0 && *srvName == "" {
 		usage()
 	}
 
-	// Check whether both the ipv4 and ipv6 flags are attempted set if ony one, error otherwise.
-	setIPFlags(tcping, useIPv4, useIPv6)
+	var resolver *dnsResolver
+	lookupTimeout := secondsToDuration(*dnsTimeoutSeconds)
+	if *dnsServer != "" {
+		resolver, err = newDNSResolver(*dnsServer, lookupTimeout)
+		if err != nil {
+			base.printError("%s", err)
+			os.Exit(1)
+		}
+	} else if lookupTimeout <= 0 {
+		base.printError("the DNS timeout should be more than 0")
+		os.Exit(1)
+	}
+	systemDNSTimeout = lookupTimeout
+
+	reResolveConfig, err := parseReResolve(*reResolve, *probePreviousIP)
+	if err != nil {
+		base.printError("%s", err)
+		os.Exit(1)
+	}
+
+	// every address is probed as a target of its own, whose IP never changes
+	if *allAddresses && (*retryHostnameResolveAfter > 0 || reResolveConfig != nil) {
+		base.printError("'--all-addresses' can't be combined with the -r or --re-resolve flags")
+		os.Exit(1)
+	}
+
+	// both families are probed, through any interface
+	if *dualStack || *happyEyeballs {
+		switch {
+		case *dualStack && *happyEyeballs:
+			base.printError("'--dual-stack' can't be combined with '--happy-eyeballs'")
+			os.Exit(1)
+		case *useIPv4 || *useIPv6 || *allAddresses || *interfaceName != "":
+			base.printError("'--dual-stack' and '--happy-eyeballs' can't be combined with the -4, -6, -I or --all-addresses flags")
+			os.Exit(1)
+		case *happyEyeballs && *useUDP:
+			base.printError("'--happy-eyeballs' can't be combined with '--udp'")
+			os.Exit(1)
+		}
+	}
+
+	// the targets of the SRV records come and go, so they aren't expanded
+	if *srvName != "" && (*allAddresses || *dualStack) {
+		base.printError("'--srv' can't be combined with '--all-addresses' or '--dual-stack'")
+		os.Exit(1)
+	}
+
+	// the dashboard takes the place of the terminal printers
+	var dashboard *tuiPrinter
+	if *useTUI {
+		if *outputJSON || *outputDB != "" || *saveToCSV != "" {
+			base.printError("'--tui' can't be combined with the -j, --db or --csv flags")
+			os.Exit(1)
+		}
+
+		if isTerminal(os.Stdout) {
+			dashboard = newTUIPrinter(base.printer, !*noColor)
+			base.printer = dashboard
+		} else {
+			base.printInfo("The output is not a terminal, so '--tui' prints a line per probe instead")
+		}
+	}
+
+	// newTarget creates a target with the options of the user. The records are given
+	// when the hostname was already resolved, as for the targets of SRV records.
+	newTarget := func(args []string, records []netip.Addr) *tcping {
+		t := &tcping{printer: base.printer, notifiers: notifiers}
+		t.userInput.alertAfter = *alertAfter
+		t.userInput.resolver = resolver
+		t.userInput.reResolve = reResolveConfig
+
+		if *htmlReport != "" {
+			t.timeline = newProbeTimeline()
+		}
+
+		// Check whether both the ipv4 and ipv6 flags are attempted set if ony one, error otherwise.
+		setIPFlags(t, useIPv4, useIPv6)
+
+		// Check if the port is valid and set it.
+		setPort(t, args)
+
+		// set generic args
+		genericArgs := genericUserInputArgs{
+			retryResolve:         retryHostnameResolveAfter,
+			probesBeforeQuit:     probesBeforeQuit,
+			timeout:              timeout,
+			secondsBetweenProbes: secondsBetweenProbes,
+			intName:              interfaceName,
+			showFailuresOnly:     showFailuresOnly,
+			showSourceAddress:    showSourceAddress,
+			useTLS:               useTLS,
+			tlsInsecure:          tlsInsecure,
+			tlsServerName:        tlsServerName,
+			tlsALPN:              tlsALPN,
+			useHTTP:              useHTTP,
+			httpMethod:           httpMethod,
+			httpPath:             httpPath,
+			httpHost:             httpHost,
+			httpStatus:           httpStatus,
+			showTimings:          showTimings,
+			waitForFirstByte:     waitForFirstByte,
+			useUDP:               useUDP,
+			udpPayload:           udpPayload,
+			udpPayloadFile:       udpPayloadFile,
+			args:                 args,
+		}
+
+		if len(records) > 0 {
+			t.records = records
+			t.userInput.ip = records[rand.Intn(len(records))]
+		}
+
+		setGenericArgs(t, genericArgs)
+		t.userInput.happyEyeballs = *happyEyeballs
+
+		return t
+	}
+
+	probes := make([]*tcping, 0, len(targets))
+	for _, args := range targets {
+		t := newTarget(args, nil)
+
+		switch {
+		case *allAddresses:
+			probes = append(probes, expandAddresses(t)...)
+		case *dualStack:
+			group, err := expandFamilies(t)
+			if err != nil {
+				base.printError("%s", err)
+				os.Exit(1)
+			}
+			probes = append(probes, group...)
+		default:
+			probes = append(probes, t)
+		}
+	}
+
+	var srvDiscoveries []*srvDiscovery
+	if *srvName != "" {
+		d, err := newSRVDiscovery(*srvName, base.printer, resolver, reResolveConfig, userInput{useIPv4: *useIPv4, useIPv6: *useIPv6}, newTarget)
+		if err != nil {
+			base.printError("%s", err)
+			os.Exit(1)
+		}
 
-	// Check if the port is valid and set it.
-	setPort(tcping, args)
+		probes = append(probes, d.members...)
+		srvDiscoveries = append(srvDiscoveries, d)
+	}
 
-	// set generic args
-	genericArgs := genericUserInputArgs{
-		retryResolve:         retryHostnameResolveAfter,
-		probesBeforeQuit:     probesBeforeQuit,
-		timeout:              timeout,
-		secondsBetweenProbes: secondsBetweenProbes,
-		intName:              interfaceName,
-		showFailuresOnly:     showFailuresOnly,
-		showSourceAddress:    showSourceAddress,
-		args:                 args,
+	if dashboard != nil {
+		dashboard.setTargets(probes)
 	}
 
-	setGenericArgs(tcping, genericArgs)
+	global := globalInput{
+		prometheusListen: *prometheusListen,
+		htmlReport:       *htmlReport,
+		useTUI:           dashboard != nil,
+		thresholds:       th,
+		srvDiscoveries:   srvDiscoveries,
+	}
+
+	return probes, global
+}
+
+// flagTakesValue reports whether the flag expects a separate value,
+// i.e. it's defined and it's not a boolean flag.
+func flagTakesValue(flagSet *flag.FlagSet, name string) bool {
+	f := flagSet.Lookup(name)
+	if f == nil {
+		return false
+	}
+
+	if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && boolFlag.IsBoolFlag() {
+		return false
+	}
+
+	return true
 }
 
 /*
@@ -445,7 +1077,7 @@ permuteArgs permute args for flag parsing stops just before the first non-flag a
 
 see: https://pkg.go.dev/flag
 */
-func permuteArgs(args []string) {
+func permuteArgs(flagSet *flag.FlagSet, args []string) {
 	var flagArgs []string
 	var nonFlagArgs []string
 
@@ -458,20 +1090,8 @@ func permuteArgs(args []string) {
 			} else {
 				optionName = v[1:]
 			}
-			switch optionName {
-			case "c":
-				fallthrough
-			case "t":
-				fallthrough
-			case "db":
-				fallthrough
-			case "I":
-				fallthrough
-			case "i":
-				fallthrough
-			case "csv":
-				fallthrough
-			case "r":
+
+			if flagTakesValue(flagSet, optionName) {
 				/* out of index */
 				if len(args) <= i+1 {
 					usage()
@@ -483,7 +1103,7 @@ func permuteArgs(args []string) {
 				}
 				flagArgs = append(flagArgs, args[i:i+2]...)
 				i++
-			default:
+			} else {
 				flagArgs = append(flagArgs, args[i])
 			}
 		} else {
@@ -635,98 +1255,145 @@ func checkForUpdates(tcping *tcping) {
 	os.Exit(0)
 }
 
-// selectResolvedIP returns a single IPv4 or IPv6 address from the net.IP slice of resolved addresses
-func selectResolvedIP(tcping *tcping, ipAddrs []netip.Addr) netip.Addr {
-	var index int
+// filterResolvedIPs returns the resolved addresses of the IP version asked for,
+// sorted and without duplicates, so that record sets can be compared.
+func filterResolvedIPs(tcping *tcping, ipAddrs []netip.Addr) []netip.Addr {
 	var ipList []netip.Addr
-	var ip netip.Addr
 
-	switch {
-	case tcping.userInput.useIPv4:
-		for _, ip := range ipAddrs {
-			if ip.Is4() {
-				ipList = append(ipList, ip)
-			}
-			// static builds (CGO=0) return IPv4-mapped IPv6 address
-			if ip.Is4In6() {
-				ipList = append(ipList, ip.Unmap())
-			}
-		}
+	for _, ip := range ipAddrs {
+		// static builds (CGO=0) return IPv4-mapped IPv6 address
+		ip = ip.Unmap()
 
-		if len(ipList) == 0 {
-			tcping.printError("Failed to find IPv4 address for %s", tcping.userInput.hostname)
-			os.Exit(1)
+		switch {
+		case tcping.userInput.useIPv4 && !ip.Is4():
+			continue
+		case tcping.userInput.useIPv6 && !ip.Is6():
+			continue
 		}
 
-		if len(ipList) > 1 {
-			index = rand.Intn(len(ipList))
-		} else {
-			index = 0
-		}
+		ipList = append(ipList, ip)
+	}
 
-		ip, _ = netip.ParseAddr(ipList[index].Unmap().String())
+	slices.SortFunc(ipList, func(a, b netip.Addr) int { return a.Compare(b) })
 
-	case tcping.userInput.useIPv6:
-		for _, ip := range ipAddrs {
-			if ip.Is6() {
-				ipList = append(ipList, ip)
-			}
-		}
+	return slices.Compact(ipList)
+}
 
-		if len(ipList) == 0 {
-			tcping.printError("Failed to find IPv6 address for %s", tcping.userInput.hostname)
-			os.Exit(1)
-		}
+// selectResolvedIP returns a single IPv4 or IPv6 address from the net.IP slice of resolved addresses
+func selectResolvedIP(tcping *tcping, ipAddrs []netip.Addr) netip.Addr {
+	ipList := filterResolvedIPs(tcping, ipAddrs)
 
-		if len(ipList) > 1 {
-			index = rand.Intn(len(ipList))
-		} else {
-			index = 0
+	if len(ipList) == 0 {
+		switch {
+		case tcping.userInput.useIPv4:
+			tcping.printError("Failed to find IPv4 address for %s", tcping.userInput.hostname)
+		case tcping.userInput.useIPv6:
+			tcping.printError("Failed to find IPv6 address for %s", tcping.userInput.hostname)
+		default:
+			tcping.printError("Failed to find an IP address for %s", tcping.userInput.hostname)
 		}
+		os.Exit(1)
+	}
 
-		ip, _ = netip.ParseAddr(ipList[index].Unmap().String())
+	return ipList[rand.Intn(len(ipList))]
+}
 
-	default:
-		if len(ipAddrs) > 1 {
-			index = rand.Intn(len(ipAddrs))
-		} else {
-			index = 0
-		}
+// resolveHostname handles hostname resolution with the timeout set by --dns-timeout.
+func resolveHostname(tcping *tcping) netip.Addr {
+	ip, err := lookupIP(tcping)
 
-		ip, _ = netip.ParseAddr(ipAddrs[index].Unmap().String())
+	// Prevent tcping to exit if it has been running for a while
+	if err != nil && (tcping.totalSuccessfulProbes != 0 || tcping.totalUnsuccessfulProbes != 0) {
+		return tcping.userInput.ip
+	} else if err != nil {
+		tcping.printError("Failed to resolve %s: %s", tcping.userInput.hostname, err)
+		os.Exit(1)
 	}
 
 	return ip
 }
 
-// resolveHostname handles hostname resolution with a timeout value of a second
-func resolveHostname(tcping *tcping) netip.Addr {
+// lookupIP returns one of the IP addresses of the hostname and keeps all of them in the records.
+func lookupIP(tcping *tcping) (netip.Addr, error) {
 	ip, err := netip.ParseAddr(tcping.userInput.hostname)
 	if err == nil {
-		return ip
+		return ip, nil
+	}
+
+	ipAddrs, err := lookupHostname(tcping)
+	if err != nil {
+		return netip.Addr{}, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+	ip = selectResolvedIP(tcping, ipAddrs)
+	tcping.records = filterResolvedIPs(tcping, ipAddrs)
+
+	return ip, nil
+}
+
+// lookupHostname resolves the hostname through the resolver chosen by the user.
+// When the hostname is re-resolved periodically, it also schedules the next lookup.
+func lookupHostname(tcping *tcping) ([]netip.Addr, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout(tcping.userInput.resolver))
 	defer cancel()
 
-	ipAddrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", tcping.userInput.hostname)
+	var ipAddrs []netip.Addr
+	var ttl time.Duration
+	var err error
 
-	// Prevent tcping to exit if it has been running for a while
-	if err != nil && (tcping.totalSuccessfulProbes != 0 || tcping.totalUnsuccessfulProbes != 0) {
-		return tcping.userInput.ip
-	} else if err != nil {
-		tcping.printError("Failed to resolve %s: %s", tcping.userInput.hostname, err)
-		os.Exit(1)
+	lookupStart := time.Now()
+	switch {
+	case tcping.userInput.reResolve != nil:
+		ipAddrs, ttl, err = lookupTTL(ctx, tcping.userInput.resolver, tcping.userInput.hostname)
+	case tcping.userInput.resolver != nil:
+		ipAddrs, err = tcping.userInput.resolver.lookupNetIP(ctx, tcping.userInput.hostname)
+	default:
+		ipAddrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", tcping.userInput.hostname)
 	}
+	tcping.dnsTime = nanoToMillisecond(time.Since(lookupStart).Nanoseconds())
 
-	return selectResolvedIP(tcping, ipAddrs)
+	if tcping.userInput.reResolve != nil {
+		tcping.nextResolve = time.Now().Add(tcping.userInput.reResolve.after(ttl))
+	}
+
+	return ipAddrs, err
+}
+
+// resolverName returns the DNS server the hostname is resolved through,
+// or an empty string for the system resolver.
+func (t *tcping) resolverName() string {
+	if t.userInput.resolver == nil {
+		return ""
+	}
+	return t.userInput.resolver.name
 }
 
 // retryResolveHostname retries resolving a hostname after certain number of failures
 func retryResolveHostname(tcping *tcping) {
 	if tcping.ongoingUnsuccessfulProbes >= tcping.userInput.retryHostnameLookupAfter {
-		tcping.printRetryingToResolve(tcping.userInput.hostname)
-		tcping.userInput.ip = resolveHostname(tcping)
+		stateLock.Lock()
+		tcping.printRetryingToResolve(tcping.userInput.hostname, tcping.resolverName())
+		stateLock.Unlock()
+
+		// the lookup could take a while, so it is done without holding the lock
+		previousRecords := tcping.records
+		lookupStart := time.Now()
+		ip, err := lookupIP(tcping)
+		if err != nil {
+			// the failed lookup is counted as a failed probe, so that the failure reasons add up
+			tcping.handleConnError(lookupStart, time.Since(lookupStart), probeDetails{failureReason: failureDNS})
+		}
+
+		stateLock.Lock()
+		defer stateLock.Unlock()
+
+		// the current IP is kept when the lookup fails
+		if err != nil {
+			ip = tcping.userInput.ip
+		}
+
+		failedProbes := tcping.ongoingUnsuccessfulProbes
+		tcping.setIP(ip)
 		tcping.ongoingUnsuccessfulProbes = 0
 		tcping.retriedHostnameLookups++
 
@@ -737,10 +1404,14 @@ func retryResolveHostname(tcping *tcping) {
 
 		lastAddr := tcping.hostnameChanges[len(tcping.hostnameChanges)-1].Addr
 		if lastAddr != tcping.userInput.ip {
+			now := time.Now()
 			tcping.hostnameChanges = append(tcping.hostnameChanges, hostnameChange{
-				Addr: tcping.userInput.ip,
-				When: time.Now(),
+				Addr:            tcping.userInput.ip,
+				When:            now,
+				PreviousRecords: previousRecords,
+				Records:         tcping.records,
 			})
+			tcping.notifyIPChange(now, lastAddr.String(), failedProbes)
 		}
 	}
 }
@@ -754,37 +1425,6 @@ func newLongestTime(startTime time.Time, duration time.Duration) longestTime {
 	}
 }
 
-// calcMinAvgMaxRttTime calculates min, avg and max RTT values
-func calcMinAvgMaxRttTime(timeArr []float32) rttResult {
-	var sum float32
-	var result rttResult
-
-	arrLen := len(timeArr)
-	// rttResults.min = ^uint(0.0)
-	if arrLen > 0 {
-		result.min = timeArr[0]
-	}
-
-	for i := 0; i < arrLen; i++ {
-		sum += timeArr[i]
-
-		if timeArr[i] > result.max {
-			result.max = timeArr[i]
-		}
-
-		if timeArr[i] < result.min {
-			result.min = timeArr[i]
-		}
-	}
-
-	if arrLen > 0 {
-		result.hasResults = true
-		result.average = sum / float32(arrLen)
-	}
-
-	return result
-}
-
 // calcLongestUptime calculates the longest uptime and sets it to tcpStats.
 func calcLongestUptime(tcping *tcping, duration time.Duration) {
 	if tcping.startOfUptime.IsZero() || duration == 0 {
@@ -845,33 +1485,58 @@ func maxDuration(x, y time.Duration) time.Duration {
 }
 
 // handleConnError processes failed probes
-func (t *tcping) handleConnError(connTime time.Time, elapsed time.Duration) {
+func (t *tcping) handleConnError(connTime time.Time, elapsed time.Duration, details probeDetails) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
 	if !t.destWasDown {
 		t.startOfDowntime = connTime
 		uptime := t.startOfDowntime.Sub(t.startOfUptime)
 		calcLongestUptime(t, uptime)
 		t.startOfUptime = time.Time{}
 		t.destWasDown = true
+		t.startOutage(connTime)
 	}
 
 	t.totalDowntime += elapsed
 	t.lastUnsuccessfulProbe = connTime
 	t.totalUnsuccessfulProbes++
 	t.ongoingUnsuccessfulProbes++
+	t.currentOutage.failedProbes++
+
+	if t.timeline != nil {
+		t.timeline.add(connTime, false, 0)
+	}
 
+	if details.failureReason != "" {
+		if t.failureReasons == nil {
+			t.failureReasons = make(map[failureReason]uint)
+		}
+		t.failureReasons[details.failureReason]++
+	}
+
+	t.notifyDown(connTime)
+
+	details.probeTime = connTime
 	t.printProbeFail(
 		t.userInput,
 		t.ongoingUnsuccessfulProbes,
+		details,
 	)
 }
 
 // handleConnSuccess processes successful probes
-func (t *tcping) handleConnSuccess(sourceAddr string, rtt float32, connTime time.Time, elapsed time.Duration) {
+func (t *tcping) handleConnSuccess(sourceAddr string, rtt float32, connTime time.Time, elapsed time.Duration, details probeDetails) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
 	if t.destWasDown {
 		t.startOfUptime = connTime
 		downtime := t.startOfUptime.Sub(t.startOfDowntime)
 		calcLongestDowntime(t, downtime)
-		t.printTotalDownTime(downtime)
+		t.endOutage(connTime)
+		t.printTotalDownTime(t.userInput, downtime)
+		t.notifyUp(connTime, t.ongoingUnsuccessfulProbes, downtime)
 		t.startOfDowntime = time.Time{}
 		t.destWasDown = false
 		t.ongoingUnsuccessfulProbes = 0
@@ -886,16 +1551,30 @@ func (t *tcping) handleConnSuccess(sourceAddr string, rtt float32, connTime time
 	t.lastSuccessfulProbe = connTime
 	t.totalSuccessfulProbes++
 	t.ongoingSuccessfulProbes++
-	t.rtt = append(t.rtt, rtt)
+	t.rtt.add(rtt)
 
-	if !t.userInput.showFailuresOnly {
-		t.printProbeSuccess(
-			sourceAddr,
-			t.userInput,
-			t.ongoingSuccessfulProbes,
-			rtt,
-		)
+	if t.timeline != nil {
+		t.timeline.add(connTime, true, rtt)
 	}
+
+	// the probe is printed with the address that won the race
+	userInput := t.userInput
+	if details.race != nil {
+		if t.raceWins == nil {
+			t.raceWins = make(map[string]uint)
+		}
+		t.raceWins[addressFamily(details.race.addr)]++
+		userInput.ip = details.race.addr
+	}
+
+	details.probeTime = connTime
+	t.printProbeSuccess(
+		sourceAddr,
+		userInput,
+		t.ongoingSuccessfulProbes,
+		rtt,
+		details,
+	)
 }
 
 // tcpProbe pings a host, TCP style
@@ -904,62 +1583,178 @@ func tcpProbe(tcping *tcping) {
 	var conn net.Conn
 	connStart := time.Now()
 
-	if tcping.userInput.networkInterface.use {
+	var details probeDetails
+
+	switch {
+	case tcping.userInput.networkInterface.use:
 		// dialer already contains the timeout value
 		conn, err = tcping.userInput.networkInterface.dialer.Dial("tcp", tcping.userInput.networkInterface.remoteAddr.String())
-	} else {
+	case tcping.userInput.happyEyeballs:
+		addrs := tcping.records
+		if len(addrs) == 0 {
+			addrs = []netip.Addr{tcping.userInput.ip}
+		}
+
+		var race raceDetails
+		conn, race, err = happyEyeballsDial(addrs, tcping.userInput.port, tcping.userInput.timeout)
+		if err == nil {
+			details.race = &race
+		}
+	default:
 		ipAndPort := netip.AddrPortFrom(tcping.userInput.ip, tcping.userInput.port)
 		conn, err = net.DialTimeout("tcp", ipAndPort.String(), tcping.userInput.timeout)
 	}
 
 	connDuration := time.Since(connStart)
+
+	if tcping.userInput.showTimings {
+		details.dnsTime = tcping.dnsTime
+		tcping.dnsTime = 0
+	}
+
+	extendedProbe := tcping.userInput.tlsConfig != nil || tcping.userInput.httpConfig != nil
+	if err == nil && (extendedProbe || tcping.userInput.showTimings) {
+		details.connectTime = nanoToMillisecond(connDuration.Nanoseconds())
+
+		if tcping.userInput.tlsConfig != nil {
+			var tlsInfo tlsDetails
+			conn, tlsInfo, err = tlsHandshake(conn, tcping.userInput.tlsConfig, tcping.userInput.timeout)
+			details.tls = &tlsInfo
+		}
+
+		if err == nil && tcping.userInput.httpConfig != nil {
+			var httpInfo httpDetails
+			httpInfo, err = httpRequest(conn, tcping.userInput.httpConfig, tcping.userInput.timeout)
+			details.http = &httpInfo
+		}
+
+		if extendedProbe {
+			// everything done after connecting is a part of the probe
+			connDuration = time.Since(connStart)
+		}
+
+		if err != nil {
+			conn.Close()
+		}
+	}
+
+	// waiting for the server is not a part of the RTT
+	if err == nil && tcping.userInput.waitForFirstByte {
+		details.firstByteTime = waitForFirstByte(conn, tcping.userInput.timeout)
+	}
+
 	rtt := nanoToMillisecond(connDuration.Nanoseconds())
 
 	elapsed := maxDuration(connDuration, tcping.userInput.intervalBetweenProbes)
 
 	if err != nil {
-		tcping.handleConnError(connStart, elapsed)
+		details.failureReason = classifyProbeFailure(err, details)
+		tcping.handleConnError(connStart, elapsed, details)
 	} else {
-		tcping.handleConnSuccess(conn.LocalAddr().String(), rtt, connStart, elapsed)
+		tcping.handleConnSuccess(conn.LocalAddr().String(), rtt, connStart, elapsed, details)
 		conn.Close()
 	}
 	<-tcping.ticker.C
 }
 
+// run probes the target until probesBeforeQuit is reached,
+// or indefinitely if no limit was given.
+func (t *tcping) run() {
+	t.ticker = time.NewTicker(t.userInput.intervalBetweenProbes)
+	defer t.ticker.Stop()
+
+	var probeCount uint
+	for {
+		// the target was removed from the SRV records
+		if t.isStopped() {
+			return
+		}
+
+		if t.userInput.shouldRetryResolve {
+			retryResolveHostname(t)
+		}
+
+		if t.userInput.reResolve != nil && !t.destIsIP && !time.Now().Before(t.nextResolve) {
+			reResolveHostname(t)
+		}
+
+		if t.userInput.useUDP {
+			udpProbe(t)
+		} else {
+			tcpProbe(t)
+		}
+
+		if t.userInput.probesBeforeQuit != 0 {
+			probeCount++
+			if probeCount == t.userInput.probesBeforeQuit {
+				return
+			}
+		}
+	}
+}
+
 func main() {
-	tcping := &tcping{}
-	processUserInput(tcping)
-	tcping.ticker = time.NewTicker(tcping.userInput.intervalBetweenProbes)
-	defer tcping.ticker.Stop()
+	// subcommands are given as the first argument
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "report":
+			runReport(os.Args[2:])
+			return
+		case "compare":
+			runCompare(os.Args[2:])
+			return
+		}
+	}
 
-	signalHandler(tcping)
+	targets, global := processUserInput()
+	list := &targetList{targets: targets}
 
-	tcping.printStart(tcping.userInput.hostname, tcping.userInput.port)
+	signalHandler(list, global)
 
-	stdinchan := make(chan bool)
-	go monitorSTDIN(stdinchan)
+	if global.prometheusListen != "" {
+		addr, err := startMetricsServer(global.prometheusListen, list)
+		if err != nil {
+			targets[0].printError("Failed to serve Prometheus metrics: %s", err)
+			os.Exit(1)
+		}
+		targets[0].printInfo("Serving Prometheus metrics on http://%s%s", addr, metricsPath)
+	}
 
-	var probeCount uint
-	for {
-		if tcping.userInput.shouldRetryResolve {
-			retryResolveHostname(tcping)
+	for _, t := range targets {
+		if t.isFirstAddress() {
+			t.printStart(t.userInput.hostname, t.userInput.port)
 		}
+	}
+
+	// the dashboard shows the statistics all along
+	stdinchan := make(chan bool)
+	if !global.useTUI {
+		go monitorSTDIN(stdinchan)
+	}
+
+	for _, t := range targets {
+		list.run(t)
+	}
+
+	for _, d := range global.srvDiscoveries {
+		list.wg.Add(1)
+		go d.run(list)
+	}
 
-		tcpProbe(tcping)
+	done := make(chan struct{})
+	go func() {
+		list.wg.Wait()
+		close(done)
+	}()
 
+	for {
 		select {
 		case pressedEnter := <-stdinchan:
 			if pressedEnter {
-				tcping.printStats()
-			}
-		default:
-		}
-
-		if tcping.userInput.probesBeforeQuit != 0 {
-			probeCount++
-			if probeCount == tcping.userInput.probesBeforeQuit {
-				shutdown(tcping)
+				printAllStats(list)
 			}
+		case <-done:
+			shutdown(list, global)
 		}
 	}
 }
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
 	"net"
 	"net/netip"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -118,6 +121,9 @@ func TestProbeFail(t *testing.T) {
 	assert.Equal(t, stats.ongoingUnsuccessfulProbes, uint(expectedFailed))
 
 	assert.Equal(t, stats.totalDowntime, 100*time.Second)
+
+	// nothing listens on the port
+	assert.Equal(t, map[failureReason]uint{failureRefused: uint(expectedFailed)}, stats.failureReasons)
 }
 
 func TestProbeFailInterval(t *testing.T) {
@@ -226,14 +232,29 @@ func TestPermuteArgs(t *testing.T) {
 			args{args: []string{"-u"}},
 			[]string{"-u"},
 		},
+		{
+			"bool option before host/ip",
+			args{args: []string{"-j", "127.0.0.1", "8080", "--r", "3"}},
+			[]string{"-j", "--r", "3", "127.0.0.1", "8080"},
+		},
+		{
+			"option with an inline value",
+			args{args: []string{"127.0.0.1", "8080", "-r=3"}},
+			[]string{"-r=3", "127.0.0.1", "8080"},
+		},
 		/**
 		 * cases in which the value of the option does not exist are not listed.
 		 * they call directly usage() and exit with code 1.
 		 */
 	}
+	flagSet := flag.NewFlagSet("tcping", flag.ContinueOnError)
+	flagSet.Uint("r", 0, "")
+	flagSet.Bool("u", false, "")
+	flagSet.Bool("j", false, "")
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			permuteArgs(tt.args.args)
+			permuteArgs(flagSet, tt.args.args)
 			assert.Equal(t, tt.want, tt.args.args)
 		})
 	}
@@ -342,3 +363,73 @@ func TestSecondsToDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name: "traditional format: host port",
+			args: []string{"example.com", "8080"},
+			want: [][]string{{"example.com", "8080"}},
+		},
+		{
+			name: "single host:port",
+			args: []string{"example.com:8080"},
+			want: [][]string{{"example.com", "8080"}},
+		},
+		{
+			name: "multiple targets",
+			args: []string{"example.com:443", "192.168.1.1:22", "[2001:db8::1]:80"},
+			want: [][]string{{"example.com", "443"}, {"192.168.1.1", "22"}, {"2001:db8::1", "80"}},
+		},
+		{
+			name:    "multiple targets, one without port",
+			args:    []string{"example.com:443", "192.168.1.1:22", "example.org"},
+			wantErr: true,
+		},
+		{
+			name: "no targets",
+			args: []string{},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTargets(tt.args, "")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadTargetsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "targets.txt")
+	content := "# service mesh\nexample.com:443\n\n10.0.0.1 22\n  [::1]:8080  \n"
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatalf("write targets file: %v", err)
+	}
+
+	got, err := parseTargets([]string{"example.org:80"}, filename)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"example.org", "80"},
+		{"example.com", "443"},
+		{"10.0.0.1", "22"},
+		{"::1", "8080"},
+	}, got)
+
+	if err := os.WriteFile(filename, []byte("example.com\n"), 0o600); err != nil {
+		t.Fatalf("write targets file: %v", err)
+	}
+
+	_, err = parseTargets(nil, filename)
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+-- A database with the sessions, events and probes tables,
+-- created before the schema version was recorded, with:
+--   tcping -c 2 --db sessions.db 127.0.0.1:18080 localhost:18097
+CREATE TABLE events (
+    id INTEGER PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES sessions(id),
+    event_type TEXT NOT NULL, -- for the data type eg. statistics, hostname change
+    timestamp DATETIME,
+    addr TEXT,
+    sourceAddr TEXT,
+    hostname TEXT,
+    port INTEGER,
+    hostname_resolve_retries INTEGER,
+
+    hostname_changed_to TEXT,
+    hostname_change_time DATETIME,
+
+    latency_min REAL,
+    latency_avg REAL,
+    latency_max REAL,
+    latency_p50 REAL,
+    latency_p90 REAL,
+    latency_p95 REAL,
+    latency_p99 REAL,
+    latency_stddev REAL,
+    latency_jitter REAL,
+
+	total_duration TEXT,
+    start_time DATETIME,
+    end_time DATETIME,
+
+	never_succeed_probe INTEGER, -- value will be 1 if a probe never succeeded
+	never_failed_probe INTEGER, -- value will be 1 if a probe never failed
+    last_successful_probe DATETIME,
+    last_unsuccessful_probe DATETIME,
+
+    longest_uptime TEXT,
+    longest_uptime_start DATETIME,
+    longest_uptime_end DATETIME,
+
+    longest_downtime TEXT,
+    longest_downtime_start DATETIME,
+    longest_downtime_end DATETIME,
+
+    outages INTEGER,
+    mttr TEXT,
+    mtbf TEXT,
+
+    outage_start DATETIME,
+    outage_end DATETIME,
+    outage_duration TEXT,
+    outage_failed_probes INTEGER,
+    outage_ongoing INTEGER, -- value will be 1 if the target was still down when tcping ended
+
+    total_packets INTEGER,
+    total_packet_loss REAL,
+    total_successful_probes INTEGER,
+    total_unsuccessful_probes INTEGER,
+    failure_reasons TEXT, -- e.g. "timeout: 3, refused: 1"
+
+    total_uptime TEXT,
+    total_downtime TEXT
+);
+INSERT INTO "events" VALUES(1,1,'statistics','2026-10-16 11:59:53','127.0.0.1','source address','127.0.0.1',18080,0,NULL,NULL,0.894,11.564,22.234,0.896,0.896,0.896,0.896,10.67,1.334,'421.037625ms','2026-10-16 11:59:53','2026-10-16 11:59:53',0,1,'2026-10-16 11:59:53','','403.281014ms','2026-10-16 11:59:53','2026-10-16 11:59:53','0s','','',0,'0s','0s',NULL,NULL,NULL,NULL,NULL,2,0.0,2,0,'','400ms','0s');
+INSERT INTO "events" VALUES(2,1,'hostname change',NULL,NULL,NULL,'127.0.0.1',18080,NULL,'127.0.0.1','2026-10-16 11:59:53',NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL);
+INSERT INTO "events" VALUES(3,1,'statistics','2026-10-16 11:59:53','127.0.0.1','source address','localhost',18097,0,NULL,NULL,0.0,0.0,0.0,0.0,0.0,0.0,0.0,0.0,0.0,'423.016756ms','2026-10-16 11:59:53','2026-10-16 11:59:53',1,0,'','2026-10-16 11:59:53','0s','','','404.838517ms','2026-10-16 11:59:53','2026-10-16 11:59:53',1,'404.838141ms','0s',NULL,NULL,NULL,NULL,NULL,2,100.0,0,2,'refused: 2','0s','400ms');
+INSERT INTO "events" VALUES(4,1,'hostname change',NULL,NULL,NULL,'localhost',18097,NULL,'127.0.0.1','2026-10-16 11:59:53',NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL);
+INSERT INTO "events" VALUES(5,1,'outage',NULL,'127.0.0.1',NULL,'localhost',18097,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,'2026-10-16 11:59:53','2026-10-16 11:59:53','404.838141ms',2,1,NULL,NULL,NULL,NULL,NULL,NULL,NULL);
+CREATE TABLE probes (
+    id INTEGER PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES sessions(id),
+    timestamp DATETIME,
+    hostname TEXT,
+    addr TEXT, -- the resolved IP address of the target
+    sourceAddr TEXT,
+    port INTEGER,
+    success INTEGER, -- value will be 1 if the probe succeeded
+    rtt REAL, -- in milliseconds, NULL for failed probes
+    failure_reason TEXT
+);
+INSERT INTO "probes" VALUES(1,1,'2026-10-16 11:59:53','localhost','127.0.0.1','',18097,0,NULL,'refused');
+INSERT INTO "probes" VALUES(2,1,'2026-10-16 11:59:53','127.0.0.1','127.0.0.1','127.0.0.1:36672',18080,1,0.894,NULL);
+INSERT INTO "probes" VALUES(3,1,'2026-10-16 11:59:53','127.0.0.1','127.0.0.1','127.0.0.1:36686',18080,1,22.234,NULL);
+INSERT INTO "probes" VALUES(4,1,'2026-10-16 11:59:53','localhost','127.0.0.1','',18097,0,NULL,'refused');
+CREATE TABLE sessions (
+    id INTEGER PRIMARY KEY,
+    targets TEXT, -- the probed targets, e.g. "example.com:443 192.168.1.1:22"
+    start_time DATETIME,
+    end_time DATETIME
+);
+INSERT INTO "sessions" VALUES(1,'127.0.0.1:18080 localhost:18097','2026-10-16 11:59:53','2026-10-16 11:59:53');
+CREATE INDEX probes_session_timestamp ON probes (session_id, timestamp);
+CREATE INDEX events_session ON events (session_id);
@@ -0,0 +1,94 @@
+-- A database of tcping v2.7.1 and earlier, with a table per run, created with:
+--   tcping -c 3 --db legacy.db 127.0.0.1 18080
+--   tcping -c 2 --db legacy.db localhost 18097
+-- along with a table unrelated to tcping.
+CREATE TABLE _127_0_0_1_18080_12_00_46_10_16_2026 (
+    id INTEGER PRIMARY KEY,
+    event_type TEXT NOT NULL, -- for the data type eg. statistics, hostname change
+    timestamp DATETIME,
+    addr TEXT,
+    sourceAddr TEXT,
+    hostname TEXT,
+    port INTEGER,
+    hostname_resolve_retries INTEGER,
+
+    hostname_changed_to TEXT,
+    hostname_change_time DATETIME,
+
+    latency_min REAL,
+    latency_avg REAL,
+    latency_max REAL,
+
+	total_duration TEXT,
+    start_time DATETIME,
+    end_time DATETIME,
+
+	never_succeed_probe INTEGER, -- value will be 1 if a probe never succeeded
+	never_failed_probe INTEGER, -- value will be 1 if a probe never failed
+    last_successful_probe DATETIME,
+    last_unsuccessful_probe DATETIME,
+
+    longest_uptime TEXT,
+    longest_uptime_start DATETIME,
+    longest_uptime_end DATETIME,
+
+    longest_downtime TEXT,
+    longest_downtime_start DATETIME,
+    longest_downtime_end DATETIME,
+
+    total_packets INTEGER,
+    total_packet_loss REAL,
+    total_successful_probes INTEGER,
+    total_unsuccessful_probes INTEGER,
+
+    total_uptime TEXT,
+    total_downtime TEXT
+);
+INSERT INTO "_127_0_0_1_18080_12_00_46_10_16_2026" VALUES(1,'statistics','2026-10-16 12:00:46','127.0.0.1','source address','127.0.0.1',18080,0,NULL,NULL,0.607,0.664,0.702,'600.963104ms','2026-10-16 12:00:46','2026-10-16 12:00:46',0,1,'2026-10-16 12:00:46','','600.817458ms','2026-10-16 12:00:46','2026-10-16 12:00:46','0s','','',3,0.0,3,0,'600ms','0s');
+INSERT INTO "_127_0_0_1_18080_12_00_46_10_16_2026" VALUES(2,'hostname change',NULL,NULL,NULL,NULL,NULL,NULL,'127.0.0.1','2026-10-16 12:00:46',NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL);
+CREATE TABLE localhost_18097_12_00_47_10_16_2026 (
+    id INTEGER PRIMARY KEY,
+    event_type TEXT NOT NULL, -- for the data type eg. statistics, hostname change
+    timestamp DATETIME,
+    addr TEXT,
+    sourceAddr TEXT,
+    hostname TEXT,
+    port INTEGER,
+    hostname_resolve_retries INTEGER,
+
+    hostname_changed_to TEXT,
+    hostname_change_time DATETIME,
+
+    latency_min REAL,
+    latency_avg REAL,
+    latency_max REAL,
+
+	total_duration TEXT,
+    start_time DATETIME,
+    end_time DATETIME,
+
+	never_succeed_probe INTEGER, -- value will be 1 if a probe never succeeded
+	never_failed_probe INTEGER, -- value will be 1 if a probe never failed
+    last_successful_probe DATETIME,
+    last_unsuccessful_probe DATETIME,
+
+    longest_uptime TEXT,
+    longest_uptime_start DATETIME,
+    longest_uptime_end DATETIME,
+
+    longest_downtime TEXT,
+    longest_downtime_start DATETIME,
+    longest_downtime_end DATETIME,
+
+    total_packets INTEGER,
+    total_packet_loss REAL,
+    total_successful_probes INTEGER,
+    total_unsuccessful_probes INTEGER,
+
+    total_uptime TEXT,
+    total_downtime TEXT
+);
+INSERT INTO "localhost_18097_12_00_47_10_16_2026" VALUES(1,'statistics','2026-10-16 12:00:48','127.0.0.1','source address','localhost',18097,0,NULL,NULL,0.0,0.0,0.0,'400.700382ms','2026-10-16 12:00:47','2026-10-16 12:00:48',1,0,'','2026-10-16 12:00:48','0s','','','400.502428ms','2026-10-16 12:00:47','2026-10-16 12:00:48',2,100.0,0,2,'0s','400ms');
+INSERT INTO "localhost_18097_12_00_47_10_16_2026" VALUES(2,'hostname change',NULL,NULL,NULL,NULL,NULL,NULL,'127.0.0.1','2026-10-16 12:00:47',NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL,NULL);
+CREATE TABLE notes (id INTEGER PRIMARY KEY, note TEXT);
+INSERT INTO "notes" VALUES(1,'added by hand, should be left alone');
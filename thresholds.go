@@ -0,0 +1,155 @@
+// thresholds.go checks the final statistics against the thresholds set by the user
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exit codes of tcping, besides 1 which is used for invalid input and
+// other errors. When several thresholds are exceeded, the exit code
+// of the first one in this list is used.
+const (
+	exitCodeOK = 0
+	// exitCodeNoSuccess is used when '--require-success' is set
+	// and a target had no successful probes.
+	exitCodeNoSuccess = 2
+	// exitCodeMaxLoss is used when the packet loss of a target exceeds '--max-loss'.
+	exitCodeMaxLoss = 3
+	// exitCodeMaxAvgRTT is used when the average RTT of a target exceeds '--max-avg-rtt'.
+	exitCodeMaxAvgRTT = 4
+	// exitCodeMaxP99RTT is used when the p99 RTT of a target exceeds '--max-p99-rtt'.
+	exitCodeMaxP99RTT = 5
+)
+
+// thresholds are the limits the final statistics of every target should stay in.
+type thresholds struct {
+	maxLoss        float64 // maxLoss is the highest acceptable packet loss, in percent
+	maxAvgRTT      float32 // maxAvgRTT is the highest acceptable average RTT, in ms
+	maxP99RTT      float32 // maxP99RTT is the highest acceptable p99 RTT, in ms
+	checkLoss      bool
+	checkAvgRTT    bool
+	checkP99RTT    bool
+	requireSuccess bool
+}
+
+// thresholdViolation describes an exceeded threshold.
+type thresholdViolation struct {
+	exitCode int
+	message  string
+}
+
+// parseLoss parses a packet loss percentage such as "5%" or "2.5".
+func parseLoss(loss string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(loss), "%"), 64)
+	if err != nil || value < 0 || value > 100 {
+		return 0, fmt.Errorf("invalid packet loss %q, expected a percentage between 0 and 100", loss)
+	}
+
+	return value, nil
+}
+
+// parseRTT parses an RTT such as "50ms" or "1.5s". Plain numbers are milliseconds.
+func parseRTT(rtt string) (float32, error) {
+	rtt = strings.TrimSpace(rtt)
+
+	if value, err := strconv.ParseFloat(rtt, 32); err == nil && value >= 0 {
+		return float32(value), nil
+	}
+
+	duration, err := time.ParseDuration(rtt)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid RTT %q, expected a duration such as 50ms", rtt)
+	}
+
+	return nanoToMillisecond(duration.Nanoseconds()), nil
+}
+
+// newThresholds creates the thresholds from the user input.
+// Empty values leave the corresponding threshold unchecked.
+func newThresholds(maxLoss, maxAvgRTT, maxP99RTT string, requireSuccess bool) (thresholds, error) {
+	th := thresholds{requireSuccess: requireSuccess}
+	var err error
+
+	if maxLoss != "" {
+		if th.maxLoss, err = parseLoss(maxLoss); err != nil {
+			return th, err
+		}
+		th.checkLoss = true
+	}
+
+	if maxAvgRTT != "" {
+		if th.maxAvgRTT, err = parseRTT(maxAvgRTT); err != nil {
+			return th, err
+		}
+		th.checkAvgRTT = true
+	}
+
+	if maxP99RTT != "" {
+		if th.maxP99RTT, err = parseRTT(maxP99RTT); err != nil {
+			return th, err
+		}
+		th.checkP99RTT = true
+	}
+
+	return th, nil
+}
+
+// check returns the thresholds exceeded by the target, most severe first.
+// rttResults of the target should be up to date.
+func (th thresholds) check(t *tcping) []thresholdViolation {
+	var violations []thresholdViolation
+
+	if th.requireSuccess && t.totalSuccessfulProbes == 0 {
+		violations = append(violations, thresholdViolation{
+			exitCode: exitCodeNoSuccess,
+			message:  fmt.Sprintf("no successful probes to %s on port %d", t.userInput.hostname, t.userInput.port),
+		})
+	}
+
+	totalPackets := t.totalSuccessfulProbes + t.totalUnsuccessfulProbes
+	if th.checkLoss && totalPackets > 0 {
+		loss := float64(t.totalUnsuccessfulProbes) / float64(totalPackets) * 100
+		if loss > th.maxLoss {
+			violations = append(violations, thresholdViolation{
+				exitCode: exitCodeMaxLoss,
+				message: fmt.Sprintf("packet loss of %.2f%% to %s on port %d exceeds %.2f%%",
+					loss, t.userInput.hostname, t.userInput.port, th.maxLoss),
+			})
+		}
+	}
+
+	if th.checkAvgRTT && t.rttResults.hasResults && t.rttResults.average > th.maxAvgRTT {
+		violations = append(violations, thresholdViolation{
+			exitCode: exitCodeMaxAvgRTT,
+			message: fmt.Sprintf("average RTT of %.3f ms to %s on port %d exceeds %.3f ms",
+				t.rttResults.average, t.userInput.hostname, t.userInput.port, th.maxAvgRTT),
+		})
+	}
+
+	if th.checkP99RTT && t.rttResults.hasResults && t.rttResults.p99 > th.maxP99RTT {
+		violations = append(violations, thresholdViolation{
+			exitCode: exitCodeMaxP99RTT,
+			message: fmt.Sprintf("p99 RTT of %.3f ms to %s on port %d exceeds %.3f ms",
+				t.rttResults.p99, t.userInput.hostname, t.userInput.port, th.maxP99RTT),
+		})
+	}
+
+	return violations
+}
+
+// exitCode returns the exit code for the violations of all targets,
+// which is the code of the most severe one.
+func exitCode(violations []thresholdViolation) int {
+	code := exitCodeOK
+
+	for _, v := range violations {
+		if code == exitCodeOK || v.exitCode < code {
+			code = v.exitCode
+		}
+	}
+
+	return code
+}
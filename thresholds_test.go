@@ -0,0 +1,164 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLoss(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		loss    string
+		want    float64
+		wantErr bool
+	}{
+		{loss: "5%", want: 5},
+		{loss: "2.5", want: 2.5},
+		{loss: "0%", want: 0},
+		{loss: "101%", wantErr: true},
+		{loss: "-1", wantErr: true},
+		{loss: "five", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.loss, func(t *testing.T) {
+			got, err := parseLoss(tt.loss)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseRTT(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rtt     string
+		want    float32
+		wantErr bool
+	}{
+		{rtt: "50ms", want: 50},
+		{rtt: "1.5s", want: 1500},
+		{rtt: "250us", want: 0.25},
+		{rtt: "20", want: 20},
+		{rtt: "-5ms", wantErr: true},
+		{rtt: "fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rtt, func(t *testing.T) {
+			got, err := parseRTT(tt.rtt)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestThresholdsCheck(t *testing.T) {
+	// createProbedStats creates stats with the given RTTs
+	// of successful probes and number of failed probes.
+	createProbedStats := func(t *testing.T, rtts []float32, failures int) *tcping {
+		stats := createTestStats(t)
+		for _, rtt := range rtts {
+			stats.handleConnSuccess("127.0.0.1:4567", rtt, time.Now(), time.Second, probeDetails{})
+		}
+		for i := 0; i < failures; i++ {
+			stats.handleConnError(time.Now(), time.Second, probeDetails{})
+		}
+		stats.rttResults = stats.rtt.result()
+
+		return stats
+	}
+
+	tests := []struct {
+		name      string
+		rtts      []float32
+		failures  int
+		maxLoss   string
+		maxAvgRTT string
+		maxP99RTT string
+		require   bool
+		wantCode  int
+	}{
+		{
+			name:      "within thresholds",
+			rtts:      []float32{10, 20, 30},
+			failures:  0,
+			maxLoss:   "0%",
+			maxAvgRTT: "25ms",
+			maxP99RTT: "50ms",
+			require:   true,
+			wantCode:  exitCodeOK,
+		},
+		{
+			name:     "no thresholds",
+			failures: 5,
+			wantCode: exitCodeOK,
+		},
+		{
+			name:     "no successful probes",
+			failures: 5,
+			maxLoss:  "50%",
+			require:  true,
+			wantCode: exitCodeNoSuccess,
+		},
+		{
+			name:      "packet loss",
+			rtts:      []float32{10, 10, 10},
+			failures:  1,
+			maxLoss:   "20%",
+			maxAvgRTT: "5ms",
+			wantCode:  exitCodeMaxLoss,
+		},
+		{
+			name:      "average RTT",
+			rtts:      []float32{10, 20, 30},
+			maxAvgRTT: "15ms",
+			maxP99RTT: "20ms",
+			wantCode:  exitCodeMaxAvgRTT,
+		},
+		{
+			name:      "p99 RTT",
+			rtts:      []float32{10, 100, 100},
+			maxAvgRTT: "80ms",
+			maxP99RTT: "50ms",
+			wantCode:  exitCodeMaxP99RTT,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th, err := newThresholds(tt.maxLoss, tt.maxAvgRTT, tt.maxP99RTT, tt.require)
+			assert.NoError(t, err)
+
+			stats := createProbedStats(t, tt.rtts, tt.failures)
+			violations := th.check(stats)
+
+			assert.Equal(t, tt.wantCode, exitCode(violations))
+		})
+	}
+}
+
+func TestExitCodeOfMultipleTargets(t *testing.T) {
+	violations := []thresholdViolation{
+		{exitCode: exitCodeMaxP99RTT},
+		{exitCode: exitCodeMaxLoss},
+		{exitCode: exitCodeMaxAvgRTT},
+	}
+
+	assert.Equal(t, exitCodeMaxLoss, exitCode(violations))
+	assert.Equal(t, exitCodeOK, exitCode(nil))
+}
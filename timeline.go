@@ -0,0 +1,139 @@
+// timeline.go keeps the probes over time for the charts of the HTML report
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// timelineSize is the largest number of points in a timeline
+const timelineSize = 512
+
+// timelinePoint aggregates consecutive probes
+type timelinePoint struct {
+	start      time.Time
+	end        time.Time
+	successful uint
+	failed     uint
+	rttMin     float32
+	rttMax     float32
+	rttSum     float64
+}
+
+// probeTimeline keeps the results of the probes over time in constant memory.
+//
+// Every point holds up to perPoint probes. Once the timeline is full,
+// adjacent points are merged and perPoint doubles, like zooming out of a chart.
+type probeTimeline struct {
+	points   []timelinePoint
+	perPoint uint
+}
+
+// newProbeTimeline creates an empty timeline
+func newProbeTimeline() *probeTimeline {
+	return &probeTimeline{
+		points:   make([]timelinePoint, 0, timelineSize),
+		perPoint: 1,
+	}
+}
+
+// probes returns the number of probes of the point
+func (p timelinePoint) probes() uint {
+	return p.successful + p.failed
+}
+
+// rttAverage returns the average RTT of the successful probes of the point
+func (p timelinePoint) rttAverage() float32 {
+	if p.successful == 0 {
+		return 0
+	}
+
+	return float32(p.rttSum / float64(p.successful))
+}
+
+// lossPercentage returns the percentage of the failed probes of the point
+func (p timelinePoint) lossPercentage() float64 {
+	if p.probes() == 0 {
+		return 0
+	}
+
+	return float64(p.failed) / float64(p.probes()) * 100
+}
+
+// add records a probe in the point
+func (p *timelinePoint) add(when time.Time, success bool, rtt float32) {
+	if p.probes() == 0 {
+		p.start = when
+	}
+	p.end = when
+
+	if !success {
+		p.failed++
+		return
+	}
+
+	if p.successful == 0 {
+		p.rttMin = rtt
+		p.rttMax = rtt
+	} else {
+		p.rttMin = float32(math.Min(float64(p.rttMin), float64(rtt)))
+		p.rttMax = float32(math.Max(float64(p.rttMax), float64(rtt)))
+	}
+	p.successful++
+	p.rttSum += float64(rtt)
+}
+
+// merge combines the point with the one right after it
+func (p timelinePoint) merge(next timelinePoint) timelinePoint {
+	merged := timelinePoint{
+		start:      p.start,
+		end:        next.end,
+		successful: p.successful + next.successful,
+		failed:     p.failed + next.failed,
+		rttMin:     p.rttMin,
+		rttMax:     p.rttMax,
+		rttSum:     p.rttSum + next.rttSum,
+	}
+
+	switch {
+	case p.successful == 0:
+		merged.rttMin = next.rttMin
+		merged.rttMax = next.rttMax
+	case next.successful > 0:
+		merged.rttMin = float32(math.Min(float64(p.rttMin), float64(next.rttMin)))
+		merged.rttMax = float32(math.Max(float64(p.rttMax), float64(next.rttMax)))
+	}
+
+	return merged
+}
+
+// add records a probe at the end of the timeline
+func (tl *probeTimeline) add(when time.Time, success bool, rtt float32) {
+	if n := len(tl.points); n > 0 && tl.points[n-1].probes() < tl.perPoint {
+		tl.points[n-1].add(when, success, rtt)
+		return
+	}
+
+	if len(tl.points) == timelineSize {
+		tl.compact()
+	}
+
+	var point timelinePoint
+	point.add(when, success, rtt)
+	tl.points = append(tl.points, point)
+}
+
+// compact merges every two adjacent points, halving the number of points
+func (tl *probeTimeline) compact() {
+	compacted := tl.points[:0]
+	for i := 0; i < len(tl.points); i += 2 {
+		point := tl.points[i]
+		if i+1 < len(tl.points) {
+			point = point.merge(tl.points[i+1])
+		}
+		compacted = append(compacted, point)
+	}
+
+	tl.points = compacted
+	tl.perPoint *= 2
+}
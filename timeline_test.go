@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimelinePointAdd(t *testing.T) {
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+
+	var p timelinePoint
+	p.add(start, false, 0)
+	p.add(start.Add(time.Second), true, 20)
+	p.add(start.Add(2*time.Second), true, 10)
+	p.add(start.Add(3*time.Second), true, 30)
+
+	assert.Equal(t, start, p.start)
+	assert.Equal(t, start.Add(3*time.Second), p.end)
+	assert.Equal(t, uint(4), p.probes())
+	assert.Equal(t, float32(10), p.rttMin)
+	assert.Equal(t, float32(30), p.rttMax)
+	assert.Equal(t, float32(20), p.rttAverage())
+	assert.Equal(t, float64(25), p.lossPercentage())
+}
+
+func TestTimelinePointMerge(t *testing.T) {
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+
+	var failed, succeeded timelinePoint
+	failed.add(start, false, 0)
+	succeeded.add(start.Add(time.Second), true, 15)
+
+	merged := failed.merge(succeeded)
+	assert.Equal(t, start, merged.start)
+	assert.Equal(t, start.Add(time.Second), merged.end)
+	assert.Equal(t, float32(15), merged.rttMin, "failed points have no RTT to merge")
+	assert.Equal(t, float32(15), merged.rttMax)
+	assert.Equal(t, float64(50), merged.lossPercentage())
+
+	merged = succeeded.merge(failed)
+	assert.Equal(t, float32(15), merged.rttMin)
+	assert.Equal(t, float32(15), merged.rttAverage())
+}
+
+func TestProbeTimeline(t *testing.T) {
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+
+	tl := newProbeTimeline()
+	for i := range timelineSize {
+		tl.add(start.Add(time.Duration(i)*time.Second), i%2 == 0, float32(i))
+	}
+
+	assert.Len(t, tl.points, timelineSize)
+	assert.Equal(t, uint(1), tl.perPoint)
+
+	// the timeline is full, so the next probe compacts it
+	tl.add(start.Add(timelineSize*time.Second), true, 1)
+
+	assert.Len(t, tl.points, timelineSize/2+1)
+	assert.Equal(t, uint(2), tl.perPoint)
+	assert.Equal(t, uint(2), tl.points[0].probes())
+	assert.Equal(t, float64(50), tl.points[0].lossPercentage())
+	assert.Equal(t, uint(1), tl.points[timelineSize/2].probes())
+
+	// the last point is filled up before a new one is added
+	tl.add(start.Add((timelineSize+1)*time.Second), false, 0)
+	assert.Len(t, tl.points, timelineSize/2+1)
+	assert.Equal(t, uint(2), tl.points[timelineSize/2].probes())
+
+	var probes uint
+	for _, p := range tl.points {
+		probes += p.probes()
+	}
+	assert.Equal(t, uint(timelineSize+2), probes, "no probe is lost while compacting")
+}
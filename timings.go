@@ -0,0 +1,25 @@
+// timings.go measures the phases of the probes besides connecting
+package main
+
+import (
+	"net"
+	"time"
+)
+
+// waitForFirstByte waits for the server to send its first byte, e.g.
+// an SSH or SMTP banner, and returns the time it took in ms. It's zero
+// if nothing was received within the timeout.
+func waitForFirstByte(conn net.Conn, timeout time.Duration) float32 {
+	start := time.Now()
+
+	if timeout > 0 {
+		conn.SetReadDeadline(start.Add(timeout))
+	}
+
+	buf := make([]byte, 1)
+	if n, _ := conn.Read(buf); n == 0 {
+		return 0
+	}
+
+	return nanoToMillisecond(time.Since(start).Nanoseconds())
+}
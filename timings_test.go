@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// detailsPrinter is a fake printer recording the details of the probes.
+type detailsPrinter struct {
+	dummyPrinter
+	details []probeDetails
+}
+
+func (p *detailsPrinter) printProbeSuccess(_ string, _ userInput, _ uint, _ float32, details probeDetails) {
+	p.details = append(p.details, details)
+}
+
+// bannerServerListen starts a TCP server which sends
+// the banner after the delay on every connection.
+func bannerServerListen(t *testing.T, banner string, delay time.Duration) netip.AddrPort {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				time.Sleep(delay)
+				if banner != "" {
+					conn.Write([]byte(banner))
+				}
+				// wait for the client to close the connection
+				conn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+
+	return netip.MustParseAddrPort(listener.Addr().String())
+}
+
+func TestWaitForFirstByte(t *testing.T) {
+	t.Run("banner", func(t *testing.T) {
+		addr := bannerServerListen(t, "SSH-2.0-OpenSSH\r\n", 20*time.Millisecond)
+
+		conn, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+
+		firstByte := waitForFirstByte(conn, time.Second)
+		assert.GreaterOrEqual(t, firstByte, float32(20))
+		assert.Less(t, firstByte, float32(1000))
+	})
+
+	t.Run("silent server", func(t *testing.T) {
+		addr := bannerServerListen(t, "", 0)
+
+		conn, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+
+		assert.Equal(t, float32(0), waitForFirstByte(conn, 50*time.Millisecond))
+	})
+}
+
+func TestProbeTimings(t *testing.T) {
+	addr := bannerServerListen(t, "220 smtp.example.com ESMTP\r\n", 50*time.Millisecond)
+
+	printer := &detailsPrinter{}
+	stats := createTestStats(t)
+	stats.printer = printer
+	stats.userInput.ip = addr.Addr()
+	stats.userInput.port = addr.Port()
+	stats.userInput.showTimings = true
+	stats.userInput.waitForFirstByte = true
+	stats.ticker = time.NewTicker(time.Nanosecond)
+	stats.dnsTime = 12.5
+
+	tcpProbe(stats)
+	tcpProbe(stats)
+
+	assert.Len(t, printer.details, 2)
+
+	// the DNS resolution is only reported by the first probe after it
+	assert.Equal(t, float32(12.5), printer.details[0].dnsTime)
+	assert.Equal(t, float32(0), printer.details[1].dnsTime)
+
+	for _, details := range printer.details {
+		assert.Greater(t, details.connectTime, float32(0))
+		assert.GreaterOrEqual(t, details.firstByteTime, float32(50))
+	}
+
+	// the RTT is the connect time, without waiting for the banner
+	assert.Less(t, stats.rtt.max, float64(printer.details[0].firstByteTime))
+}
@@ -0,0 +1,77 @@
+// tlsprobe.go completes a TLS handshake on top of the TCP probes
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+)
+
+// tlsDetails holds the outcome of a TLS handshake.
+type tlsDetails struct {
+	// handshakeTime is the time it took to complete the TLS handshake, in ms.
+	handshakeTime float32
+	version       string
+	cipherSuite   string
+	alpn          string
+	certSubject   string
+	certExpiry    time.Time
+	// err is set when the handshake has failed.
+	err error
+}
+
+// newTLSConfig creates the TLS configuration used by the TLS probes.
+// alpn is a comma-separated list of protocols to offer, e.g. "h2,http/1.1".
+func newTLSConfig(serverName string, insecure bool, alpn string) *tls.Config {
+	config := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: insecure,
+	}
+
+	for _, protocol := range strings.Split(alpn, ",") {
+		if protocol = strings.TrimSpace(protocol); protocol != "" {
+			config.NextProtos = append(config.NextProtos, protocol)
+		}
+	}
+
+	return config
+}
+
+// tlsHandshake completes a TLS handshake over an already established connection.
+// The returned connection should be closed by the caller, even on errors.
+func tlsHandshake(conn net.Conn, config *tls.Config, timeout time.Duration) (*tls.Conn, tlsDetails, error) {
+	var details tlsDetails
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tlsConn := tls.Client(conn, config)
+
+	handshakeStart := time.Now()
+	err := tlsConn.HandshakeContext(ctx)
+	details.handshakeTime = nanoToMillisecond(time.Since(handshakeStart).Nanoseconds())
+
+	if err != nil {
+		details.err = err
+		return tlsConn, details, err
+	}
+
+	state := tlsConn.ConnectionState()
+	details.version = tls.VersionName(state.Version)
+	details.cipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	details.alpn = state.NegotiatedProtocol
+
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		details.certSubject = leaf.Subject.String()
+		details.certExpiry = leaf.NotAfter
+	}
+
+	return tlsConn, details, nil
+}
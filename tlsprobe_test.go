@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createServerTestStats creates a tcping probing the given test server.
+func createServerTestStats(t *testing.T, srv *httptest.Server) *tcping {
+	addrPort, err := netip.ParseAddrPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("parse test server address: %v", err)
+	}
+
+	stats := createTestStats(t)
+	stats.userInput.ip = addrPort.Addr()
+	stats.userInput.port = addrPort.Port()
+	stats.ticker = time.NewTicker(time.Nanosecond)
+
+	return stats
+}
+
+func TestTLSProbeSuccess(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	stats := createServerTestStats(t, srv)
+
+	// httptest certificates are valid for example.com
+	tlsConfig := newTLSConfig("example.com", false, "h2,http/1.1")
+	tlsConfig.RootCAs = x509.NewCertPool()
+	tlsConfig.RootCAs.AddCert(srv.Certificate())
+	stats.userInput.tlsConfig = tlsConfig
+
+	for i := 0; i < 5; i++ {
+		tcpProbe(stats)
+	}
+
+	assert.Equal(t, uint(5), stats.totalSuccessfulProbes)
+	assert.Equal(t, uint(0), stats.totalUnsuccessfulProbes)
+}
+
+func TestTLSProbeHandshakeFailure(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	stats := createServerTestStats(t, srv)
+
+	// the certificate of the test server is not trusted
+	stats.userInput.tlsConfig = newTLSConfig("example.com", false, "")
+
+	for i := 0; i < 5; i++ {
+		tcpProbe(stats)
+	}
+
+	assert.Equal(t, uint(0), stats.totalSuccessfulProbes)
+	assert.Equal(t, uint(5), stats.totalUnsuccessfulProbes)
+	assert.Equal(t, uint(5), stats.ongoingUnsuccessfulProbes)
+	assert.True(t, stats.destWasDown)
+}
+
+func TestTLSHandshakeDetails(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial test server: %v", err)
+	}
+
+	tlsConn, details, err := tlsHandshake(conn, newTLSConfig("example.com", true, "h2"), time.Second)
+	t.Cleanup(func() { tlsConn.Close() })
+
+	assert.NoError(t, err)
+	assert.NoError(t, details.err)
+	assert.Equal(t, "TLS 1.3", details.version)
+	assert.NotEmpty(t, details.cipherSuite)
+	assert.Equal(t, "h2", details.alpn)
+	assert.Equal(t, srv.Certificate().Subject.String(), details.certSubject)
+	assert.Equal(t, srv.Certificate().NotAfter, details.certExpiry)
+	assert.Greater(t, details.handshakeTime, float32(0))
+}
@@ -0,0 +1,419 @@
+// tui.go draws a full-screen dashboard of the targets, redrawn on every probe
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+)
+
+const (
+	// tuiWindow is the number of recent probes in the sparkline and the rolling loss
+	tuiWindow = 60
+	// tuiOutages is the number of recent outages listed per target
+	tuiOutages = 3
+	// tuiMessages is the number of recent messages listed below the targets
+	tuiMessages = 5
+	// tuiHistogramWidth is the width of the longest bar of the RTT histogram
+	tuiHistogramWidth = 30
+
+	// ANSI escape sequences to redraw the screen in place
+	ansiClearScreen = "\x1b[H\x1b[2J"
+	ansiCursorHome  = "\x1b[H"
+	ansiClearLine   = "\x1b[K"
+	ansiClearBelow  = "\x1b[J"
+)
+
+// sparkBlocks are the bars of the sparkline, from the lowest to the highest RTT
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// tuiProbe is the result of a probe kept for the sparkline
+type tuiProbe struct {
+	success bool
+	rtt     float32
+}
+
+// tuiTarget holds the recent probes of a target
+type tuiTarget struct {
+	t      *tcping
+	recent []tuiProbe // recent holds up to tuiWindow probes, the oldest first
+}
+
+// tuiPrinter redraws a dashboard of all targets instead of
+// printing a line per probe. The final statistics, and anything
+// printed after them, are left to the regular terminal printer.
+//
+// The dashboard is drawn on the main screen rather than the alternate one,
+// so that the last frame stays visible even if tcping exits on an error.
+type tuiPrinter struct {
+	final     printer // final prints once the dashboard is closed
+	out       io.Writer
+	colored   bool
+	startTime time.Time
+	targets   []*tuiTarget
+	messages  []string // messages are the recent info and error messages, the oldest first
+	started   bool
+	closed    bool
+}
+
+func newTUIPrinter(final printer, colored bool) *tuiPrinter {
+	return &tuiPrinter{
+		final:     final,
+		out:       os.Stdout,
+		colored:   colored,
+		startTime: time.Now(),
+	}
+}
+
+// isTerminal reports whether the file is an interactive terminal,
+// which is needed to redraw the dashboard.
+func isTerminal(f *os.File) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// setTargets sets the targets drawn on the dashboard
+func (p *tuiPrinter) setTargets(targets []*tcping) {
+	p.targets = make([]*tuiTarget, 0, len(targets))
+	for _, t := range targets {
+		p.addTarget(t)
+	}
+}
+
+// addTarget adds a target to the dashboard, e.g. a new target of SRV records
+func (p *tuiPrinter) addTarget(t *tcping) {
+	p.targets = append(p.targets, &tuiTarget{t: t})
+}
+
+// target returns the dashboard state of the target with the given input.
+// The probes of a previous IP address of a target are not a part of it,
+// unlike the ones of any address winning a Happy Eyeballs race.
+// Targets removed from the SRV records are skipped, as they may come back.
+func (p *tuiPrinter) target(userInput userInput) *tuiTarget {
+	for _, target := range p.targets {
+		if target.t.isStopped() {
+			continue
+		}
+
+		sameIP := target.t.userInput.ip == userInput.ip || userInput.happyEyeballs
+		if target.t.userInput.hostname == userInput.hostname && target.t.userInput.port == userInput.port && sameIP {
+			return target
+		}
+	}
+	return nil
+}
+
+// record adds a probe to the recent probes of the target
+func (tt *tuiTarget) record(probe tuiProbe) {
+	if len(tt.recent) == tuiWindow {
+		copy(tt.recent, tt.recent[1:])
+		tt.recent = tt.recent[:tuiWindow-1]
+	}
+	tt.recent = append(tt.recent, probe)
+}
+
+// rollingLoss returns the packet loss of the recent probes
+func (tt *tuiTarget) rollingLoss() float64 {
+	if len(tt.recent) == 0 {
+		return 0
+	}
+
+	var failed int
+	for _, probe := range tt.recent {
+		if !probe.success {
+			failed++
+		}
+	}
+
+	return float64(failed) / float64(len(tt.recent)) * 100
+}
+
+// sparkline draws the RTTs of the recent probes relative to each other.
+// Failed probes are drawn as a cross.
+func sparkline(probes []tuiProbe) string {
+	var low, high float32
+	first := true
+	for _, probe := range probes {
+		if !probe.success {
+			continue
+		}
+		if first || probe.rtt < low {
+			low = probe.rtt
+		}
+		if first || probe.rtt > high {
+			high = probe.rtt
+		}
+		first = false
+	}
+
+	var sb strings.Builder
+	for _, probe := range probes {
+		if !probe.success {
+			sb.WriteRune('×')
+			continue
+		}
+
+		level := 0
+		if high > low {
+			level = int(float32(len(sparkBlocks)-1) * (probe.rtt - low) / (high - low))
+		}
+		sb.WriteRune(sparkBlocks[level])
+	}
+
+	return sb.String()
+}
+
+// paint colors the text, unless the colors are disabled
+func (p *tuiPrinter) paint(c color.Color, format string, args ...any) string {
+	text := fmt.Sprintf(format, args...)
+	if !p.colored {
+		return text
+	}
+	return c.Sprint(text)
+}
+
+// histogramLines draws the RTT histogram of the whole run,
+// from the lowest to the highest bucket with any RTTs.
+func (p *tuiPrinter) histogramLines(s *rttStats) []string {
+	counts := make([]uint64, 0, len(rttHistogramBounds)+1)
+	labels := make([]string, 0, len(rttHistogramBounds)+1)
+
+	var inBounds uint64
+	for i, bound := range rttHistogramBounds {
+		counts = append(counts, s.histogram[i])
+		labels = append(labels, fmt.Sprintf("<= %g ms", bound))
+		inBounds += s.histogram[i]
+	}
+	counts = append(counts, s.count-inBounds)
+	labels = append(labels, fmt.Sprintf(" > %g ms", rttHistogramBounds[len(rttHistogramBounds)-1]))
+
+	first, last := -1, -1
+	var highest uint64
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		if first == -1 {
+			first = i
+		}
+		last = i
+		highest = max(highest, count)
+	}
+
+	if first == -1 {
+		return nil
+	}
+
+	lines := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		width := int(counts[i] * tuiHistogramWidth / highest)
+		if counts[i] > 0 && width == 0 {
+			width = 1
+		}
+		lines = append(lines, fmt.Sprintf("%10s %s %d", labels[i], p.paint(color.Cyan, "%s", strings.Repeat("█", width)), counts[i]))
+	}
+
+	return lines
+}
+
+// targetLines draws the status and the running statistics of the target
+func (p *tuiPrinter) targetLines(tt *tuiTarget) []string {
+	t := tt.t
+
+	name := fmt.Sprintf("%s on port %d", t.userInput.ip, t.userInput.port)
+	if t.userInput.hostname != "" && t.userInput.hostname != t.userInput.ip.String() {
+		name = fmt.Sprintf("%s (%s) on port %d", t.userInput.hostname, t.userInput.ip, t.userInput.port)
+	}
+
+	var status, streak string
+	switch {
+	case t.isStopped():
+		status = p.paint(color.Gray, "GONE   ")
+		streak = fmt.Sprintf("removed from the SRV records of %s", t.srv.name)
+	case len(tt.recent) == 0:
+		status = p.paint(color.Yellow, "WAITING")
+	case t.destWasDown:
+		status = p.paint(color.Red, "DOWN   ")
+		streak = fmt.Sprintf("%d failed probes in a row, down for %s", t.ongoingUnsuccessfulProbes, durationToString(time.Since(t.startOfDowntime)))
+	default:
+		status = p.paint(color.LightGreen, "UP     ")
+		streak = fmt.Sprintf("%d successful probes in a row", t.ongoingSuccessfulProbes)
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s %s", status, p.paint(color.LightCyan, "%s", name)),
+	}
+
+	if streak != "" {
+		lines = append(lines, "        "+streak)
+	}
+
+	lines = append(lines, fmt.Sprintf("        probes %d sent, %d received | loss %.2f%% of the last %d, %.2f%% in total",
+		t.totalSuccessfulProbes+t.totalUnsuccessfulProbes, t.totalSuccessfulProbes,
+		tt.rollingLoss(), len(tt.recent), lossPercentage(t)))
+
+	lines = append(lines, fmt.Sprintf("        uptime %s | downtime %s", durationToString(t.totalUptime), durationToString(t.totalDowntime)))
+
+	if rtt := t.rtt.result(); rtt.hasResults {
+		lines = append(lines, fmt.Sprintf("        rtt min %.3f | avg %.3f | max %.3f | p95 %.3f | p99 %.3f | jitter %.3f ms",
+			rtt.min, rtt.average, rtt.max, rtt.p95, rtt.p99, rtt.jitter))
+	}
+
+	if len(tt.recent) > 0 {
+		lines = append(lines, "        "+p.paint(color.LightGreen, "%s", sparkline(tt.recent)))
+	}
+
+	for _, line := range p.histogramLines(&t.rtt) {
+		lines = append(lines, "        "+line)
+	}
+
+	outages := t.allOutages()
+	if len(outages) > 0 {
+		summary := summarizeOutages(outages, t.totalUptime)
+		lines = append(lines, "        "+p.paint(color.Yellow, "%s", formatOutageSummary(summary)))
+
+		for _, o := range outages[max(0, len(outages)-tuiOutages):] {
+			end := o.end.Format(hourFormat)
+			if o.ongoing {
+				end = "ongoing"
+			}
+			lines = append(lines, fmt.Sprintf("          %s - %s  %s, %d failed probes, %s",
+				o.start.Format(timeFormat), end, durationToString(o.duration), o.failedProbes, o.ip))
+		}
+	}
+
+	return lines
+}
+
+// render draws the whole dashboard
+func (p *tuiPrinter) render(now time.Time) string {
+	var sb strings.Builder
+
+	writeLine := func(line string) {
+		sb.WriteString(line)
+		sb.WriteString(ansiClearLine)
+		sb.WriteString("\n")
+	}
+
+	elapsed := time.Time{}.Add(now.Sub(p.startTime))
+	writeLine(p.paint(color.LightCyan, "TCPING version %s | running for %s | press Ctrl+C to quit", version, elapsed.Format(hourFormat)))
+
+	for _, tt := range p.targets {
+		writeLine("")
+		for _, line := range p.targetLines(tt) {
+			writeLine(line)
+		}
+	}
+
+	if len(p.messages) > 0 {
+		writeLine("")
+		for _, message := range p.messages {
+			writeLine(message)
+		}
+	}
+
+	return sb.String()
+}
+
+// draw redraws the dashboard in place
+func (p *tuiPrinter) draw() {
+	if p.closed {
+		return
+	}
+
+	prefix := ansiCursorHome
+	if !p.started {
+		prefix = ansiClearScreen
+		p.started = true
+	}
+
+	fmt.Fprint(p.out, prefix+p.render(time.Now())+ansiClearBelow)
+}
+
+// addMessage lists the message below the targets
+func (p *tuiPrinter) addMessage(message string) {
+	if len(p.messages) == tuiMessages {
+		p.messages = p.messages[1:]
+	}
+	p.messages = append(p.messages, strings.TrimSpace(message))
+}
+
+func (p *tuiPrinter) printStart(_ string, _ uint16) {
+	p.draw()
+}
+
+func (p *tuiPrinter) printProbeSuccess(_ string, userInput userInput, _ uint, rtt float32, _ probeDetails) {
+	if userInput.showFailuresOnly {
+		return
+	}
+
+	if tt := p.target(userInput); tt != nil {
+		tt.record(tuiProbe{success: true, rtt: rtt})
+	}
+	p.draw()
+}
+
+func (p *tuiPrinter) printProbeFail(userInput userInput, _ uint, _ probeDetails) {
+	if tt := p.target(userInput); tt != nil {
+		tt.record(tuiProbe{success: false})
+	}
+	p.draw()
+}
+
+func (p *tuiPrinter) printTotalDownTime(userInput userInput, downtime time.Duration) {
+	host := userInput.hostname
+	if host == "" {
+		host = userInput.ip.String()
+	}
+	p.addMessage(p.paint(color.Yellow, "%s No response received from %s on port %d for %s",
+		time.Now().Format(hourFormat), host, userInput.port, durationToString(downtime)))
+}
+
+func (p *tuiPrinter) printRetryingToResolve(hostname, resolver string) {
+	p.addMessage(p.paint(color.LightYellow, "%s %s", time.Now().Format(hourFormat), retryingToResolveMessage(hostname, resolver)))
+	p.draw()
+}
+
+// printStatistics closes the dashboard, as it's only called on exit,
+// and prints the final statistics below its last frame.
+func (p *tuiPrinter) printStatistics(t tcping) {
+	if !p.closed {
+		p.draw()
+		p.closed = true
+	}
+	p.final.printStatistics(t)
+}
+
+func (p *tuiPrinter) printVersion() {
+	p.final.printVersion()
+}
+
+func (p *tuiPrinter) printInfo(format string, args ...any) {
+	if p.closed || !p.started {
+		p.final.printInfo(format, args...)
+		return
+	}
+	p.addMessage(p.paint(color.FgLightBlue, format, args...))
+	p.draw()
+}
+
+func (p *tuiPrinter) printError(format string, args ...any) {
+	if p.closed || !p.started {
+		p.final.printError(format, args...)
+		return
+	}
+	p.addMessage(p.paint(color.Red, format, args...))
+	p.draw()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTUITargetRecord(t *testing.T) {
+	var tt tuiTarget
+	for i := range tuiWindow + 10 {
+		tt.record(tuiProbe{success: i%4 != 0, rtt: float32(i)})
+	}
+
+	assert.Len(t, tt.recent, tuiWindow)
+	assert.Equal(t, float32(10), tt.recent[0].rtt, "the oldest probes are dropped")
+	assert.Equal(t, float32(tuiWindow+9), tt.recent[tuiWindow-1].rtt)
+	assert.InDelta(t, 25, tt.rollingLoss(), 0.001)
+}
+
+func TestTUIPrinterTarget(t *testing.T) {
+	stats := createTestStats(t)
+	stats.userInput.hostname = "localhost"
+
+	p := newTUIPrinter(&dummyPrinter{}, false)
+	p.setTargets([]*tcping{stats})
+
+	assert.Same(t, stats, p.target(stats.userInput).t)
+
+	previousIP := stats.userInput
+	previousIP.ip = netip.MustParseAddr("127.0.0.2")
+	assert.Nil(t, p.target(previousIP), "the previous IP is drained apart")
+
+	stats.userInput.happyEyeballs = true
+	raceWinner := stats.userInput
+	raceWinner.ip = netip.MustParseAddr("::1")
+	assert.Same(t, stats, p.target(raceWinner).t, "any address may win the race")
+}
+
+func TestSparkline(t *testing.T) {
+	probes := []tuiProbe{
+		{success: true, rtt: 10},
+		{success: true, rtt: 20},
+		{success: false},
+		{success: true, rtt: 15},
+	}
+	assert.Equal(t, "▁█×▄", sparkline(probes))
+
+	constant := []tuiProbe{{success: true, rtt: 5}, {success: true, rtt: 5}}
+	assert.Equal(t, "▁▁", sparkline(constant))
+	assert.Equal(t, "", sparkline(nil))
+}
+
+func TestTUIPrinter(t *testing.T) {
+	var out, final bytes.Buffer
+	showTimestamp := false
+
+	p := newTUIPrinter(&plainPrinter{showTimestamp: &showTimestamp, out: &final}, false)
+	p.out = &out
+
+	stats := createTestStats(t)
+	stats.userInput.hostname = "localhost"
+	stats.printer = p
+	p.setTargets([]*tcping{stats})
+
+	// messages before the dashboard is drawn go to the regular printer
+	p.printInfo("Serving Prometheus metrics")
+	assert.Contains(t, final.String(), "Serving Prometheus metrics")
+
+	p.printStart(stats.userInput.hostname, stats.userInput.port)
+	assert.True(t, strings.HasPrefix(out.String(), ansiClearScreen))
+	assert.Contains(t, out.String(), "WAITING localhost (127.0.0.1) on port 12345")
+
+	now := time.Now()
+	stats.handleConnSuccess("127.0.0.1:4567", 10, now, time.Second, probeDetails{})
+	stats.handleConnError(now.Add(time.Second), time.Second, probeDetails{})
+
+	out.Reset()
+	stats.handleConnError(now.Add(2*time.Second), time.Second, probeDetails{})
+	frame := out.String()
+	assert.True(t, strings.HasPrefix(frame, ansiCursorHome), "the dashboard is redrawn in place")
+	assert.Contains(t, frame, "DOWN    localhost (127.0.0.1) on port 12345")
+	assert.Contains(t, frame, "2 failed probes in a row")
+	assert.Contains(t, frame, "probes 3 sent, 1 received | loss 66.67% of the last 3, 66.67% in total")
+	assert.Contains(t, frame, "▁××")
+	assert.Contains(t, frame, "1 outage, MTTR")
+
+	// errors are listed on the dashboard while it's open
+	p.printError("webhook failed")
+	assert.Contains(t, out.String(), "webhook failed")
+	assert.NotContains(t, final.String(), "webhook failed")
+
+	// the final statistics close the dashboard
+	p.printStatistics(*stats)
+	assert.Contains(t, final.String(), "TCPing statistics")
+
+	out.Reset()
+	stats.handleConnSuccess("127.0.0.1:4567", 10, now.Add(3*time.Second), time.Second, probeDetails{})
+	assert.Empty(t, out.String(), "nothing is drawn after closing the dashboard")
+
+	p.printError("Threshold exceeded")
+	assert.Contains(t, final.String(), "Threshold exceeded")
+}
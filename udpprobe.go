@@ -0,0 +1,106 @@
+// udpprobe.go sends UDP datagrams and waits for their replies
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxUDPReplySize is the largest reply that is read. Longer replies
+// are truncated, which is fine since only their arrival matters.
+const maxUDPReplySize = 65535
+
+// parseHexPayload decodes a hex encoded payload such as "deadbeef",
+// "0xdeadbeef" or "de ad be ef".
+func parseHexPayload(payload string) ([]byte, error) {
+	payload = strings.Join(strings.Fields(payload), "")
+	payload = strings.TrimPrefix(strings.TrimPrefix(payload, "0x"), "0X")
+
+	decoded, err := hex.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex payload: %w", err)
+	}
+
+	return decoded, nil
+}
+
+// readUDPPayload returns the payload given either as hex or as a file.
+func readUDPPayload(hexPayload, payloadFile string) ([]byte, error) {
+	if hexPayload != "" && payloadFile != "" {
+		return nil, fmt.Errorf("only one of '--udp-payload' and '--udp-payload-file' can be used")
+	}
+
+	if payloadFile != "" {
+		return os.ReadFile(payloadFile)
+	}
+
+	return parseHexPayload(hexPayload)
+}
+
+// udpExchange sends the payload over the connection and
+// waits for any reply until the timeout is reached.
+func udpExchange(conn net.Conn, payload []byte, timeout time.Duration) error {
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	if _, err := conn.Write(payload); err != nil {
+		return err
+	}
+
+	reply := make([]byte, maxUDPReplySize)
+	_, err := conn.Read(reply)
+
+	return err
+}
+
+// udpProbe sends the UDP payload to the target and counts
+// a reply within the timeout as a successful probe.
+func udpProbe(tcping *tcping) {
+	var err error
+	var conn net.Conn
+	probeStart := time.Now()
+
+	ipAndPort := netip.AddrPortFrom(tcping.userInput.ip, tcping.userInput.port)
+
+	if tcping.userInput.networkInterface.use {
+		dialer := tcping.userInput.networkInterface.dialer
+		dialer.LocalAddr = &net.UDPAddr{IP: tcping.userInput.networkInterface.dialer.LocalAddr.(*net.TCPAddr).IP}
+		conn, err = dialer.Dial("udp", ipAndPort.String())
+	} else {
+		conn, err = net.Dial("udp", ipAndPort.String())
+	}
+
+	if err == nil {
+		err = udpExchange(conn, tcping.userInput.udpPayload, tcping.userInput.timeout)
+	}
+
+	probeDuration := time.Since(probeStart)
+	rtt := nanoToMillisecond(probeDuration.Nanoseconds())
+
+	// only the DNS resolution is timed, as there's no connection to establish
+	var details probeDetails
+	if tcping.userInput.showTimings {
+		details.dnsTime = tcping.dnsTime
+		tcping.dnsTime = 0
+	}
+
+	elapsed := maxDuration(probeDuration, tcping.userInput.intervalBetweenProbes)
+
+	if err != nil {
+		details.failureReason = classifyFailure(err)
+		tcping.handleConnError(probeStart, elapsed, details)
+	} else {
+		tcping.handleConnSuccess(conn.LocalAddr().String(), rtt, probeStart, elapsed, details)
+	}
+
+	if conn != nil {
+		conn.Close()
+	}
+	<-tcping.ticker.C
+}
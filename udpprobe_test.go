@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createUDPTestStats creates a tcping probing the given UDP address.
+func createUDPTestStats(t *testing.T, addr net.Addr, payload []byte) *tcping {
+	addrPort, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		t.Fatalf("parse test server address: %v", err)
+	}
+
+	stats := createTestStats(t)
+	stats.userInput.ip = addrPort.Addr()
+	stats.userInput.port = addrPort.Port()
+	stats.userInput.useUDP = true
+	stats.userInput.udpPayload = payload
+	stats.userInput.timeout = 200 * time.Millisecond
+	stats.ticker = time.NewTicker(time.Nanosecond)
+
+	return stats
+}
+
+// udpServerListen starts a UDP server that replies to
+// the datagrams matching the payload with "pong".
+func udpServerListen(t *testing.T, payload []byte) net.Addr {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if bytes.Equal(buf[:n], payload) {
+				conn.WriteTo([]byte("pong"), addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr()
+}
+
+func TestParseHexPayload(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		payload string
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			payload: "",
+			want:    []byte{},
+		},
+		{
+			name:    "plain",
+			payload: "deadbeef",
+			want:    []byte{0xde, 0xad, 0xbe, 0xef},
+		},
+		{
+			name:    "prefixed with spaces",
+			payload: "0xde ad BE ef",
+			want:    []byte{0xde, 0xad, 0xbe, 0xef},
+		},
+		{
+			name:    "odd length",
+			payload: "abc",
+			wantErr: true,
+		},
+		{
+			name:    "not hex",
+			payload: "hello",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHexPayload(tt.payload)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadUDPPayload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "payload.bin")
+	err := os.WriteFile(filename, []byte("ping"), 0o600)
+	assert.NoError(t, err)
+
+	payload, err := readUDPPayload("", filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ping"), payload)
+
+	payload, err = readUDPPayload("70696e67", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ping"), payload)
+
+	_, err = readUDPPayload("70696e67", filename)
+	assert.Error(t, err)
+}
+
+func TestUDPProbeSuccess(t *testing.T) {
+	payload := []byte("ping")
+	addr := udpServerListen(t, payload)
+	stats := createUDPTestStats(t, addr, payload)
+
+	for i := 0; i < 5; i++ {
+		udpProbe(stats)
+	}
+
+	assert.Equal(t, uint(5), stats.totalSuccessfulProbes)
+	assert.Equal(t, uint(0), stats.totalUnsuccessfulProbes)
+	assert.Equal(t, uint64(5), stats.rtt.count)
+}
+
+func TestUDPProbeTimings(t *testing.T) {
+	payload := []byte("ping")
+	addr := udpServerListen(t, payload)
+
+	printer := &detailsPrinter{}
+	stats := createUDPTestStats(t, addr, payload)
+	stats.printer = printer
+	stats.userInput.showTimings = true
+	stats.dnsTime = 12.5
+
+	udpProbe(stats)
+	udpProbe(stats)
+
+	// the DNS resolution is only reported by the first probe after it
+	assert.Len(t, printer.details, 2)
+	assert.Equal(t, float32(12.5), printer.details[0].dnsTime)
+	assert.Equal(t, float32(0), printer.details[1].dnsTime)
+	assert.Equal(t, float32(0), printer.details[0].connectTime, "UDP has no connection to time")
+}
+
+func TestUDPProbeNoReply(t *testing.T) {
+	// the server ignores datagrams with an unexpected payload
+	addr := udpServerListen(t, []byte("ping"))
+	stats := createUDPTestStats(t, addr, []byte("hello"))
+
+	for i := 0; i < 3; i++ {
+		udpProbe(stats)
+	}
+
+	assert.Equal(t, uint(0), stats.totalSuccessfulProbes)
+	assert.Equal(t, uint(3), stats.totalUnsuccessfulProbes)
+	assert.True(t, stats.destWasDown)
+}
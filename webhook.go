@@ -0,0 +1,75 @@
+// webhook.go posts the state changes of the targets to a URL
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout is the timeout of a single request.
+const webhookTimeout = 5 * time.Second
+
+// webhookNotifier posts the state changes as JSON to a URL.
+type webhookNotifier struct {
+	*notificationQueue
+	url    string
+	client *http.Client
+	// retries is the number of retries after a failed request.
+	retries uint
+	// backoff is the wait before the first retry, doubled for every next one.
+	backoff time.Duration
+}
+
+// newWebhookNotifier creates a webhook notifier and
+// starts sending its notifications in the background.
+func newWebhookNotifier(url string, retries uint, backoff time.Duration, onError func(format string, args ...any)) *webhookNotifier {
+	w := &webhookNotifier{
+		url:     url,
+		client:  &http.Client{Timeout: webhookTimeout},
+		retries: retries,
+		backoff: backoff,
+	}
+	w.notificationQueue = newNotificationQueue("webhook", w.sendWithRetries, onError)
+
+	return w
+}
+
+// sendWithRetries sends the notification, retrying with an exponential backoff.
+func (w *webhookNotifier) sendWithRetries(change stateChange) error {
+	body, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	backoff := w.backoff
+	for attempt := uint(0); ; attempt++ {
+		err = w.send(body)
+		if err == nil || attempt == w.retries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// send posts the body once. Status codes other than 2xx are errors.
+func (w *webhookNotifier) send(body []byte) error {
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookNotifierSend(t *testing.T) {
+	var mu sync.Mutex
+	var received []stateChange
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var change stateChange
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		mu.Lock()
+		received = append(received, change)
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+
+	w := newWebhookNotifier(srv.URL, 0, time.Millisecond, func(format string, args ...any) {
+		t.Errorf(format, args...)
+	})
+
+	w.notify(stateChange{Event: stateChangeDown, Hostname: "example.com", IP: "127.0.0.1", Port: 443, FailedProbes: 3, Downtime: 2})
+	w.notify(stateChange{Event: stateChangeUp, Hostname: "example.com", IP: "127.0.0.1", Port: 443, FailedProbes: 5, Downtime: 4})
+	w.flush(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Len(t, received, 2)
+	assert.Equal(t, stateChangeDown, received[0].Event)
+	assert.Equal(t, uint(3), received[0].FailedProbes)
+	assert.Equal(t, stateChangeUp, received[1].Event)
+	assert.Equal(t, float64(4), received[1].Downtime)
+	assert.Equal(t, "127.0.0.1", received[1].IP)
+}
+
+func TestWebhookNotifierRetries(t *testing.T) {
+	var attempts atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// the first two attempts fail
+		if attempts.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	var failures atomic.Int32
+	w := newWebhookNotifier(srv.URL, 2, time.Millisecond, func(_ string, _ ...any) {
+		failures.Add(1)
+	})
+
+	w.notify(stateChange{Event: stateChangeDown})
+	w.flush(5 * time.Second)
+
+	assert.Equal(t, int32(3), attempts.Load())
+	assert.Equal(t, int32(0), failures.Load())
+}
+
+func TestWebhookNotifierGivesUp(t *testing.T) {
+	var attempts atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+
+	var failures atomic.Int32
+	w := newWebhookNotifier(srv.URL, 1, time.Millisecond, func(_ string, _ ...any) {
+		failures.Add(1)
+	})
+
+	w.notify(stateChange{Event: stateChangeDown})
+	w.flush(5 * time.Second)
+
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.Equal(t, int32(1), failures.Load())
+}
+
+func TestWebhookNotifierDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	var dropped atomic.Int32
+	w := newWebhookNotifier(srv.URL, 0, time.Millisecond, func(_ string, _ ...any) {
+		dropped.Add(1)
+	})
+
+	start := time.Now()
+	for i := 0; i < notificationQueueSize+10; i++ {
+		w.notify(stateChange{Event: stateChangeDown})
+	}
+
+	// the receiver is stuck, yet notifying returns right away
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Eventually(t, func() bool { return dropped.Load() >= 9 }, 5*time.Second, 10*time.Millisecond)
+}